@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/tiwariayush700/log-ingestion-service/config"
+	"github.com/tiwariayush700/log-ingestion-service/internal/alert"
+	"github.com/tiwariayush700/log-ingestion-service/internal/storage"
+	"github.com/tiwariayush700/log-ingestion-service/internal/tracker"
+)
+
+// newAlerter builds the alerting rules and notification channels from the
+// configuration
+func newAlerter(cfg *config.Config, store *storage.Storage, track *tracker.Tracker) *alert.Alerter {
+	var rules []alert.Rule
+	if cfg.AlertConsecutiveFailures > 0 {
+		rules = append(rules, alert.ConsecutiveFailures{Threshold: cfg.AlertConsecutiveFailures})
+	}
+	if cfg.AlertStaleness {
+		rules = append(rules, alert.Staleness{})
+	}
+	if cfg.AlertCountDrop > 0 {
+		rules = append(rules, alert.NewCountDrop(track, cfg.AlertCountDrop, cfg.AlertCountWindow))
+	}
+
+	var notifiers []alert.Notifier
+	if cfg.AlertWebhookURL != "" {
+		notifiers = append(notifiers, alert.NewWebhook(cfg.AlertWebhookURL, cfg.AlertWebhookSecret))
+	}
+	if cfg.AlertSlackWebhookURL != "" {
+		notifiers = append(notifiers, alert.NewSlack(cfg.AlertSlackWebhookURL))
+	}
+	if cfg.AlertSMTPAddr != "" {
+		notifiers = append(notifiers, alert.NewEmail(cfg.AlertSMTPAddr, cfg.AlertSMTPUsername, cfg.AlertSMTPPassword, cfg.AlertSMTPFrom, cfg.AlertSMTPTo))
+	}
+
+	return alert.New(store, track, rules, notifiers)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/jobs"
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// backfiller runs the chunks of backfill jobs through the pipeline of
+// their source
+type backfiller map[string]*pipeline
+
+// Backfill fetches the chunk from the source, bounded by the query
+// parameters of the job, and ingests it
+func (b backfiller) Backfill(ctx context.Context, job models.Job, from, to time.Time) (jobs.Counts, error) {
+	p, ok := b[job.Source]
+	if !ok {
+		return jobs.Counts{}, fmt.Errorf("%w: %s", jobs.ErrUnknownSource, job.Source)
+	}
+
+	query := url.Values{}
+	query.Set(job.FromParam, from.Format(time.RFC3339))
+	query.Set(job.ToParam, to.Format(time.RFC3339))
+	return p.backfill(ctx, query)
+}
@@ -8,38 +8,107 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // The runtime image ships without a zoneinfo database
 
 	"github.com/tiwariayush700/log-ingestion-service/config"
 	"github.com/tiwariayush700/log-ingestion-service/internal/api"
-	"github.com/tiwariayush700/log-ingestion-service/internal/fetcher"
+	"github.com/tiwariayush700/log-ingestion-service/internal/jobs"
+	"github.com/tiwariayush700/log-ingestion-service/internal/lease"
+	"github.com/tiwariayush700/log-ingestion-service/internal/reprocess"
+	"github.com/tiwariayush700/log-ingestion-service/internal/shard"
 	"github.com/tiwariayush700/log-ingestion-service/internal/storage"
 	"github.com/tiwariayush700/log-ingestion-service/internal/tracker"
-	"github.com/tiwariayush700/log-ingestion-service/internal/transformer"
+	"github.com/tiwariayush700/log-ingestion-service/internal/validator"
 )
 
 func main() {
 	cfg := config.LoadConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Initialize components
-	fetch := fetcher.New(cfg.APIEndpoint)
-	transform := transformer.New(cfg.SourceName)
-
 	store, err := storage.New(cfg.MongoURI, cfg.MongoDatabase, cfg.MongoCollection)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
-	track, err := tracker.New(cfg.MongoURI, cfg.MongoDatabase)
+	validate, err := validator.New(cfg.SourceSchemas)
+	if err != nil {
+		log.Fatalf("Failed to initialize validator: %v", err)
+	}
+
+	var reprocessors []*reprocess.Reprocessor
+	for _, source := range cfg.SourceNames() {
+		reprocessor, err := newReprocessor(cfg, source, validate, store)
+		if err != nil {
+			log.Fatalf("Failed to initialize transformer of %s: %v", source, err)
+		}
+		reprocessors = append(reprocessors, reprocessor)
+	}
+	reprocessor := reprocess.NewRouter(reprocessors...)
+
+	if len(os.Args) > 1 && os.Args[1] == "reprocess" {
+		err := runReprocess(reprocessor, os.Args[2:])
+		closeCtx, closeCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer closeCancel()
+		if closeErr := store.Close(closeCtx); closeErr != nil {
+			log.Printf("Error closing storage: %v", closeErr)
+		}
+		if err != nil {
+			log.Fatalf("Reprocessing failed: %v", err)
+		}
+		return
+	}
+
+	// Every configured source is held to its freshness SLO
+	trackerOpts := []tracker.Option{tracker.WithAnomalyDetection(cfg.AnomalyWindow, cfg.AnomalyThreshold)}
+	for _, source := range cfg.SourceNames() {
+		maxStaleness, err := cfg.MaxStalenessFor(source)
+		if err != nil {
+			log.Printf("Falling back to the default SLO: %v", err)
+		}
+		trackerOpts = append(trackerOpts, tracker.WithSLO(source, maxStaleness))
+	}
+
+	track, err := tracker.New(cfg.MongoURI, cfg.MongoDatabase, trackerOpts...)
 	if err != nil {
 		log.Fatalf("Failed to initialize tracker: %v", err)
 	}
 
+	pipelines := make(map[string]*pipeline)
+	for _, source := range cfg.SourceNames() {
+		p, err := newPipeline(cfg, source, validate, store, track)
+		if err != nil {
+			log.Fatalf("Failed to initialize transformer of %s: %v", source, err)
+		}
+		pipelines[source] = p
+	}
+
 	// Set up context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// The sources are split between the live replicas, or ingested by the
+	// replica holding the lease; all replicas serve the API
+	var coordinator *shard.Coordinator
+	var elector *lease.Elector
+	var leader api.LeaderInterface
+	var cluster api.ClusterInterface
+	switch {
+	case cfg.Sharding:
+		coordinator = shard.NewCoordinator(store, cfg.InstanceID, cfg.SourceNames(), cfg.LeaseTTL)
+		cluster = coordinator
+	case cfg.LeaderElection:
+		elector = lease.NewElector(store, "ingestion", cfg.InstanceID, cfg.LeaseTTL)
+		leader = elector
+	}
+
+	// Backfill jobs are run by any replica, one claim at a time
+	runner := jobs.New(store, backfiller(pipelines), cfg.SourceNames(), cfg.InstanceID, cfg.JobLeaseTTL)
+
 	// Start the API server
-	apiServer := api.New(store, track)
+	apiServer := api.New(store, track, reprocessor, leader, cluster, runner)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -51,25 +120,49 @@ func main() {
 	}()
 
 	// Start the ingestion process
-	wg.Add(1)
+	ingestAll := func(ctx context.Context) {
+		var sources sync.WaitGroup
+		for _, p := range pipelines {
+			sources.Add(1)
+			go func(p *pipeline) {
+				defer sources.Done()
+				p.run(ctx)
+			}(p)
+		}
+		sources.Wait()
+	}
+
+	var ingestion sync.WaitGroup
+	ingestion.Add(1)
 	go func() {
-		defer wg.Done()
-		ticker := time.NewTicker(cfg.FetchInterval)
-		defer ticker.Stop()
-
-		// Run immediately on startup
-		ingestData(ctx, fetch, transform, store, track)
-
-		for {
-			select {
-			case <-ticker.C:
-				ingestData(ctx, fetch, transform, store, track)
-			case <-ctx.Done():
-				return
-			}
+		defer ingestion.Done()
+		switch {
+		case coordinator != nil:
+			coordinator.Run(ctx, func(ctx context.Context, source string) {
+				pipelines[source].run(ctx)
+			})
+		case elector != nil:
+			elector.Run(ctx, ingestAll)
+		default:
+			ingestAll(ctx)
 		}
 	}()
 
+	// Every replica evaluates the alerts, the alert state in the database
+	// makes sure each change is notified once
+	alerter := newAlerter(cfg, store, track)
+	ingestion.Add(1)
+	go func() {
+		defer ingestion.Done()
+		alerter.Run(ctx, cfg.AlertInterval)
+	}()
+
+	ingestion.Add(1)
+	go func() {
+		defer ingestion.Done()
+		runner.Run(ctx, cfg.JobPollInterval)
+	}()
+
 	// Handle graceful shutdown
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -81,6 +174,12 @@ func main() {
 		log.Println("Context cancelled")
 	}
 
+	// Stop ingestion before closing storage, so the lease, the sources and
+	// the running job of this replica are released and other replicas take
+	// over right away
+	cancel()
+	ingestion.Wait()
+
 	// Clean up resources
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
@@ -93,40 +192,6 @@ func main() {
 		log.Printf("Error closing tracker: %v", err)
 	}
 
-	cancel()  // Cancel the context to stop all goroutines
 	wg.Wait() // Wait for all goroutines to finish
 	log.Println("Application shutdown complete")
 }
-
-func ingestData(ctx context.Context, fetch *fetcher.Fetcher, transform *transformer.Transformer, store *storage.Storage, track *tracker.Tracker) {
-	log.Println("Starting data ingestion...")
-
-	// Fetch data
-	posts, err := fetch.FetchPosts(ctx)
-	if err != nil {
-		log.Printf("Error fetching posts: %v", err)
-		if trackErr := track.RecordFailure(ctx, err); trackErr != nil {
-			log.Printf("Error recording failure: %v", trackErr)
-		}
-		return
-	}
-
-	// Transform data
-	enrichedPosts := transform.TransformPosts(posts)
-
-	// Store data
-	if err := store.StorePosts(ctx, enrichedPosts); err != nil {
-		log.Printf("Error storing posts: %v", err)
-		if trackErr := track.RecordFailure(ctx, err); trackErr != nil {
-			log.Printf("Error recording failure: %v", trackErr)
-		}
-		return
-	}
-
-	// Record success
-	if err := track.RecordSuccess(ctx, len(enrichedPosts)); err != nil {
-		log.Printf("Error recording success: %v", err)
-	}
-
-	log.Printf("Successfully ingested %d posts", len(enrichedPosts))
-}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/config"
+	"github.com/tiwariayush700/log-ingestion-service/internal/dedup"
+	"github.com/tiwariayush700/log-ingestion-service/internal/drain"
+	"github.com/tiwariayush700/log-ingestion-service/internal/fetcher"
+	"github.com/tiwariayush700/log-ingestion-service/internal/history"
+	"github.com/tiwariayush700/log-ingestion-service/internal/jobs"
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"github.com/tiwariayush700/log-ingestion-service/internal/reprocess"
+	"github.com/tiwariayush700/log-ingestion-service/internal/schedule"
+	"github.com/tiwariayush700/log-ingestion-service/internal/schema"
+	"github.com/tiwariayush700/log-ingestion-service/internal/severity"
+	"github.com/tiwariayush700/log-ingestion-service/internal/storage"
+	"github.com/tiwariayush700/log-ingestion-service/internal/tombstone"
+	"github.com/tiwariayush700/log-ingestion-service/internal/tracker"
+	"github.com/tiwariayush700/log-ingestion-service/internal/transformer"
+	"github.com/tiwariayush700/log-ingestion-service/internal/validator"
+)
+
+// pipeline ingests a source, from fetching its posts to recording the run
+type pipeline struct {
+	source     string
+	fetch      *fetcher.Fetcher
+	drift      *schema.Detector
+	validate   *validator.Validator
+	transform  *transformer.Transformer
+	versions   *history.Recorder
+	tombstones *tombstone.Marker
+	store      *storage.Storage
+	track      *tracker.Tracker
+	schedule   *schedule.Runner
+}
+
+// newPipeline builds the pipeline of a source from the configuration
+func newPipeline(cfg *config.Config, source string, validate *validator.Validator, store *storage.Storage, track *tracker.Tracker) (*pipeline, error) {
+	transform, err := newTransformer(cfg, source, store, false)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(cfg.ScheduleTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule timezone %q: %w", cfg.ScheduleTimezone, err)
+	}
+	spec, catchUp := cfg.ScheduleFor(source)
+	scheduler, err := schedule.New(spec, catchUp, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule source %s: %w", source, err)
+	}
+	timeout, overlap, err := cfg.RunPolicyFor(source)
+	if err != nil {
+		return nil, err
+	}
+	runner, err := schedule.NewRunner(source, scheduler, track, timeout, overlap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule source %s: %w", source, err)
+	}
+
+	// Records missing from a snapshot source were deleted upstream,
+	// wherever rules routed their posts
+	var tombstones *tombstone.Marker
+	if cfg.IsSnapshotSource(source) {
+		collections, err := routedCollections(cfg)
+		if err != nil {
+			return nil, err
+		}
+		tombstones = tombstone.NewMarker(store, cfg.TombstonePurgeAfter, collections)
+	}
+
+	return &pipeline{
+		source:     source,
+		fetch:      fetcher.New(cfg.Sources[source], fetcher.WithRetries(cfg.FetchRetries, cfg.FetchRetryBackoff)),
+		drift:      schema.NewDetector(store, cfg.SchemaNullRateDelta),
+		validate:   validate,
+		transform:  transform,
+		versions:   history.NewRecorder(store),
+		tombstones: tombstones,
+		store:      store,
+		track:      track,
+		schedule:   runner,
+	}, nil
+}
+
+// newReprocessor builds the reprocessor of a source. Stored posts are
+// replayed without the stages that depend on what was ingested before,
+// dedup and sampling, nor template mining, which would count them again.
+// Posts are read from the default collection and the ones rules route to.
+func newReprocessor(cfg *config.Config, source string, validate *validator.Validator, store *storage.Storage) (*reprocess.Reprocessor, error) {
+	transform, err := newTransformer(cfg, source, store, true)
+	if err != nil {
+		return nil, err
+	}
+
+	collections, err := routedCollections(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return reprocess.New(source, validate, transform, store, cfg.ReprocessBatchSize, collections), nil
+}
+
+// routedCollections returns the collections rules route posts to, besides
+// the default one
+func routedCollections(cfg *config.Config) ([]string, error) {
+	if cfg.RulesConfigPath == "" {
+		return nil, nil
+	}
+	rules, err := transformer.ReadRuleConfigs(cfg.RulesConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var collections []string
+	for _, rule := range rules {
+		if rule.Action == transformer.RuleRoute {
+			collections = append(collections, rule.Collection)
+		}
+	}
+	return collections, nil
+}
+
+// run ingests the source on its schedule until ctx is done. The state the
+// stages keep is reloaded first, since the source may have been run by
+// another replica before this one acquired the lease or was assigned it.
+func (p *pipeline) run(ctx context.Context) {
+	if err := p.transform.Reload(ctx); err != nil {
+		log.Printf("Error reloading the transform state of %s: %v", p.source, err)
+	}
+	p.schedule.Run(ctx, p.ingest)
+}
+
+// ingest runs the pipeline once, recording the run in the tracker
+func (p *pipeline) ingest(ctx context.Context) {
+	log.Printf("Starting data ingestion of %s...", p.source)
+
+	// Record the run as started, it is finalized whatever the outcome
+	run, err := p.track.StartRun(ctx, p.source)
+	if err != nil {
+		log.Printf("Error recording run start: %v", err)
+	}
+	fail := func(message string, err error) {
+		// A run that timed out or was replaced says so
+		if cause := schedule.Interruption(ctx); cause != nil {
+			err = fmt.Errorf("%v: %w", cause, err)
+		}
+		log.Printf("%s: %v", message, err)
+		run.Error = err.Error()
+		finishRun(p.track, run)
+	}
+
+	// Fetch data
+	start := time.Now()
+	posts, stats, err := p.fetch.Fetch(ctx)
+	run.Timings.FetchMs = milliseconds(time.Since(start))
+	run.Bytes = stats.Bytes
+	run.HTTPStatus = stats.StatusCode
+	run.Attempts = stats.Attempts
+	if err != nil {
+		fail("Error fetching posts", err)
+		return
+	}
+	run.Fetched = len(posts)
+
+	// Compare the payload schema with the baseline of the source
+	changes, err := p.drift.Observe(ctx, p.source, posts)
+	if err != nil {
+		log.Printf("Error detecting schema drift: %v", err)
+	}
+	for _, change := range changes {
+		log.Printf("Schema drift in source %s: field %s %s", p.source, change.Path, change.Kind)
+	}
+	fetched := posts
+
+	// Validate, transform and store the posts
+	posts, err = p.process(ctx, &run, posts)
+	if len(changes) > 0 && run.Counters != nil {
+		run.Counters["schema_changes"] = len(changes)
+	}
+	if err != nil {
+		fail("Error processing posts", err)
+		return
+	}
+
+	// Record a version of the upstream records that are new or changed
+	summary, err := p.versions.Observe(ctx, p.source, posts)
+	if err != nil {
+		log.Printf("Error recording version history: %v", err)
+	}
+	run.New = summary.New
+	run.Changed = summary.Changed
+	run.Unchanged = summary.Unchanged
+
+	// Tombstone the records that disappeared from a snapshot source. Records
+	// rejected by this run are still live upstream and count as present.
+	if p.tombstones != nil {
+		deletions, err := p.tombstones.Observe(ctx, p.source, fetched)
+		if err != nil {
+			log.Printf("Error recording deletions: %v", err)
+		}
+		run.Deleted = deletions.Deleted
+		run.Purged = deletions.Purged
+	}
+
+	// Record success
+	run.Success = true
+	finishRun(p.track, run)
+
+	log.Printf("Successfully ingested %d posts of %s (%d rejected, %d dropped, %d duplicates, %d new, %d changed, %d deleted)", run.Count, p.source, run.Rejected, run.Dropped, run.Duplicates, run.New, run.Changed, run.Deleted)
+}
+
+// process validates and transforms posts, storing the records and the
+// rejects, and returns the valid posts. The counts and timings of the steps
+// are recorded on run.
+func (p *pipeline) process(ctx context.Context, run *models.IngestStatus, posts []models.Post) ([]models.Post, error) {
+	// Validate data, setting invalid records aside
+	start := time.Now()
+	posts, rejected := p.validate.Validate(p.source, posts)
+
+	// Transform data
+	result, err := p.transform.Transform(ctx, posts)
+	run.Timings.TransformMs = milliseconds(time.Since(start))
+	run.StageLatency = result.StageLatency()
+	if err != nil {
+		return posts, fmt.Errorf("failed to transform posts: %w", err)
+	}
+	enrichedPosts := result.Posts
+	run.Transformed = len(enrichedPosts)
+	run.Dropped = result.Dropped
+	run.Duplicates = result.Duplicates
+	run.Counters = result.Counters
+
+	// Store the records rejected by validation or by stages
+	start = time.Now()
+	rejected = append(rejected, result.Rejected...)
+	run.Rejected = len(rejected)
+	if err := p.store.StoreRejected(ctx, rejected); err != nil {
+		return posts, fmt.Errorf("failed to store rejected posts: %w", err)
+	}
+
+	// Store data
+	err = p.store.StorePosts(ctx, enrichedPosts)
+	run.Timings.StoreMs = milliseconds(time.Since(start))
+	if err != nil {
+		return posts, fmt.Errorf("failed to store posts: %w", err)
+	}
+	run.Count = len(enrichedPosts)
+
+	// Only stored posts are remembered by dedup, so a retry goes through
+	// the posts of a failed batch again
+	if err := p.transform.Commit(ctx, result); err != nil {
+		return posts, fmt.Errorf("failed to commit transformed posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// backfill re-ingests the posts the source returns for a query, such as
+// the range of a backfill chunk. Backfilled records are not a snapshot of
+// the source and are not tracked as a run, so schema drift, version
+// history and tombstones are left to scheduled runs.
+func (p *pipeline) backfill(ctx context.Context, query url.Values) (jobs.Counts, error) {
+	// Any replica runs backfills, whether or not it runs the source
+	if err := p.transform.Reload(ctx); err != nil {
+		return jobs.Counts{}, err
+	}
+
+	posts, _, err := p.fetch.FetchQuery(ctx, query)
+	if err != nil {
+		return jobs.Counts{}, err
+	}
+
+	var run models.IngestStatus
+	_, err = p.process(ctx, &run, posts)
+	return jobs.Counts{Fetched: len(posts), Stored: run.Count, Rejected: run.Rejected}, err
+}
+
+// finishRun finalizes a run. It does not use the ingestion context so runs
+// interrupted by a shutdown are still recorded.
+func finishRun(track *tracker.Tracker, run models.IngestStatus) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := track.FinishRun(ctx, run); err != nil {
+		log.Printf("Error recording run end: %v", err)
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// newTransformer builds the transformer and its stages from the configuration.
+// The replay transformer used to reprocess stored posts leaves out dedup,
+// sampling and template mining.
+func newTransformer(cfg *config.Config, source string, store *storage.Storage, replay bool) (*transformer.Transformer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	location, err := cfg.TimezoneFor(source)
+	if err != nil {
+		log.Printf("Falling back to UTC: %v", err)
+	}
+
+	defaultSeverity := severity.Unspecified
+	if cfg.SeverityDefault != "" {
+		var ok bool
+		if defaultSeverity, ok = severity.Parse(cfg.SeverityDefault); !ok {
+			return nil, fmt.Errorf("unknown default severity: %s", cfg.SeverityDefault)
+		}
+	}
+	stages := []transformer.Stage{
+		transformer.NewSeverityStage(cfg.SeverityFields, severity.Scheme(cfg.SeverityNumericScheme), defaultSeverity),
+	}
+
+	if cfg.LookupConfigPath != "" {
+		lookups, err := transformer.ReadLookupConfigs(cfg.LookupConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, lookupConfig := range lookups {
+			lookup, err := transformer.NewLookupStage(lookupConfig)
+			if err != nil {
+				return nil, err
+			}
+			stages = append(stages, lookup)
+		}
+	}
+
+	if len(cfg.GeoIPDatabases) > 0 {
+		stages = append(stages, transformer.NewGeoIPStage(cfg.GeoIPField, cfg.GeoIPDatabases))
+	}
+
+	if path, ok := cfg.SourceScripts[source]; ok {
+		script, err := transformer.NewScriptStage(path, cfg.ScriptTimeout)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, script)
+	}
+
+	if cfg.TemplateField != "" && !replay {
+		config := drain.Config{
+			Depth:        cfg.TemplateDepth,
+			SimThreshold: cfg.TemplateSimThreshold,
+			MaxChildren:  cfg.TemplateMaxChildren,
+		}
+		templates, err := transformer.NewTemplateStage(ctx, source, cfg.TemplateField, config, store)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, templates)
+	}
+
+	if cfg.RulesConfigPath != "" {
+		rules, err := transformer.ReadRuleConfigs(cfg.RulesConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		rulesStage, err := transformer.NewRulesStage(rules)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, rulesStage)
+	}
+
+	fingerprint, err := transformer.NewFingerprintStage(cfg.FingerprintFields, cfg.FingerprintNormalize)
+	if err != nil {
+		return nil, err
+	}
+	stages = append(stages, fingerprint)
+
+	if cfg.DedupMode != "off" && !replay {
+		var window dedup.Window
+		name := "dedup_" + source
+		switch cfg.DedupStore {
+		case "bloom":
+			window, err = dedup.NewBloomWindow(ctx, name, store, cfg.DedupWindow, cfg.DedupBloomCapacity, cfg.DedupBloomFPRate)
+		case "exact":
+			window, err = store.FingerprintWindow(ctx, name, cfg.DedupWindow)
+		default:
+			err = fmt.Errorf("unknown dedup store: %s", cfg.DedupStore)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		dedupStage, err := transformer.NewDedupStage(window, transformer.DedupMode(cfg.DedupMode))
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, dedupStage)
+	}
+
+	if (cfg.SampleRate < 1 || cfg.SampleCapField != "") && !replay {
+		sampling, err := transformer.NewSamplingStage(transformer.SamplingConfig{
+			Rate:      cfg.SampleRate,
+			KeyField:  cfg.SampleKey,
+			CapField:  cfg.SampleCapField,
+			Cap:       cfg.SampleCap,
+			CapWindow: cfg.SampleCapWindow,
+		})
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, sampling)
+	}
+
+	return transformer.New(source,
+		transformer.WithTimestampExtractor(transformer.NewTimestampExtractor(cfg.EventTimeFields, cfg.EventTimeLayouts, location)),
+		transformer.WithStages(stages...),
+		transformer.WithWorkers(cfg.TransformWorkers, cfg.TransformQueueSize),
+		transformer.WithPreserveOrder(cfg.TransformPreserveOrder),
+		transformer.WithVersion(cfg.TransformerVersion),
+		transformer.WithRawPayload(cfg.StoreRawPayload),
+	), nil
+}
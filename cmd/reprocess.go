@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"github.com/tiwariayush700/log-ingestion-service/internal/reprocess"
+)
+
+// runReprocess reprocesses stored posts from the command line, e.g.
+//
+//	log-ingestion-service reprocess -source placeholder_api -from 2023-01-01T00:00:00Z -into posts_v2
+func runReprocess(reprocessor *reprocess.Router, args []string) error {
+	flags := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	source := flags.String("source", "", "source of the posts to reprocess")
+	from := flags.String("from", "", "start of the event time range, RFC3339")
+	to := flags.String("to", "", "end of the event time range, RFC3339")
+	into := flags.String("into", "", "collection receiving the reprocessed posts; they replace the stored ones when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	request := models.ReprocessRequest{Source: *source, Collection: *into}
+	if request.Source == "" {
+		return fmt.Errorf("-source is required")
+	}
+	var err error
+	if *from != "" {
+		if request.From, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *to != "" {
+		if request.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := reprocessor.Reprocess(ctx, request)
+	log.Printf("Reprocessed %d posts of %s from %d upstream records with transformer %s: %d stored, %d dropped, %d rejected, %d deleted",
+		result.Read, result.Source, result.Replayed, result.TransformerVersion, result.Stored, result.Dropped, result.Rejected, result.Deleted)
+	return err
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,11 +18,143 @@ type Config struct {
 	MongoCollection string
 	FetchInterval   time.Duration
 	ServerPort      string
+
+	// Sources ingested, endpoint by source name. A single source is built
+	// from APIEndpoint and SourceName when none is set.
+	Sources map[string]string
+
+	// Freshness SLOs, maximum time since the last successful run of each
+	// source, none when zero
+	MaxStaleness       time.Duration
+	SourceMaxStaleness map[string]string
+
+	// Schedules of the sources, every FetchInterval unless set, evaluated
+	// in ScheduleTimezone, and the policy for the runs they missed
+	Schedule         string
+	SourceSchedules  map[string]string
+	ScheduleTimezone string
+	CatchUp          string
+	SourceCatchUp    map[string]string
+
+	// Runs of a source are bounded by RunTimeout, none when zero, and the
+	// overlap policy decides what happens to a run due while one is going
+	RunTimeout          time.Duration
+	SourceRunTimeout    map[string]string
+	OverlapPolicy       string
+	SourceOverlapPolicy map[string]string
+
+	// Leader election, only the replica holding the lease ingests. With
+	// sharding every live replica ingests its share of the sources instead.
+	LeaderElection bool
+	Sharding       bool
+	InstanceID     string
+	LeaseTTL       time.Duration
+
+	// Count anomaly detection, over the counts of the last AnomalyWindow
+	// successful runs of each source
+	AnomalyWindow    int
+	AnomalyThreshold float64
+
+	// Alerting rules, disabled when zero, and notification channels, each
+	// enabled when its URL or server is set
+	AlertInterval            time.Duration
+	AlertConsecutiveFailures int
+	AlertStaleness           bool
+	AlertCountDrop           float64
+	AlertCountWindow         int
+	AlertWebhookURL          string
+	AlertWebhookSecret       string
+	AlertSlackWebhookURL     string
+	AlertSMTPAddr            string
+	AlertSMTPUsername        string
+	AlertSMTPPassword        string
+	AlertSMTPFrom            string
+	AlertSMTPTo              []string
+
+	// Backfill jobs, polled for at JobPollInterval; a replica holds the job
+	// it runs for JobLeaseTTL after each chunk
+	JobPollInterval time.Duration
+	JobLeaseTTL     time.Duration
+
+	// Fetch retries, with the backoff doubling after each attempt
+	FetchRetries      int
+	FetchRetryBackoff time.Duration
+
+	// Transform worker pool
+	TransformWorkers       int
+	TransformQueueSize     int
+	TransformPreserveOrder bool
+
+	// Raw payload preservation and reprocessing
+	TransformerVersion string
+	StoreRawPayload    bool
+	ReprocessBatchSize int
+
+	// Event time extraction
+	EventTimeFields  []string
+	EventTimeLayouts []string
+	SourceTimezones  map[string]string
+
+	// Severity detection
+	SeverityFields        []string
+	SeverityNumericScheme string
+	SeverityDefault       string
+
+	// Content fingerprinting and deduplication
+	FingerprintFields    []string
+	FingerprintNormalize []string
+	DedupMode            string
+	DedupWindow          time.Duration
+	DedupStore           string
+	DedupBloomCapacity   int
+	DedupBloomFPRate     float64
+
+	// Lookup table enrichment
+	LookupConfigPath string
+
+	// GeoIP enrichment
+	GeoIPDatabases []string
+	GeoIPField     string
+
+	// Filtering and routing rules
+	RulesConfigPath string
+
+	// Sampling
+	SampleRate      float64
+	SampleKey       string
+	SampleCapField  string
+	SampleCap       int
+	SampleCapWindow time.Duration
+
+	// Schema validation, JSON Schema file per source
+	SourceSchemas map[string]string
+
+	// Scripting, JavaScript file per source and per-record time limit
+	SourceScripts map[string]string
+	ScriptTimeout time.Duration
+
+	// Template mining, disabled when TemplateField is empty
+	TemplateField        string
+	TemplateDepth        int
+	TemplateSimThreshold float64
+	TemplateMaxChildren  int
+
+	// Schema drift detection, change in null rate reported as drift
+	SchemaNullRateDelta float64
+
+	// Tombstones for snapshot sources, purged after the grace period when
+	// it is not zero
+	SnapshotSources     []string
+	TombstonePurgeAfter time.Duration
 }
 
+// MinLeaseTTL is the shortest LEASE_TTL, the lease and the memberships
+// being renewed three times per TTL
+const MinLeaseTTL = 3 * time.Second
+
 // LoadConfig loads the configuration from environment variables
 func LoadConfig() *Config {
-	return &Config{
+	cfg := &Config{
 		APIEndpoint:     getEnv("API_ENDPOINT", "https://jsonplaceholder.typicode.com/posts"),
 		SourceName:      getEnv("SOURCE_NAME", "placeholder_api"),
 		MongoURI:        getEnv("MONGO_URI", "mongodb://localhost:27017"),
@@ -26,7 +162,210 @@ func LoadConfig() *Config {
 		MongoCollection: getEnv("MONGO_COLLECTION", "posts"),
 		FetchInterval:   getDurationEnv("FETCH_INTERVAL", 5*time.Minute),
 		ServerPort:      getEnv("SERVER_PORT", "8080"),
+
+		Sources: getMapEnv("SOURCES", ","),
+
+		MaxStaleness:       getDurationEnv("SLO_MAX_STALENESS", 15*time.Minute),
+		SourceMaxStaleness: getMapEnv("SOURCE_MAX_STALENESS", ","),
+
+		Schedule:         getEnv("SCHEDULE", ""),
+		SourceSchedules:  getMapEnv("SOURCE_SCHEDULES", ";"),
+		ScheduleTimezone: getEnv("SCHEDULE_TIMEZONE", "UTC"),
+		CatchUp:          getEnv("CATCH_UP", "once"),
+		SourceCatchUp:    getMapEnv("SOURCE_CATCH_UP", ","),
+
+		RunTimeout:          getDurationEnv("RUN_TIMEOUT", 10*time.Minute),
+		SourceRunTimeout:    getMapEnv("SOURCE_RUN_TIMEOUT", ","),
+		OverlapPolicy:       getEnv("OVERLAP_POLICY", "queue"),
+		SourceOverlapPolicy: getMapEnv("SOURCE_OVERLAP_POLICY", ","),
+
+		LeaderElection: getBoolEnv("LEADER_ELECTION", true),
+		Sharding:       getBoolEnv("SHARDING", false),
+		InstanceID:     getEnv("INSTANCE_ID", defaultInstanceID()),
+		LeaseTTL:       getDurationEnv("LEASE_TTL", 30*time.Second),
+
+		AnomalyWindow:    getIntEnv("ANOMALY_WINDOW", 20),
+		AnomalyThreshold: getFloatEnv("ANOMALY_THRESHOLD", 3.5),
+
+		AlertInterval:            getDurationEnv("ALERT_INTERVAL", time.Minute),
+		AlertConsecutiveFailures: getIntEnv("ALERT_CONSECUTIVE_FAILURES", 3),
+		AlertStaleness:           getBoolEnv("ALERT_STALENESS", true),
+		AlertCountDrop:           getFloatEnv("ALERT_COUNT_DROP", 0),
+		AlertCountWindow:         getIntEnv("ALERT_COUNT_WINDOW", 10),
+		AlertWebhookURL:          getEnv("ALERT_WEBHOOK_URL", ""),
+		AlertWebhookSecret:       getEnv("ALERT_WEBHOOK_SECRET", ""),
+		AlertSlackWebhookURL:     getEnv("ALERT_SLACK_WEBHOOK_URL", ""),
+		AlertSMTPAddr:            getEnv("ALERT_SMTP_ADDR", ""),
+		AlertSMTPUsername:        getEnv("ALERT_SMTP_USERNAME", ""),
+		AlertSMTPPassword:        getEnv("ALERT_SMTP_PASSWORD", ""),
+		AlertSMTPFrom:            getEnv("ALERT_SMTP_FROM", ""),
+		AlertSMTPTo:              getListEnv("ALERT_SMTP_TO", ",", nil),
+
+		JobPollInterval: getDurationEnv("JOB_POLL_INTERVAL", 10*time.Second),
+		JobLeaseTTL:     getDurationEnv("JOB_LEASE_TTL", 10*time.Minute),
+
+		FetchRetries:      getIntEnv("FETCH_RETRIES", 0),
+		FetchRetryBackoff: getDurationEnv("FETCH_RETRY_BACKOFF", time.Second),
+
+		TransformWorkers:       getIntEnv("TRANSFORM_WORKERS", 1),
+		TransformQueueSize:     getIntEnv("TRANSFORM_QUEUE_SIZE", 0),
+		TransformPreserveOrder: getBoolEnv("TRANSFORM_PRESERVE_ORDER", true),
+
+		TransformerVersion: getEnv("TRANSFORMER_VERSION", "1"),
+		StoreRawPayload:    getBoolEnv("STORE_RAW_PAYLOAD", true),
+		ReprocessBatchSize: getIntEnv("REPROCESS_BATCH_SIZE", 500),
+
+		EventTimeFields:  getListEnv("EVENT_TIME_FIELDS", ",", nil),
+		EventTimeLayouts: getListEnv("EVENT_TIME_LAYOUTS", ";", nil),
+		SourceTimezones:  getMapEnv("SOURCE_TIMEZONES", ","),
+
+		SeverityFields:        getListEnv("SEVERITY_FIELDS", ",", nil),
+		SeverityNumericScheme: getEnv("SEVERITY_NUMERIC_SCHEME", "syslog"),
+		SeverityDefault:       getEnv("SEVERITY_DEFAULT", ""),
+
+		FingerprintFields:    getListEnv("FINGERPRINT_FIELDS", ",", []string{"title", "body"}),
+		FingerprintNormalize: getListEnv("FINGERPRINT_NORMALIZE", ",", []string{"lowercase", "collapse_whitespace"}),
+		DedupMode:            getEnv("DEDUP_MODE", "off"),
+		DedupWindow:          getDurationEnv("DEDUP_WINDOW", 24*time.Hour),
+		DedupStore:           getEnv("DEDUP_STORE", "exact"),
+		DedupBloomCapacity:   getIntEnv("DEDUP_BLOOM_CAPACITY", 1000000),
+		DedupBloomFPRate:     getFloatEnv("DEDUP_BLOOM_FP_RATE", 0.001),
+
+		LookupConfigPath: getEnv("LOOKUP_CONFIG", ""),
+
+		GeoIPDatabases: getListEnv("GEOIP_DATABASES", ",", nil),
+		GeoIPField:     getEnv("GEOIP_FIELD", "client_ip"),
+
+		RulesConfigPath: getEnv("RULES_CONFIG", ""),
+
+		SampleRate:      getFloatEnv("SAMPLE_RATE", 1),
+		SampleKey:       getEnv("SAMPLE_KEY", ""),
+		SampleCapField:  getEnv("SAMPLE_CAP_FIELD", ""),
+		SampleCap:       getIntEnv("SAMPLE_CAP", 1000),
+		SampleCapWindow: getDurationEnv("SAMPLE_CAP_WINDOW", time.Minute),
+
+		SourceSchemas: getMapEnv("SOURCE_SCHEMAS", ","),
+
+		SourceScripts: getMapEnv("SOURCE_SCRIPTS", ","),
+		ScriptTimeout: getDurationEnv("SCRIPT_TIMEOUT", 100*time.Millisecond),
+
+		TemplateField:        getEnv("TEMPLATE_FIELD", ""),
+		TemplateDepth:        getIntEnv("TEMPLATE_DEPTH", 4),
+		TemplateSimThreshold: getFloatEnv("TEMPLATE_SIM_THRESHOLD", 0.4),
+		TemplateMaxChildren:  getIntEnv("TEMPLATE_MAX_CHILDREN", 100),
+
+		SchemaNullRateDelta: getFloatEnv("SCHEMA_NULL_RATE_DELTA", 0.25),
+
+		SnapshotSources:     getListEnv("SNAPSHOT_SOURCES", ",", nil),
+		TombstonePurgeAfter: getDurationEnv("TOMBSTONE_PURGE_AFTER", 0),
+	}
+
+	if len(cfg.Sources) == 0 {
+		cfg.Sources = map[string]string{cfg.SourceName: cfg.APIEndpoint}
+	}
+	return cfg
+}
+
+// SourceNames returns the names of the configured sources, sorted
+func (c *Config) SourceNames() []string {
+	names := make([]string, 0, len(c.Sources))
+	for name := range c.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the settings that cannot fall back to a default
+func (c *Config) Validate() error {
+	if (c.LeaderElection || c.Sharding) && c.LeaseTTL < MinLeaseTTL {
+		return fmt.Errorf("lease TTL must be at least %s, got %s", MinLeaseTTL, c.LeaseTTL)
+	}
+	return nil
+}
+
+// MaxStalenessFor returns the freshness SLO of a source, defaulting to
+// MaxStaleness
+func (c *Config) MaxStalenessFor(source string) (time.Duration, error) {
+	value, ok := c.SourceMaxStaleness[source]
+	if !ok {
+		return c.MaxStaleness, nil
+	}
+
+	maxStaleness, err := time.ParseDuration(value)
+	if err != nil {
+		return c.MaxStaleness, fmt.Errorf("invalid max staleness %q for source %q: %w", value, source, err)
+	}
+	return maxStaleness, nil
+}
+
+// ScheduleFor returns the schedule of a source and its catch-up policy
+func (c *Config) ScheduleFor(source string) (string, string) {
+	spec, ok := c.SourceSchedules[source]
+	if !ok {
+		spec = c.Schedule
+	}
+	if spec == "" {
+		spec = "@every " + c.FetchInterval.String()
+	}
+
+	catchUp, ok := c.SourceCatchUp[source]
+	if !ok {
+		catchUp = c.CatchUp
+	}
+	return spec, catchUp
+}
+
+// RunPolicyFor returns the run timeout and the overlap policy of a source
+func (c *Config) RunPolicyFor(source string) (time.Duration, string, error) {
+	overlap, ok := c.SourceOverlapPolicy[source]
+	if !ok {
+		overlap = c.OverlapPolicy
+	}
+
+	value, ok := c.SourceRunTimeout[source]
+	if !ok {
+		return c.RunTimeout, overlap, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return c.RunTimeout, overlap, fmt.Errorf("invalid run timeout %q for source %q: %w", value, source, err)
+	}
+	return timeout, overlap, nil
+}
+
+// TimezoneFor returns the timezone configured for a source, defaulting to UTC
+func (c *Config) TimezoneFor(source string) (*time.Location, error) {
+	name, ok := c.SourceTimezones[source]
+	if !ok {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC, fmt.Errorf("invalid timezone %q for source %q: %w", name, source, err)
+	}
+	return location, nil
+}
+
+// IsSnapshotSource reports whether a source returns every live record on
+// each fetch
+func (c *Config) IsSnapshotSource(source string) bool {
+	for _, name := range c.SnapshotSources {
+		if name == source {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultInstanceID identifies the replica by host name and process ID
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
 	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
 }
 
 func getEnv(key, defaultValue string) string {
@@ -44,3 +383,59 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getListEnv splits a separated list, dropping empty entries
+func getListEnv(key, sep string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getMapEnv parses a separated list of key=value pairs
+func getMapEnv(key, sep string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range getListEnv(key, sep, nil) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result
+}
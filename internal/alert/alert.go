@@ -0,0 +1,230 @@
+// Package alert evaluates alerting rules against the state of each source
+// and notifies channels when an alert fires and when it resolves
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Store keeps the state of the alerts
+type Store interface {
+	// FireAlert records an alert as firing, reporting whether it was not
+	// firing already
+	FireAlert(ctx context.Context, alert models.Alert) (bool, error)
+	// ResolveAlert records a firing alert as resolved, pending delivery to
+	// the channels, and returns it, nil if it was not firing
+	ResolveAlert(ctx context.Context, id string, at time.Time, pending []string, retryAt time.Time) (*models.Alert, error)
+	// GetUndeliveredAlerts returns the alerts with channels pending
+	// delivery whose retry is due
+	GetUndeliveredAlerts(ctx context.Context, now time.Time) ([]models.Alert, error)
+	// ClaimDelivery takes the next delivery attempt of an alert unchanged
+	// since it was read, reporting whether it did
+	ClaimDelivery(ctx context.Context, alert models.Alert, retryAt time.Time) (bool, error)
+	// RecordDelivery records the channels still pending after an attempt
+	RecordDelivery(ctx context.Context, alert models.Alert, pending []string, deliveryErr string) error
+}
+
+// Undelivered notifications are retried with a delay doubling from
+// minRetryDelay up to maxRetryDelay
+const (
+	minRetryDelay = time.Minute
+	maxRetryDelay = time.Hour
+)
+
+// Tracker reads the state and the runs of the sources
+type Tracker interface {
+	GetStatusRollup(ctx interface{}) (models.StatusRollup, error)
+	GetRuns(ctx interface{}, filter models.RunFilter) ([]models.IngestStatus, error)
+}
+
+// Notifier sends alerts to a channel
+type Notifier interface {
+	// Name identifies the channel in the delivery state of the alerts
+	Name() string
+	Notify(ctx context.Context, alert models.Alert) error
+}
+
+// Alerter evaluates the rules for every source
+type Alerter struct {
+	store     Store
+	tracker   Tracker
+	rules     []Rule
+	notifiers []Notifier
+	now       func() time.Time
+}
+
+// New creates a new Alerter instance
+func New(store Store, tracker Tracker, rules []Rule, notifiers []Notifier) *Alerter {
+	return &Alerter{
+		store:     store,
+		tracker:   tracker,
+		rules:     rules,
+		notifiers: notifiers,
+		now:       time.Now,
+	}
+}
+
+// Run evaluates the rules on startup and then at every interval until ctx
+// is done
+func (a *Alerter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.Evaluate(ctx); err != nil {
+			log.Printf("Error evaluating alerts: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Evaluate checks every rule against every source, firing the alerts of
+// the rules that match and resolving the others. Notifications are only
+// sent when an alert changes state, so a rule matching on every evaluation
+// notifies once. Notifications that failed are then retried.
+func (a *Alerter) Evaluate(ctx context.Context) error {
+	rollup, err := a.tracker.GetStatusRollup(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range rollup.Sources {
+		for _, rule := range a.rules {
+			message, firing, err := rule.Evaluate(ctx, source)
+			if err != nil {
+				log.Printf("Error evaluating rule %s for %s: %v", rule.Name(), source.Source, err)
+				continue
+			}
+			if err := a.transition(ctx, rule.Name(), source.Source, message, firing); err != nil {
+				return err
+			}
+		}
+	}
+
+	a.retry(ctx)
+	return nil
+}
+
+// transition fires or resolves the alert of a rule and source, notifying
+// if its state changed
+func (a *Alerter) transition(ctx context.Context, rule, source, message string, firing bool) error {
+	id := fmt.Sprintf("%s/%s", rule, source)
+	// The database keeps milliseconds, and the delivery state is matched
+	// on the time the alert fired
+	now := a.now().UTC().Truncate(time.Millisecond)
+	retryAt := now.Add(retryDelay(1))
+
+	if !firing {
+		resolved, err := a.store.ResolveAlert(ctx, id, now, a.channels(), retryAt)
+		if err != nil || resolved == nil {
+			return err
+		}
+		a.notify(ctx, *resolved)
+		return nil
+	}
+
+	alert := models.Alert{
+		ID:       id,
+		Rule:     rule,
+		Source:   source,
+		State:    models.AlertFiring,
+		Message:  message,
+		FiredAt:  now,
+		Pending:  a.channels(),
+		Attempts: 1,
+		RetryAt:  &retryAt,
+	}
+	fired, err := a.store.FireAlert(ctx, alert)
+	if err != nil || !fired {
+		return err
+	}
+	a.notify(ctx, alert)
+	return nil
+}
+
+// retry sends the notifications that are due again. Each retry is claimed
+// first, so a single replica sends it.
+func (a *Alerter) retry(ctx context.Context) {
+	now := a.now().UTC()
+	alerts, err := a.store.GetUndeliveredAlerts(ctx, now)
+	if err != nil {
+		log.Printf("Error reading undelivered alerts: %v", err)
+		return
+	}
+
+	for _, alert := range alerts {
+		retryAt := now.Add(retryDelay(alert.Attempts + 1))
+		claimed, err := a.store.ClaimDelivery(ctx, alert, retryAt)
+		if err != nil {
+			log.Printf("Error claiming delivery of alert %s: %v", alert.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		alert.Attempts++
+		alert.RetryAt = &retryAt
+		a.notify(ctx, alert)
+	}
+}
+
+// notify sends an alert to the channels it is pending on and records the
+// channels that failed, to be retried. A failing channel does not keep the
+// others from being notified.
+func (a *Alerter) notify(ctx context.Context, alert models.Alert) {
+	log.Printf("Alert %s %s: %s", alert.ID, alert.State, alert.Message)
+
+	pending := make(map[string]bool, len(alert.Pending))
+	for _, name := range alert.Pending {
+		pending[name] = true
+	}
+
+	// Channels that are no longer configured are dropped
+	var failed, errs []string
+	for _, notifier := range a.notifiers {
+		if !pending[notifier.Name()] {
+			continue
+		}
+		if err := notifier.Notify(ctx, alert); err != nil {
+			log.Printf("Error sending alert %s to %s (attempt %d): %v", alert.ID, notifier.Name(), alert.Attempts, err)
+			failed = append(failed, notifier.Name())
+			errs = append(errs, fmt.Sprintf("%s: %v", notifier.Name(), err))
+		}
+	}
+
+	if err := a.store.RecordDelivery(ctx, alert, failed, strings.Join(errs, "; ")); err != nil {
+		log.Printf("Error recording delivery of alert %s: %v", alert.ID, err)
+	}
+}
+
+// channels returns the names of the configured channels
+func (a *Alerter) channels() []string {
+	names := make([]string, 0, len(a.notifiers))
+	for _, notifier := range a.notifiers {
+		names = append(names, notifier.Name())
+	}
+	return names
+}
+
+// retryDelay returns the delay before the attempt after the given one
+func retryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
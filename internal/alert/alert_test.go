@@ -0,0 +1,244 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryStore keeps alerts in memory
+type memoryStore struct {
+	alerts map[string]models.Alert
+}
+
+func (s *memoryStore) FireAlert(ctx context.Context, alert models.Alert) (bool, error) {
+	if s.alerts[alert.ID].State == models.AlertFiring {
+		return false, nil
+	}
+	s.alerts[alert.ID] = alert
+	return true, nil
+}
+
+func (s *memoryStore) ResolveAlert(ctx context.Context, id string, at time.Time, pending []string, retryAt time.Time) (*models.Alert, error) {
+	alert, ok := s.alerts[id]
+	if !ok || alert.State != models.AlertFiring {
+		return nil, nil
+	}
+	alert.State = models.AlertResolved
+	alert.ResolvedAt = &at
+	alert.Pending = pending
+	alert.Attempts = 1
+	alert.RetryAt = &retryAt
+	alert.DeliveryError = ""
+	s.alerts[id] = alert
+	return &alert, nil
+}
+
+func (s *memoryStore) GetUndeliveredAlerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	var alerts []models.Alert
+	for _, alert := range s.alerts {
+		if len(alert.Pending) > 0 && !alert.RetryAt.After(now) {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+func (s *memoryStore) ClaimDelivery(ctx context.Context, alert models.Alert, retryAt time.Time) (bool, error) {
+	stored := s.alerts[alert.ID]
+	if stored.State != alert.State || stored.Attempts != alert.Attempts {
+		return false, nil
+	}
+	stored.Attempts++
+	stored.RetryAt = &retryAt
+	s.alerts[alert.ID] = stored
+	return true, nil
+}
+
+func (s *memoryStore) RecordDelivery(ctx context.Context, alert models.Alert, pending []string, deliveryErr string) error {
+	stored := s.alerts[alert.ID]
+	if stored.State != alert.State || stored.Attempts != alert.Attempts {
+		return nil
+	}
+	stored.Pending = pending
+	stored.DeliveryError = deliveryErr
+	s.alerts[alert.ID] = stored
+	return nil
+}
+
+// fakeTracker returns fixed source states and runs
+type fakeTracker struct {
+	sources []models.SourceStatus
+	runs    []models.IngestStatus
+}
+
+func (f *fakeTracker) GetStatusRollup(ctx interface{}) (models.StatusRollup, error) {
+	return models.StatusRollup{Sources: f.sources}, nil
+}
+
+func (f *fakeTracker) GetRuns(ctx interface{}, filter models.RunFilter) ([]models.IngestStatus, error) {
+	if len(f.runs) > filter.Limit {
+		return f.runs[:filter.Limit], nil
+	}
+	return f.runs, nil
+}
+
+// recorder keeps the alerts it is notified of, failing while err is set
+type recorder struct {
+	name   string
+	alerts []models.Alert
+	err    error
+}
+
+func (r *recorder) Name() string {
+	if r.name == "" {
+		return "recorder"
+	}
+	return r.name
+}
+
+func (r *recorder) Notify(ctx context.Context, alert models.Alert) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func TestEvaluate(t *testing.T) {
+	store := &memoryStore{alerts: make(map[string]models.Alert)}
+	tracker := &fakeTracker{sources: []models.SourceStatus{
+		{Source: "a", ConsecutiveFailures: 3, LastError: "timeout"},
+		{Source: "b", Breached: true, FreshnessLagSeconds: 1200, MaxStalenessSeconds: 900},
+	}}
+	notifier := &recorder{}
+	alerter := New(store, tracker, []Rule{ConsecutiveFailures{Threshold: 3}, Staleness{}}, []Notifier{notifier})
+	ctx := context.Background()
+
+	if err := alerter.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if len(notifier.alerts) != 2 {
+		t.Fatalf("Expected 2 alerts, got %+v", notifier.alerts)
+	}
+	if alert := notifier.alerts[0]; alert.ID != "consecutive_failures/a" || !strings.Contains(alert.Message, "timeout") {
+		t.Errorf("Expected the failures of a, got %+v", alert)
+	}
+	if alert := notifier.alerts[1]; alert.ID != "staleness/b" || alert.Message != "no successful run for 20m0s, SLO is 15m0s" {
+		t.Errorf("Expected the staleness of b, got %+v", alert)
+	}
+
+	// Firing alerts are not sent again
+	if err := alerter.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if len(notifier.alerts) != 2 {
+		t.Fatalf("Expected no new alert, got %+v", notifier.alerts[2:])
+	}
+
+	// a recovers
+	tracker.sources[0].ConsecutiveFailures = 0
+	if err := alerter.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if len(notifier.alerts) != 3 {
+		t.Fatalf("Expected a resolve notification, got %+v", notifier.alerts)
+	}
+	if alert := notifier.alerts[2]; alert.ID != "consecutive_failures/a" || alert.State != models.AlertResolved || alert.ResolvedAt == nil {
+		t.Errorf("Expected the failures of a to resolve, got %+v", alert)
+	}
+}
+
+func TestEvaluateRetry(t *testing.T) {
+	store := &memoryStore{alerts: make(map[string]models.Alert)}
+	tracker := &fakeTracker{sources: []models.SourceStatus{{Source: "a", Breached: true}}}
+	webhook := &recorder{name: "webhook", err: errors.New("unavailable")}
+	slack := &recorder{name: "slack"}
+	alerter := New(store, tracker, []Rule{Staleness{}}, []Notifier{webhook, slack})
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	alerter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := alerter.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	alert := store.alerts["staleness/a"]
+	if len(slack.alerts) != 1 || len(alert.Pending) != 1 || alert.Pending[0] != "webhook" || !strings.Contains(alert.DeliveryError, "unavailable") {
+		t.Fatalf("Expected the webhook to be pending, got %+v", alert)
+	}
+
+	// The retry is not due yet
+	webhook.err = nil
+	if err := alerter.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if len(webhook.alerts) != 0 {
+		t.Fatalf("Expected no retry before the delay, got %+v", webhook.alerts)
+	}
+
+	// Once due, only the failed channel is notified again
+	now = now.Add(minRetryDelay)
+	if err := alerter.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if len(webhook.alerts) != 1 || len(slack.alerts) != 1 {
+		t.Fatalf("Expected the webhook alone to be retried, got %+v and %+v", webhook.alerts, slack.alerts)
+	}
+	if alert := store.alerts["staleness/a"]; len(alert.Pending) != 0 || alert.Attempts != 2 {
+		t.Errorf("Expected the alert to be delivered on the second attempt, got %+v", alert)
+	}
+
+	// A resolve is delivered to every channel again
+	tracker.sources[0].Breached = false
+	webhook.err = errors.New("unavailable")
+	if err := alerter.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if alert := store.alerts["staleness/a"]; alert.State != models.AlertResolved || len(alert.Pending) != 1 || len(slack.alerts) != 2 {
+		t.Errorf("Expected the resolve to be pending on the webhook, got %+v", alert)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:   time.Minute,
+		2:   2 * time.Minute,
+		4:   8 * time.Minute,
+		7:   time.Hour,
+		100: time.Hour,
+	} {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("Expected a delay of %v after %d attempts, got %v", want, attempts, got)
+		}
+	}
+}
+
+func TestCountDrop(t *testing.T) {
+	tracker := &fakeTracker{runs: []models.IngestStatus{
+		{Count: 40}, {Count: 100}, {Count: 110}, {Count: 90}, {Count: 0},
+	}}
+	rule := NewCountDrop(tracker, 0.5, 3)
+	source := models.SourceStatus{Source: "a"}
+
+	message, firing, err := rule.Evaluate(context.Background(), source)
+	if err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if !firing || message != "latest run stored 40 records, 60% below the average of 100.0 over the previous 3 runs" {
+		t.Errorf("Expected the drop to fire, got %v: %s", firing, message)
+	}
+
+	tracker.runs[0].Count = 60
+	if _, firing, _ := rule.Evaluate(context.Background(), source); firing {
+		t.Errorf("Expected a 40%% drop not to fire")
+	}
+
+	tracker.runs = tracker.runs[:1]
+	if _, firing, _ := rule.Evaluate(context.Background(), source); firing {
+		t.Errorf("Expected a single run not to fire")
+	}
+}
@@ -0,0 +1,236 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the webhook body, hex encoded
+// and prefixed with sha256=
+const SignatureHeader = "X-Signature-256"
+
+// Webhook posts alerts as JSON to a URL, signed when a secret is set
+type Webhook struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhook creates a new Webhook notifier
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the name of the channel
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// Notify posts the alert
+func (w *Webhook) Notify(ctx context.Context, alert models.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	headers := map[string]string{}
+	if len(w.secret) > 0 {
+		headers[SignatureHeader] = "sha256=" + Sign(w.secret, body)
+	}
+	return post(ctx, w.client, w.url, body, headers)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of a body
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Slack posts alerts to a Slack incoming webhook, or any webhook accepting
+// the same payload
+type Slack struct {
+	url    string
+	client *http.Client
+}
+
+// NewSlack creates a new Slack notifier
+func NewSlack(url string) *Slack {
+	return &Slack{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the name of the channel
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+// Notify posts the alert as a text message
+func (s *Slack) Notify(ctx context.Context, alert models.Alert) error {
+	body, err := json.Marshal(map[string]string{"text": Summary(alert)})
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+	return post(ctx, s.client, s.url, body, nil)
+}
+
+// post sends a JSON body and fails on a non 2xx response
+func post(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Email sends alerts through an SMTP server
+type Email struct {
+	addr    string
+	auth    smtp.Auth
+	from    string
+	to      []string
+	timeout time.Duration
+}
+
+// NewEmail creates a new Email notifier sending through the server at addr,
+// host:port, authenticating when a username is set
+func NewEmail(addr, username, password, from string, to []string) *Email {
+	email := &Email{addr: addr, from: from, to: to, timeout: 10 * time.Second}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		email.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return email
+}
+
+// Name returns the name of the channel
+func (e *Email) Name() string {
+	return "email"
+}
+
+// Notify mails the alert. The whole exchange with the server must finish
+// within the timeout of the notifier, or before ctx is done.
+func (e *Email) Notify(ctx context.Context, alert models.Alert) error {
+	if err := e.send(ctx, e.message(alert)); err != nil {
+		return fmt.Errorf("failed to send alert email: %w", err)
+	}
+	return nil
+}
+
+// send delivers a message like smtp.SendMail, over a connection with a
+// deadline
+func (e *Email) send(ctx context.Context, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	host, _, _ := strings.Cut(e.addr, ":")
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("server does not support AUTH")
+		}
+		if err := client.Auth(e.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message builds the mail of an alert
+func (e *Email) message(alert models.Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&b, "Subject: [%s] %s on %s\r\n", strings.ToUpper(alert.State), alert.Rule, alert.Source)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\nFired at %s\r\n", alert.Message, alert.FiredAt.Format(time.RFC3339))
+	if alert.ResolvedAt != nil {
+		fmt.Fprintf(&b, "Resolved at %s\r\n", alert.ResolvedAt.Format(time.RFC3339))
+	}
+	return []byte(b.String())
+}
+
+// Summary describes an alert on one line
+func Summary(alert models.Alert) string {
+	summary := fmt.Sprintf("[%s] %s on %s: %s", strings.ToUpper(alert.State), alert.Rule, alert.Source, alert.Message)
+	if alert.ResolvedAt != nil {
+		summary += fmt.Sprintf(" (fired at %s)", alert.FiredAt.Format(time.RFC3339))
+	}
+	return summary
+}
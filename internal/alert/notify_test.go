@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+func testAlert() models.Alert {
+	return models.Alert{
+		ID:      "staleness/a",
+		Rule:    "staleness",
+		Source:  "a",
+		State:   models.AlertFiring,
+		Message: "no successful run for 20m0s, SLO is 15m0s",
+		FiredAt: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	defer server.Close()
+
+	if err := NewWebhook(server.URL, "secret").Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify returned an error: %v", err)
+	}
+
+	var alert models.Alert
+	if err := json.Unmarshal(body, &alert); err != nil || alert.ID != "staleness/a" {
+		t.Errorf("Expected the alert as JSON, got %s", body)
+	}
+	if want := "sha256=" + Sign([]byte("secret"), body); signature != want {
+		t.Errorf("Expected signature %s, got %s", want, signature)
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := NewWebhook(server.URL, "").Notify(context.Background(), testAlert()); err == nil {
+		t.Errorf("Expected an error on a 500 response")
+	}
+}
+
+func TestSlack(t *testing.T) {
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	alert := testAlert()
+	resolvedAt := alert.FiredAt.Add(time.Hour)
+	alert.State = models.AlertResolved
+	alert.ResolvedAt = &resolvedAt
+	if err := NewSlack(server.URL).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("Notify returned an error: %v", err)
+	}
+
+	want := "[RESOLVED] staleness on a: no successful run for 20m0s, SLO is 15m0s (fired at 2023-01-01T12:00:00Z)"
+	if payload["text"] != want {
+		t.Errorf("Expected text %q, got %q", want, payload["text"])
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	email := NewEmail("smtp.example.com:587", "user", "password", "alerts@example.com", []string{"a@example.com", "b@example.com"})
+	message := string(email.message(testAlert()))
+
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: [FIRING] staleness on a\r\n",
+		"\r\n\r\nno successful run for 20m0s, SLO is 15m0s\r\n",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected the message to contain %q, got %q", want, message)
+		}
+	}
+}
+
+func TestEmailTimeout(t *testing.T) {
+	// A server that accepts connections and never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	email := NewEmail(listener.Addr().String(), "", "", "alerts@example.com", []string{"a@example.com"})
+	email.timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if err := email.Notify(context.Background(), testAlert()); err == nil {
+		t.Fatal("Expected an error from a silent server, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the send to time out, took %v", elapsed)
+	}
+}
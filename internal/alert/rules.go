@@ -0,0 +1,103 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Rule decides whether the alert of a source fires
+type Rule interface {
+	Name() string
+	// Evaluate reports whether the alert fires for the source, with a
+	// message describing why
+	Evaluate(ctx context.Context, source models.SourceStatus) (string, bool, error)
+}
+
+// ConsecutiveFailures fires when the latest runs of a source all failed
+type ConsecutiveFailures struct {
+	Threshold int
+}
+
+// Name returns the name of the rule
+func (r ConsecutiveFailures) Name() string {
+	return "consecutive_failures"
+}
+
+// Evaluate fires when the source failed at least Threshold times in a row
+func (r ConsecutiveFailures) Evaluate(ctx context.Context, source models.SourceStatus) (string, bool, error) {
+	if source.ConsecutiveFailures < r.Threshold {
+		return "", false, nil
+	}
+	return fmt.Sprintf("%d consecutive failed runs, last error: %s", source.ConsecutiveFailures, source.LastError), true, nil
+}
+
+// Staleness fires when a source breaches its freshness SLO
+type Staleness struct{}
+
+// Name returns the name of the rule
+func (r Staleness) Name() string {
+	return "staleness"
+}
+
+// Evaluate fires when the time since the last successful run of the source
+// exceeds its SLO
+func (r Staleness) Evaluate(ctx context.Context, source models.SourceStatus) (string, bool, error) {
+	if !source.Breached {
+		return "", false, nil
+	}
+	lag := time.Duration(source.FreshnessLagSeconds * float64(time.Second)).Round(time.Second)
+	maxStaleness := time.Duration(source.MaxStalenessSeconds * float64(time.Second))
+	return fmt.Sprintf("no successful run for %s, SLO is %s", lag, maxStaleness), true, nil
+}
+
+// CountDrop fires when the latest successful run of a source stored
+// markedly fewer records than the runs before it
+type CountDrop struct {
+	tracker Tracker
+	// drop is the fraction of the trailing average below which the count
+	// fires the alert, window the number of runs averaged
+	drop   float64
+	window int
+}
+
+// NewCountDrop creates a new CountDrop rule
+func NewCountDrop(tracker Tracker, drop float64, window int) *CountDrop {
+	if window < 1 {
+		window = 1
+	}
+	return &CountDrop{tracker: tracker, drop: drop, window: window}
+}
+
+// Name returns the name of the rule
+func (r *CountDrop) Name() string {
+	return "count_drop"
+}
+
+// Evaluate compares the count of the latest successful run with the mean
+// of up to window successful runs before it
+func (r *CountDrop) Evaluate(ctx context.Context, source models.SourceStatus) (string, bool, error) {
+	success := true
+	runs, err := r.tracker.GetRuns(ctx, models.RunFilter{Source: source.Source, Success: &success, Limit: r.window + 1})
+	if err != nil {
+		return "", false, err
+	}
+	if len(runs) < 2 {
+		return "", false, nil
+	}
+
+	total := 0
+	for _, run := range runs[1:] {
+		total += run.Count
+	}
+	average := float64(total) / float64(len(runs)-1)
+	latest := float64(runs[0].Count)
+	if average == 0 || latest >= average*(1-r.drop) {
+		return "", false, nil
+	}
+
+	return fmt.Sprintf("latest run stored %d records, %.0f%% below the average of %.1f over the previous %d runs",
+		runs[0].Count, 100*(1-latest/average), average, len(runs)-1), true, nil
+}
@@ -1,37 +1,89 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tiwariayush700/log-ingestion-service/internal/jobs"
 	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"github.com/tiwariayush700/log-ingestion-service/internal/reprocess"
+	"github.com/tiwariayush700/log-ingestion-service/internal/severity"
+	"github.com/tiwariayush700/log-ingestion-service/internal/tracker"
 )
 
 // StorageInterface defines the methods required for storage
 type StorageInterface interface {
-	GetPosts(ctx interface{}) ([]models.EnrichedPost, error)
+	GetPosts(ctx interface{}, filter models.LogFilter) ([]models.EnrichedPost, error)
 	GetPostByID(ctx interface{}, id string) (models.EnrichedPost, error)
+	GetRejected(ctx interface{}, source string, limit int) ([]models.RejectedRecord, error)
+	GetSchema(ctx interface{}, source string, limit int) (*models.SourceSchema, error)
+	GetTemplates(ctx interface{}, source string, limit int) ([]models.LogTemplate, error)
+	GetHistory(ctx interface{}, source string, postID int) ([]models.PostVersion, error)
 }
 
 // TrackerInterface defines the methods required for tracker
 type TrackerInterface interface {
-	GetLatestStatus(ctx interface{}) (models.IngestStatus, error)
+	GetStatusRollup(ctx interface{}) (models.StatusRollup, error)
+	GetRuns(ctx interface{}, filter models.RunFilter) ([]models.IngestStatus, error)
+	GetRun(ctx interface{}, id string) (models.IngestStatus, error)
+	GetRunStats(ctx interface{}, filter models.RunFilter) (models.RunStats, error)
+	GetSkippedRuns(ctx interface{}, source string, limit int) ([]models.SkippedRun, error)
+}
+
+// ReprocessorInterface defines the methods required for reprocessing
+type ReprocessorInterface interface {
+	Reprocess(ctx context.Context, request models.ReprocessRequest) (models.ReprocessResult, error)
+}
+
+// LeaderInterface defines the methods required for leader election
+type LeaderInterface interface {
+	Leader(ctx interface{}) (*models.Lease, error)
+}
+
+// ClusterInterface defines the methods required for sharded ingestion
+type ClusterInterface interface {
+	Members(ctx interface{}) ([]models.Member, error)
+}
+
+// JobsInterface defines the methods required for backfill jobs
+type JobsInterface interface {
+	Create(ctx context.Context, request models.JobRequest) (models.Job, error)
+	Get(ctx context.Context, id string) (models.Job, error)
+	List(ctx context.Context, filter models.JobFilter) ([]models.Job, error)
+	Cancel(ctx context.Context, id string) (models.Job, error)
 }
 
 // API handles HTTP requests
 type API struct {
-	router  *gin.Engine
-	storage StorageInterface
-	tracker TrackerInterface
+	router      *gin.Engine
+	storage     StorageInterface
+	tracker     TrackerInterface
+	reprocessor ReprocessorInterface
+	// leader is nil when leader election is disabled, cluster when
+	// ingestion is not sharded
+	leader  LeaderInterface
+	cluster ClusterInterface
+	jobs    JobsInterface
 }
 
 // New creates a new API instance
-func New(storage StorageInterface, tracker TrackerInterface) *API {
+func New(storage StorageInterface, tracker TrackerInterface, reprocessor ReprocessorInterface, leader LeaderInterface, cluster ClusterInterface, jobs JobsInterface) *API {
 	router := gin.Default()
 	api := &API{
-		router:  router,
-		storage: storage,
-		tracker: tracker,
+		router:      router,
+		storage:     storage,
+		tracker:     tracker,
+		reprocessor: reprocessor,
+		leader:      leader,
+		cluster:     cluster,
+		jobs:        jobs,
 	}
 
 	api.setupRoutes()
@@ -44,7 +96,20 @@ func (a *API) setupRoutes() {
 	{
 		apiGroup.GET("/logs", a.getLogs)
 		apiGroup.GET("/logs/:id", a.getLogByID)
+		apiGroup.GET("/logs/:id/history", a.getLogHistory)
 		apiGroup.GET("/status", a.getStatus)
+		apiGroup.GET("/runs", a.getRuns)
+		apiGroup.GET("/runs/stats", a.getRunStats)
+		apiGroup.GET("/runs/skipped", a.getSkippedRuns)
+		apiGroup.GET("/runs/:id", a.getRun)
+		apiGroup.GET("/rejected", a.getRejected)
+		apiGroup.GET("/sources/:name/schema", a.getSourceSchema)
+		apiGroup.GET("/templates", a.getTemplates)
+		apiGroup.POST("/reprocess", a.postReprocess)
+		apiGroup.POST("/jobs", a.postJob)
+		apiGroup.GET("/jobs", a.getJobs)
+		apiGroup.GET("/jobs/:id", a.getJob)
+		apiGroup.POST("/jobs/:id/cancel", a.cancelJob)
 	}
 }
 
@@ -53,9 +118,21 @@ func (a *API) Run(addr string) error {
 	return a.router.Run(addr)
 }
 
-// getLogs returns all logs
+// sortableFields lists the fields logs can be sorted on
+var sortableFields = map[string]bool{
+	"event_time":  true,
+	"ingested_at": true,
+}
+
+// getLogs returns the logs matching the query parameters
 func (a *API) getLogs(c *gin.Context) {
-	logs, err := a.storage.GetPosts(c.Request.Context())
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logs, err := a.storage.GetPosts(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -64,6 +141,94 @@ func (a *API) getLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, logs)
 }
 
+// severityCondition matches severity conditions such as severity>=warn
+var severityCondition = regexp.MustCompile(`^severity(>=|<=|>|<|=)([A-Za-z0-9]+)$`)
+
+// parseLogFilter builds a log filter from the from, to, severity and sort query parameters
+func parseLogFilter(c *gin.Context) (models.LogFilter, error) {
+	var filter models.LogFilter
+
+	if from := c.Query("from"); from != "" {
+		ts, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from parameter: %w", err)
+		}
+		filter.From = ts
+	}
+
+	if to := c.Query("to"); to != "" {
+		ts, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to parameter: %w", err)
+		}
+		filter.To = ts
+	}
+
+	if err := parseSeverityFilter(c, &filter); err != nil {
+		return filter, err
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		field := strings.TrimPrefix(sort, "-")
+		if !sortableFields[field] {
+			return filter, fmt.Errorf("invalid sort parameter: %s", sort)
+		}
+		filter.SortField = field
+		filter.SortDesc = strings.HasPrefix(sort, "-")
+	}
+
+	return filter, nil
+}
+
+// parseSeverityFilter reads severity conditions from the raw query string.
+// A condition such as severity>=warn reaches the server as the key
+// "severity>" with the value "warn", so the condition is rebuilt from each
+// key and value pair before being parsed.
+func parseSeverityFilter(c *gin.Context, filter *models.LogFilter) error {
+	for key, values := range c.Request.URL.Query() {
+		if !strings.HasPrefix(key, "severity") {
+			continue
+		}
+
+		for _, value := range values {
+			condition := key
+			if value != "" || !strings.ContainsAny(key, "<>") {
+				condition += "=" + value
+			}
+
+			match := severityCondition.FindStringSubmatch(condition)
+			if match == nil {
+				return fmt.Errorf("invalid severity condition: %s", condition)
+			}
+
+			level, ok := severity.Parse(match[2])
+			if !ok {
+				return fmt.Errorf("unknown severity: %s", match[2])
+			}
+
+			switch match[1] {
+			case ">=":
+				filter.MinSeverity = int(level)
+			case ">":
+				filter.MinSeverity = int(level) + 1
+			case "<=":
+				filter.MaxSeverity = int(level)
+			case "<":
+				// No severity is below trace, and a zero bound means none
+				if level <= severity.Trace {
+					return fmt.Errorf("no severity is below %s", match[2])
+				}
+				filter.MaxSeverity = int(level) - 1
+			case "=":
+				filter.MinSeverity = int(level)
+				filter.MaxSeverity = int(level)
+			}
+		}
+	}
+
+	return nil
+}
+
 // getLogByID returns a log by its ID
 func (a *API) getLogByID(c *gin.Context) {
 	id := c.Param("id")
@@ -76,13 +241,363 @@ func (a *API) getLogByID(c *gin.Context) {
 	c.JSON(http.StatusOK, log)
 }
 
-// getStatus returns the latest ingestion status
+// getLogHistory returns the versions of the upstream record a log was
+// ingested from, most recent first
+func (a *API) getLogHistory(c *gin.Context) {
+	id := c.Param("id")
+	log, err := a.storage.GetPostByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	versions, err := a.storage.GetHistory(c.Request.Context(), log.Source, log.PostID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// getStatus returns the ingestion status of every source, degraded when
+// any source breaches its freshness SLO, and the replicas ingesting them
 func (a *API) getStatus(c *gin.Context) {
-	status, err := a.tracker.GetLatestStatus(c.Request.Context())
+	status, err := a.tracker.GetStatusRollup(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if a.leader != nil {
+		status.Leader, err = a.leader.Leader(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if a.cluster != nil {
+		status.Members, err = a.cluster.Members(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, status)
 }
+
+// getRuns returns the runs matching the query parameters, most recent first
+func (a *API) getRuns(c *gin.Context) {
+	filter, err := parseRunFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.Limit, err = parseLimit(c, 50); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if value := c.Query("offset"); value != "" {
+		filter.Offset, err = strconv.Atoi(value)
+		if err != nil || filter.Offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid offset: %q", value)})
+			return
+		}
+	}
+
+	runs, err := a.tracker.GetRuns(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+// getRun returns a run by its ID
+func (a *API) getRun(c *gin.Context) {
+	run, err := a.tracker.GetRun(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, tracker.ErrRunNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// getRunStats returns the statistics of the runs over a window, the last
+// 24 hours unless from or window is given
+func (a *API) getRunStats(c *gin.Context) {
+	filter, err := parseRunFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if filter.From.IsZero() {
+		window := 24 * time.Hour
+		if value := c.Query("window"); value != "" {
+			window, err = time.ParseDuration(value)
+			if err != nil || window <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid window: %q", value)})
+				return
+			}
+		}
+		end := filter.To
+		if end.IsZero() {
+			end = time.Now().UTC()
+		}
+		filter.From = end.Add(-window)
+	}
+
+	stats, err := a.tracker.GetRunStats(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// getSkippedRuns returns the most recent runs skipped because the previous
+// run of their source was still going
+func (a *API) getSkippedRuns(c *gin.Context) {
+	limit, err := parseLimit(c, 50)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	skipped, err := a.tracker.GetSkippedRuns(c.Request.Context(), c.Query("source"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, skipped)
+}
+
+// parseRunFilter builds a run filter from the source, success, anomalous,
+// from and to query parameters
+func parseRunFilter(c *gin.Context) (models.RunFilter, error) {
+	filter := models.RunFilter{Source: c.Query("source")}
+
+	if value := c.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid success parameter: %q", value)
+		}
+		filter.Success = &success
+	}
+
+	if value := c.Query("anomalous"); value != "" {
+		anomalous, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid anomalous parameter: %q", value)
+		}
+		filter.Anomalous = &anomalous
+	}
+
+	if from := c.Query("from"); from != "" {
+		ts, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from parameter: %w", err)
+		}
+		filter.From = ts
+	}
+
+	if to := c.Query("to"); to != "" {
+		ts, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to parameter: %w", err)
+		}
+		filter.To = ts
+	}
+
+	return filter, nil
+}
+
+// getRejected returns the most recent rejected records
+func (a *API) getRejected(c *gin.Context) {
+	limit, err := parseLimit(c, 100)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	records, err := a.storage.GetRejected(c.Request.Context(), c.Query("source"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// getSourceSchema returns the schema baseline, latest profile and drift
+// events of a source
+func (a *API) getSourceSchema(c *gin.Context) {
+	limit, err := parseLimit(c, 20)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := c.Param("name")
+	schema, err := a.storage.GetSchema(c.Request.Context(), name, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if schema == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no schema profile for source %s", name)})
+		return
+	}
+
+	c.JSON(http.StatusOK, schema)
+}
+
+// getTemplates returns the mined message templates, most frequent first
+func (a *API) getTemplates(c *gin.Context) {
+	limit, err := parseLimit(c, 50)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	templates, err := a.storage.GetTemplates(c.Request.Context(), c.Query("source"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// postReprocess runs the stored posts of a source and time range through
+// the current pipeline again
+func (a *API) postReprocess(c *gin.Context) {
+	var request models.ReprocessRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if request.Source == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source is required"})
+		return
+	}
+	if !request.From.IsZero() && !request.To.IsZero() && !request.From.Before(request.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	result, err := a.reprocessor.Reprocess(c.Request.Context(), request)
+	if errors.Is(err, reprocess.ErrUnknownSource) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// postJob queues a backfill job
+func (a *API) postJob(c *gin.Context) {
+	var request models.JobRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	job, err := a.jobs.Create(c.Request.Context(), request)
+	if errors.Is(err, jobs.ErrInvalidRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, jobs.ErrUnknownSource) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// getJobs returns the jobs matching the source and state query parameters,
+// most recent first
+func (a *API) getJobs(c *gin.Context) {
+	limit, err := parseLimit(c, 50)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := models.JobFilter{Source: c.Query("source"), State: c.Query("state"), Limit: limit}
+	list, err := a.jobs.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// getJob returns a job with its progress
+func (a *API) getJob(c *gin.Context) {
+	job, err := a.jobs.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// cancelJob cancels a pending job, or stops a running one after its
+// current chunk
+func (a *API) cancelJob(c *gin.Context) {
+	job, err := a.jobs.Cancel(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, jobs.ErrJobFinished) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "job": job})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// parseLimit reads the limit query parameter
+func parseLimit(c *gin.Context, defaultLimit int) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid limit: %q", value)
+	}
+	return limit, nil
+}
@@ -1,23 +1,37 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tiwariayush700/log-ingestion-service/internal/jobs"
 	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"github.com/tiwariayush700/log-ingestion-service/internal/reprocess"
+	"github.com/tiwariayush700/log-ingestion-service/internal/tracker"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // MockStorage is a mock implementation of the storage interface
 type MockStorage struct {
-	posts []models.EnrichedPost
+	posts      []models.EnrichedPost
+	rejected   []models.RejectedRecord
+	schemas    map[string]*models.SourceSchema
+	templates  []models.LogTemplate
+	versions   []models.PostVersion
+	lastFilter models.LogFilter
+	lastSource string
+	lastLimit  int
 }
 
-func (m *MockStorage) GetPosts(ctx interface{}) ([]models.EnrichedPost, error) {
+func (m *MockStorage) GetPosts(ctx interface{}, filter models.LogFilter) ([]models.EnrichedPost, error) {
+	m.lastFilter = filter
 	return m.posts, nil
 }
 
@@ -27,18 +41,144 @@ func (m *MockStorage) GetPostByID(ctx interface{}, id string) (models.EnrichedPo
 			return post, nil
 		}
 	}
-	return models.EnrichedPost{}, nil
+	return models.EnrichedPost{}, fmt.Errorf("failed to find post: %s", id)
+}
+
+func (m *MockStorage) GetRejected(ctx interface{}, source string, limit int) ([]models.RejectedRecord, error) {
+	m.lastSource = source
+	m.lastLimit = limit
+	return m.rejected, nil
+}
+
+func (m *MockStorage) GetSchema(ctx interface{}, source string, limit int) (*models.SourceSchema, error) {
+	m.lastLimit = limit
+	return m.schemas[source], nil
+}
+
+func (m *MockStorage) GetTemplates(ctx interface{}, source string, limit int) ([]models.LogTemplate, error) {
+	m.lastSource = source
+	m.lastLimit = limit
+	return m.templates, nil
+}
+
+func (m *MockStorage) GetHistory(ctx interface{}, source string, postID int) ([]models.PostVersion, error) {
+	var versions []models.PostVersion
+	for _, version := range m.versions {
+		if version.Source == source && version.PostID == postID {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
 }
 
 // MockTracker is a mock implementation of the tracker interface
 type MockTracker struct {
-	status models.IngestStatus
+	status     models.StatusRollup
+	runs       []models.IngestStatus
+	lastFilter models.RunFilter
 }
 
-func (m *MockTracker) GetLatestStatus(ctx interface{}) (models.IngestStatus, error) {
+func (m *MockTracker) GetStatusRollup(ctx interface{}) (models.StatusRollup, error) {
 	return m.status, nil
 }
 
+func (m *MockTracker) GetRuns(ctx interface{}, filter models.RunFilter) ([]models.IngestStatus, error) {
+	m.lastFilter = filter
+	return m.runs, nil
+}
+
+func (m *MockTracker) GetRun(ctx interface{}, id string) (models.IngestStatus, error) {
+	for _, run := range m.runs {
+		if run.ID.Hex() == id {
+			return run, nil
+		}
+	}
+	return models.IngestStatus{}, fmt.Errorf("%w: %s", tracker.ErrRunNotFound, id)
+}
+
+func (m *MockTracker) GetSkippedRuns(ctx interface{}, source string, limit int) ([]models.SkippedRun, error) {
+	m.lastFilter = models.RunFilter{Source: source, Limit: limit}
+	return []models.SkippedRun{{Source: "test_source", Policy: "skip", Reason: "previous run still running"}}, nil
+}
+
+func (m *MockTracker) GetRunStats(ctx interface{}, filter models.RunFilter) (models.RunStats, error) {
+	m.lastFilter = filter
+	return models.RunStats{Source: filter.Source, From: filter.From, To: filter.To, Runs: 4, Succeeded: 3, SuccessRate: 0.75}, nil
+}
+
+// MockReprocessor is a mock implementation of the reprocessor interface
+type MockReprocessor struct {
+	lastRequest models.ReprocessRequest
+}
+
+func (m *MockReprocessor) Reprocess(ctx context.Context, request models.ReprocessRequest) (models.ReprocessResult, error) {
+	m.lastRequest = request
+	if request.Source != "test_source" {
+		return models.ReprocessResult{}, fmt.Errorf("%w: %s", reprocess.ErrUnknownSource, request.Source)
+	}
+	return models.ReprocessResult{Source: request.Source, Read: 3, Replayed: 2, Stored: 2, Deleted: 3}, nil
+}
+
+// MockLeader is a mock implementation of the leader interface
+type MockLeader struct {
+	lease *models.Lease
+}
+
+func (m *MockLeader) Leader(ctx interface{}) (*models.Lease, error) {
+	return m.lease, nil
+}
+
+// MockCluster is a mock implementation of the cluster interface
+type MockCluster struct {
+	members []models.Member
+}
+
+func (m *MockCluster) Members(ctx interface{}) ([]models.Member, error) {
+	return m.members, nil
+}
+
+// MockJobs is a mock implementation of the jobs interface, holding a
+// running job and a succeeded one
+type MockJobs struct {
+	jobs       map[string]models.Job
+	lastFilter models.JobFilter
+}
+
+func (m *MockJobs) Create(ctx context.Context, request models.JobRequest) (models.Job, error) {
+	if request.Source != "test_source" {
+		return models.Job{}, fmt.Errorf("%w: %s", jobs.ErrUnknownSource, request.Source)
+	}
+	if request.Chunk == "" {
+		return models.Job{}, fmt.Errorf("%w: invalid chunk", jobs.ErrInvalidRequest)
+	}
+	return models.Job{Type: models.JobBackfill, Source: request.Source, From: request.From, To: request.To, Chunk: request.Chunk, State: models.JobPending}, nil
+}
+
+func (m *MockJobs) Get(ctx context.Context, id string) (models.Job, error) {
+	job, ok := m.jobs[id]
+	if !ok {
+		return models.Job{}, fmt.Errorf("%w: %s", jobs.ErrJobNotFound, id)
+	}
+	return job, nil
+}
+
+func (m *MockJobs) List(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	m.lastFilter = filter
+	return []models.Job{m.jobs["job-1"]}, nil
+}
+
+func (m *MockJobs) Cancel(ctx context.Context, id string) (models.Job, error) {
+	job, err := m.Get(ctx, id)
+	if err != nil {
+		return job, err
+	}
+	if job.State != models.JobRunning {
+		return job, fmt.Errorf("%w: %s is %s", jobs.ErrJobFinished, id, job.State)
+	}
+	job.CancelRequested = true
+	return job, nil
+}
+
 func setupTestAPI() (*API, *MockStorage, *MockTracker) {
 	gin.SetMode(gin.TestMode)
 
@@ -56,23 +196,78 @@ func setupTestAPI() (*API, *MockStorage, *MockTracker) {
 				Source:     "test_source",
 			},
 		},
+		rejected: []models.RejectedRecord{
+			{
+				Source:     "test_source",
+				Reason:     models.RejectedSchema,
+				Payload:    map[string]interface{}{"id": 2},
+				Errors:     []string{"/: missing properties: 'title'"},
+				RejectedAt: time.Now().UTC(),
+			},
+		},
+		versions: []models.PostVersion{
+			{
+				Source:  "test_source",
+				PostID:  1,
+				Version: 2,
+				Changes: []models.FieldDiff{{Path: "title", Before: "Old Title", After: "Test Title"}},
+			},
+			{Source: "test_source", PostID: 1, Version: 1},
+			{Source: "test_source", PostID: 2, Version: 1},
+		},
+		templates: []models.LogTemplate{
+			{ID: "a1", Source: "test_source", Template: "user <*> logged in", Count: 10},
+			{ID: "b2", Source: "test_source", Template: "disk <*> full", Count: 2},
+		},
+		schemas: map[string]*models.SourceSchema{
+			"test_source": {
+				Source: "test_source",
+				Latest: models.SchemaProfile{
+					Records: 1,
+					Fields:  []models.FieldProfile{{Path: "title", Types: []string{"string"}}},
+				},
+				Drift: []models.DriftEvent{
+					{
+						Source:     "test_source",
+						DetectedAt: time.Now().UTC(),
+						Changes:    []models.SchemaChange{{Path: "body", Kind: models.FieldRemoved}},
+					},
+				},
+			},
+		},
 	}
 
 	// Create mock tracker with test data
+	runID, _ := primitive.ObjectIDFromHex("6f50c31f5dc4b6d5c8456e77")
 	mockTracker := &MockTracker{
-		status: models.IngestStatus{
-			ID:        primitive.NewObjectID(),
-			Timestamp: time.Now().UTC(),
-			Success:   true,
-			Count:     1,
+		status: models.StatusRollup{
+			Status:    models.StatusDegraded,
+			CheckedAt: time.Now().UTC(),
+			Sources: []models.SourceStatus{
+				{Source: "other_source", ConsecutiveFailures: 3, FreshnessLagSeconds: 3600, MaxStalenessSeconds: 900, Breached: true},
+				{Source: "test_source", FreshnessLagSeconds: 60, MaxStalenessSeconds: 900},
+			},
+		},
+		runs: []models.IngestStatus{
+			{ID: runID, Source: "test_source", State: models.RunSucceeded, Success: true, Count: 1},
 		},
 	}
 
 	// Create API with mock dependencies
 	api := &API{
-		router:  gin.New(),
-		storage: mockStorage,
-		tracker: mockTracker,
+		router:      gin.New(),
+		storage:     mockStorage,
+		tracker:     mockTracker,
+		reprocessor: &MockReprocessor{},
+		leader:      &MockLeader{lease: &models.Lease{Name: "ingestion", Holder: "replica-1"}},
+		cluster: &MockCluster{members: []models.Member{
+			{ID: "replica-1", Sources: []string{"other_source"}},
+			{ID: "replica-2", Sources: []string{"test_source"}},
+		}},
+		jobs: &MockJobs{jobs: map[string]models.Job{
+			"job-1": {Source: "test_source", State: models.JobRunning, ChunksDone: 2, ChunksTotal: 5},
+			"job-2": {Source: "test_source", State: models.JobSucceeded, ChunksDone: 5, ChunksTotal: 5},
+		}},
 	}
 	api.setupRoutes()
 
@@ -108,6 +303,84 @@ func TestGetLogs(t *testing.T) {
 	}
 }
 
+func TestGetLogsEventTimeFilter(t *testing.T) {
+	api, mockStorage, _ := setupTestAPI()
+
+	// Create a test request with a time range and descending sort
+	req := httptest.NewRequest(http.MethodGet, "/api/logs?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z&sort=-event_time", nil)
+	resp := httptest.NewRecorder()
+
+	// Serve the request
+	api.router.ServeHTTP(resp, req)
+
+	// Check response
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	filter := mockStorage.lastFilter
+	if !filter.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected from 2024-01-01, got %v", filter.From)
+	}
+
+	if !filter.To.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected to 2024-01-02, got %v", filter.To)
+	}
+
+	if filter.SortField != "event_time" || !filter.SortDesc {
+		t.Errorf("Expected descending sort on event_time, got %q (desc=%v)", filter.SortField, filter.SortDesc)
+	}
+}
+
+func TestGetLogsSeverityFilter(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantMin int
+		wantMax int
+	}{
+		{"severity>=warn", 13, 0},
+		{"severity>warn", 14, 0},
+		{"severity<=info", 0, 9},
+		{"severity<error", 0, 16},
+		{"severity=ERR", 17, 17},
+		{"severity>=warn&severity<=error", 13, 17},
+	}
+
+	for _, tt := range tests {
+		api, mockStorage, _ := setupTestAPI()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/logs?"+tt.query, nil)
+		resp := httptest.NewRecorder()
+
+		api.router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Expected status code %d for %q, got %d", http.StatusOK, tt.query, resp.Code)
+			continue
+		}
+
+		filter := mockStorage.lastFilter
+		if filter.MinSeverity != tt.wantMin || filter.MaxSeverity != tt.wantMax {
+			t.Errorf("Expected severity range [%d, %d] for %q, got [%d, %d]", tt.wantMin, tt.wantMax, tt.query, filter.MinSeverity, filter.MaxSeverity)
+		}
+	}
+}
+
+func TestGetLogsInvalidFilter(t *testing.T) {
+	api, _, _ := setupTestAPI()
+
+	for _, query := range []string{"from=yesterday", "to=2024-13-01", "sort=title", "severity>=loud", "severity~warn", "severity<trace"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/logs?"+query, nil)
+		resp := httptest.NewRecorder()
+
+		api.router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for %q, got %d", http.StatusBadRequest, query, resp.Code)
+		}
+	}
+}
+
 func TestGetLogByID(t *testing.T) {
 	api, _, _ := setupTestAPI()
 
@@ -148,16 +421,397 @@ func TestGetStatus(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
 	}
 
-	var status models.IngestStatus
+	var status models.StatusRollup
 	if err := json.Unmarshal(resp.Body.Bytes(), &status); err != nil {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if !status.Success {
-		t.Error("Expected success to be true")
+	if status.Status != models.StatusDegraded {
+		t.Errorf("Expected status %s, got %s", models.StatusDegraded, status.Status)
+	}
+
+	if len(status.Sources) != 2 || !status.Sources[0].Breached || status.Sources[0].ConsecutiveFailures != 3 {
+		t.Errorf("Expected the breaching source first, got %+v", status.Sources)
+	}
+
+	if status.Leader == nil || status.Leader.Holder != "replica-1" {
+		t.Errorf("Expected replica-1 to lead, got %+v", status.Leader)
+	}
+
+	if len(status.Members) != 2 || status.Members[1].Sources[0] != "test_source" {
+		t.Errorf("Expected test_source to be ingested by replica-2, got %+v", status.Members)
+	}
+}
+
+func TestGetRejected(t *testing.T) {
+	api, mockStorage, _ := setupTestAPI()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/rejected?source=test_source&limit=5", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var records []models.RejectedRecord
+	if err := json.Unmarshal(resp.Body.Bytes(), &records); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(records) != 1 || len(records[0].Errors) != 1 {
+		t.Errorf("Expected 1 rejected record with its errors, got %+v", records)
+	}
+	if mockStorage.lastSource != "test_source" || mockStorage.lastLimit != 5 {
+		t.Errorf("Expected source test_source and limit 5, got %s and %d", mockStorage.lastSource, mockStorage.lastLimit)
+	}
+
+	// Invalid limit
+	req = httptest.NewRequest(http.MethodGet, "/api/rejected?limit=0", nil)
+	resp = httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func TestGetSourceSchema(t *testing.T) {
+	api, mockStorage, _ := setupTestAPI()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sources/test_source/schema", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var schema models.SourceSchema
+	if err := json.Unmarshal(resp.Body.Bytes(), &schema); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(schema.Latest.Fields) != 1 || len(schema.Drift) != 1 {
+		t.Errorf("Expected 1 field and 1 drift event, got %+v", schema)
+	}
+	if mockStorage.lastLimit != 20 {
+		t.Errorf("Expected default limit 20, got %d", mockStorage.lastLimit)
+	}
+
+	// Unknown source
+	req = httptest.NewRequest(http.MethodGet, "/api/sources/unknown/schema", nil)
+	resp = httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestGetTemplates(t *testing.T) {
+	api, mockStorage, _ := setupTestAPI()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/templates?source=test_source", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var templates []models.LogTemplate
+	if err := json.Unmarshal(resp.Body.Bytes(), &templates); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(templates) != 2 || templates[0].Count != 10 {
+		t.Errorf("Expected 2 templates, got %+v", templates)
+	}
+	if mockStorage.lastSource != "test_source" || mockStorage.lastLimit != 50 {
+		t.Errorf("Expected source test_source and limit 50, got %s and %d", mockStorage.lastSource, mockStorage.lastLimit)
+	}
+}
+
+func TestGetSkippedRuns(t *testing.T) {
+	api, _, mockTracker := setupTestAPI()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/runs/skipped?source=test_source&limit=5", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+	var skipped []models.SkippedRun
+	if err := json.Unmarshal(resp.Body.Bytes(), &skipped); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0].Reason != "previous run still running" {
+		t.Errorf("Expected the skipped run, got %+v", skipped)
+	}
+	if mockTracker.lastFilter.Source != "test_source" || mockTracker.lastFilter.Limit != 5 {
+		t.Errorf("Expected source test_source and limit 5, got %+v", mockTracker.lastFilter)
+	}
+}
+
+func TestPostReprocess(t *testing.T) {
+	api, _, _ := setupTestAPI()
+
+	body := `{"source":"test_source","from":"2023-01-01T00:00:00Z","to":"2023-01-02T00:00:00Z","collection":"posts_v2"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/reprocess", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var result models.ReprocessResult
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if result.Replayed != 2 || result.Stored != 2 {
+		t.Errorf("Expected 2 replayed and stored posts, got %+v", result)
+	}
+	request := api.reprocessor.(*MockReprocessor).lastRequest
+	if request.Collection != "posts_v2" || !request.From.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the request to be passed on, got %+v", request)
+	}
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"from":"2023-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{`{"source":"test_source","from":"2023-01-02T00:00:00Z","to":"2023-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{`{"source":"test_source","from":"yesterday"}`, http.StatusBadRequest},
+		{`{"source":"unknown"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/reprocess", strings.NewReader(tt.body))
+		resp := httptest.NewRecorder()
+		api.router.ServeHTTP(resp, req)
+		if resp.Code != tt.code {
+			t.Errorf("Expected status code %d for %s, got %d", tt.code, tt.body, resp.Code)
+		}
+	}
+}
+
+func TestPostJob(t *testing.T) {
+	api, _, _ := setupTestAPI()
+
+	body := `{"source":"test_source","from":"2023-01-01T00:00:00Z","to":"2023-01-02T00:00:00Z","chunk":"1h"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, resp.Code)
+	}
+	var job models.Job
+	if err := json.Unmarshal(resp.Body.Bytes(), &job); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if job.State != models.JobPending || job.Chunk != "1h" {
+		t.Errorf("Expected a pending job, got %+v", job)
+	}
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"source":"test_source","from":"yesterday"}`, http.StatusBadRequest},
+		{`{"source":"test_source","from":"2023-01-01T00:00:00Z","to":"2023-01-02T00:00:00Z"}`, http.StatusBadRequest},
+		{`{"source":"unknown","chunk":"1h"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(tt.body))
+		resp := httptest.NewRecorder()
+		api.router.ServeHTTP(resp, req)
+		if resp.Code != tt.code {
+			t.Errorf("Expected status code %d for %s, got %d", tt.code, tt.body, resp.Code)
+		}
 	}
+}
+
+func TestGetJobs(t *testing.T) {
+	api, _, _ := setupTestAPI()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs?source=test_source&state=running&limit=10", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
 
-	if status.Count != 1 {
-		t.Errorf("Expected count 1, got %d", status.Count)
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+	want := models.JobFilter{Source: "test_source", State: "running", Limit: 10}
+	if filter := api.jobs.(*MockJobs).lastFilter; filter != want {
+		t.Errorf("Expected filter %+v, got %+v", want, filter)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/jobs/job-1", nil)
+	resp = httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	var job models.Job
+	if err := json.Unmarshal(resp.Body.Bytes(), &job); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if resp.Code != http.StatusOK || job.ChunksDone != 2 || job.ChunksTotal != 5 {
+		t.Errorf("Expected the progress of job-1, got %d: %+v", resp.Code, job)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/jobs/job-3", nil)
+	resp = httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	api, _, _ := setupTestAPI()
+
+	tests := []struct {
+		id   string
+		code int
+	}{
+		{"job-1", http.StatusOK},
+		{"job-2", http.StatusConflict},
+		{"job-3", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/jobs/"+tt.id+"/cancel", nil)
+		resp := httptest.NewRecorder()
+		api.router.ServeHTTP(resp, req)
+		if resp.Code != tt.code {
+			t.Errorf("Expected status code %d for %s, got %d", tt.code, tt.id, resp.Code)
+		}
+	}
+}
+
+func TestGetLogHistory(t *testing.T) {
+	api, _, _ := setupTestAPI()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/logs/5f50c31f5dc4b6d5c8456e77/history", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var versions []models.PostVersion
+	if err := json.Unmarshal(resp.Body.Bytes(), &versions); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || len(versions[0].Changes) != 1 {
+		t.Errorf("Expected the 2 versions of post 1, got %+v", versions)
+	}
+
+	// Unknown log
+	req = httptest.NewRequest(http.MethodGet, "/api/logs/5f50c31f5dc4b6d5c8456e78/history", nil)
+	resp = httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestGetRuns(t *testing.T) {
+	api, _, mockTracker := setupTestAPI()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/runs?source=test_source&success=false&anomalous=true&from=2023-01-01T00:00:00Z&limit=10&offset=20", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var runs []models.IngestStatus
+	if err := json.Unmarshal(resp.Body.Bytes(), &runs); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Errorf("Expected 1 run, got %d", len(runs))
+	}
+
+	filter := mockTracker.lastFilter
+	if filter.Source != "test_source" || filter.Success == nil || *filter.Success || filter.Limit != 10 || filter.Offset != 20 {
+		t.Errorf("Expected the filter to be parsed, got %+v", filter)
+	}
+	if filter.Anomalous == nil || !*filter.Anomalous {
+		t.Errorf("Expected the filter to be parsed, got %+v", filter)
+	}
+	if !filter.From.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected from 2023-01-01, got %v", filter.From)
+	}
+
+	for _, query := range []string{"success=maybe", "anomalous=maybe", "offset=-1", "from=yesterday", "limit=0"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/runs?"+query, nil)
+		resp := httptest.NewRecorder()
+		api.router.ServeHTTP(resp, req)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, query, resp.Code)
+		}
+	}
+}
+
+func TestGetRun(t *testing.T) {
+	api, _, _ := setupTestAPI()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/runs/6f50c31f5dc4b6d5c8456e77", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var run models.IngestStatus
+	if err := json.Unmarshal(resp.Body.Bytes(), &run); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if run.State != models.RunSucceeded {
+		t.Errorf("Expected a succeeded run, got %+v", run)
+	}
+
+	// Unknown run
+	req = httptest.NewRequest(http.MethodGet, "/api/runs/6f50c31f5dc4b6d5c8456e78", nil)
+	resp = httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestGetRunStats(t *testing.T) {
+	api, _, mockTracker := setupTestAPI()
+
+	before := time.Now().UTC()
+	req := httptest.NewRequest(http.MethodGet, "/api/runs/stats?source=test_source&window=1h", nil)
+	resp := httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var stats models.RunStats
+	if err := json.Unmarshal(resp.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if stats.Runs != 4 || stats.SuccessRate != 0.75 {
+		t.Errorf("Expected 4 runs with success rate 0.75, got %+v", stats)
+	}
+
+	// The window ends now
+	from := mockTracker.lastFilter.From
+	if from.Before(before.Add(-time.Hour-time.Second)) || from.After(time.Now().Add(-time.Hour)) {
+		t.Errorf("Expected the window to start an hour ago, got %v", from)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/runs/stats?window=soon", nil)
+	resp = httptest.NewRecorder()
+	api.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.Code)
 	}
 }
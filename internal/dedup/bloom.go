@@ -0,0 +1,103 @@
+package dedup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// Bloom is a fixed size Bloom filter
+type Bloom struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+// NewBloom creates a Bloom filter sized for capacity keys at the given false
+// positive rate
+func NewBloom(capacity int, falsePositiveRate float64) *Bloom {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.001
+	}
+
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &Bloom{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Add inserts a key into the filter
+func (b *Bloom) Add(key string) {
+	h1, h2 := hashes(key)
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+// Contains reports whether the key may have been added
+func (b *Bloom) Contains(key string) bool {
+	h1, h2 := hashes(key)
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalBinary encodes the filter
+func (b *Bloom) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, v := range append([]uint64{b.m, b.k}, b.bits...) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a filter encoded with MarshalBinary
+func (b *Bloom) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 || len(data)%8 != 0 {
+		return errors.New("invalid bloom filter encoding")
+	}
+
+	words := make([]uint64, len(data)/8)
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, words); err != nil {
+		return fmt.Errorf("failed to decode bloom filter: %w", err)
+	}
+
+	m, k := words[0], words[1]
+	if m == 0 || k == 0 || uint64(len(words)-2) != (m+63)/64 {
+		return errors.New("invalid bloom filter encoding")
+	}
+
+	b.m, b.k, b.bits = m, k, words[2:]
+	return nil
+}
+
+// hashes derives the two base hashes used for double hashing
+func hashes(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:]) | 1 // keep the stride odd
+	return h1, h2
+}
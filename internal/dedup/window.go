@@ -0,0 +1,191 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Window remembers the keys seen during a sliding time window. Checking a
+// key and recording it are separate, so the keys of records are only
+// committed once the records are stored.
+type Window interface {
+	// Contains reports whether the key was committed within the window
+	Contains(ctx context.Context, key string) (bool, error)
+	// Commit records the keys in the window. Keys may repeat.
+	Commit(ctx context.Context, keys []string) error
+}
+
+// StateStore persists opaque state blobs by name
+type StateStore interface {
+	LoadState(ctx context.Context, name string) ([]byte, error)
+	SaveState(ctx context.Context, name string, data []byte) error
+}
+
+// BloomWindow is a Window backed by two rotating Bloom filters. Keys are
+// remembered for at least half and at most the full window, and a key may
+// be reported as seen by mistake at the configured false positive rate.
+type BloomWindow struct {
+	mu sync.Mutex
+	// commitMu serializes commits, each reloading and saving the window
+	commitMu  sync.Mutex
+	name      string
+	store     StateStore
+	window    time.Duration
+	capacity  int
+	fpRate    float64
+	current   *Bloom
+	previous  *Bloom
+	rotatedAt time.Time
+	now       func() time.Time
+}
+
+// bloomState is the persisted form of a BloomWindow
+type bloomState struct {
+	RotatedAt time.Time
+	Current   []byte
+	Previous  []byte
+}
+
+// NewBloomWindow creates a BloomWindow and restores its state from store
+func NewBloomWindow(ctx context.Context, name string, store StateStore, window time.Duration, capacity int, falsePositiveRate float64) (*BloomWindow, error) {
+	w := &BloomWindow{
+		name:     name,
+		store:    store,
+		window:   window,
+		capacity: capacity,
+		fpRate:   falsePositiveRate,
+		now:      time.Now,
+	}
+	w.reset()
+
+	if err := w.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Reload replaces the filters with the ones last persisted, e.g. by the
+// replica that ran the source before
+func (w *BloomWindow) Reload(ctx context.Context) error {
+	data, err := w.store.LoadState(ctx, w.name)
+	if err != nil {
+		return fmt.Errorf("failed to load dedup window: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if data == nil {
+		w.reset()
+		return nil
+	}
+	return w.restore(data)
+}
+
+// Contains reports whether the key was committed within the window
+func (w *BloomWindow) Contains(ctx context.Context, key string) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rotate()
+	return w.current.Contains(key) || w.previous.Contains(key), nil
+}
+
+// Commit records the keys in the window and persists it. The persisted
+// filters are reloaded first so the keys committed by other replicas, such
+// as one running a backfill, are kept.
+func (w *BloomWindow) Commit(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	w.commitMu.Lock()
+	defer w.commitMu.Unlock()
+
+	if err := w.Reload(ctx); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.rotate()
+	for _, key := range keys {
+		w.current.Add(key)
+	}
+	w.mu.Unlock()
+
+	return w.Flush(ctx)
+}
+
+// Flush persists the filters so the window survives restarts
+func (w *BloomWindow) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	state := bloomState{RotatedAt: w.rotatedAt}
+	var err error
+	if state.Current, err = w.current.MarshalBinary(); err == nil {
+		state.Previous, err = w.previous.MarshalBinary()
+	}
+	w.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode dedup window: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state); err != nil {
+		return fmt.Errorf("failed to encode dedup window: %w", err)
+	}
+
+	if err := w.store.SaveState(ctx, w.name, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to save dedup window: %w", err)
+	}
+	return nil
+}
+
+// rotate starts a new generation every half window
+func (w *BloomWindow) rotate() {
+	now := w.now()
+	elapsed := now.Sub(w.rotatedAt)
+	switch {
+	case elapsed >= w.window:
+		// Both generations are older than the window
+		w.reset()
+	case elapsed >= w.window/2:
+		w.previous = w.current
+		w.current = NewBloom(w.capacity, w.fpRate)
+		w.rotatedAt = now
+	}
+}
+
+// reset empties both generations
+func (w *BloomWindow) reset() {
+	w.current = NewBloom(w.capacity, w.fpRate)
+	w.previous = NewBloom(w.capacity, w.fpRate)
+	w.rotatedAt = w.now()
+}
+
+// restore loads persisted filters, discarding them if the sizing changed
+func (w *BloomWindow) restore(data []byte) error {
+	var state bloomState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return fmt.Errorf("failed to decode dedup window: %w", err)
+	}
+
+	current, previous := &Bloom{}, &Bloom{}
+	if err := current.UnmarshalBinary(state.Current); err != nil {
+		return err
+	}
+	if err := previous.UnmarshalBinary(state.Previous); err != nil {
+		return err
+	}
+
+	if fresh := NewBloom(w.capacity, w.fpRate); current.m != fresh.m || current.k != fresh.k {
+		w.reset()
+		return nil
+	}
+
+	w.current, w.previous, w.rotatedAt = current, previous, state.RotatedAt
+	return nil
+}
@@ -0,0 +1,157 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory StateStore
+type memoryStore struct {
+	states map[string][]byte
+}
+
+func (m *memoryStore) LoadState(ctx context.Context, name string) ([]byte, error) {
+	return m.states[name], nil
+}
+
+func (m *memoryStore) SaveState(ctx context.Context, name string, data []byte) error {
+	m.states[name] = data
+	return nil
+}
+
+func TestBloom(t *testing.T) {
+	bloom := NewBloom(1000, 0.01)
+
+	for i := 0; i < 1000; i++ {
+		bloom.Add(fmt.Sprintf("key-%d", i))
+	}
+
+	// Added keys are always reported
+	for i := 0; i < 1000; i++ {
+		if !bloom.Contains(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("Expected key-%d to be contained", i)
+		}
+	}
+
+	// Unknown keys are reported close to the false positive rate
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if bloom.Contains(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("Expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+}
+
+func TestBloomMarshalBinary(t *testing.T) {
+	bloom := NewBloom(100, 0.01)
+	bloom.Add("hello")
+
+	data, err := bloom.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to marshal bloom filter: %v", err)
+	}
+
+	var decoded Bloom
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Failed to unmarshal bloom filter: %v", err)
+	}
+
+	if !decoded.Contains("hello") {
+		t.Error("Expected decoded filter to contain 'hello'")
+	}
+
+	if err := decoded.UnmarshalBinary([]byte("short")); err == nil {
+		t.Error("Expected error for invalid encoding, got nil")
+	}
+}
+
+func TestBloomWindow(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{states: map[string][]byte{}}
+
+	window, err := NewBloomWindow(ctx, "test", store, time.Hour, 1000, 0.001)
+	if err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window.now = func() time.Time { return now }
+	window.reset()
+
+	if seen, _ := window.Contains(ctx, "a"); seen {
+		t.Error("Expected first sighting not to be seen")
+	}
+	if seen, _ := window.Contains(ctx, "a"); seen {
+		t.Error("Expected uncommitted key not to be seen")
+	}
+	if err := window.Commit(ctx, []string{"a"}); err != nil {
+		t.Fatalf("Failed to commit key: %v", err)
+	}
+	if seen, _ := window.Contains(ctx, "a"); !seen {
+		t.Error("Expected committed key to be seen")
+	}
+
+	// After half a window the key moves to the previous generation
+	now = now.Add(40 * time.Minute)
+	if seen, _ := window.Contains(ctx, "a"); !seen {
+		t.Error("Expected key to still be seen after rotation")
+	}
+
+	// Committed keys are persisted and restored by a new window
+	restored, err := NewBloomWindow(ctx, "test", store, time.Hour, 1000, 0.001)
+	if err != nil {
+		t.Fatalf("Failed to restore window: %v", err)
+	}
+	restored.now = func() time.Time { return now }
+	if seen, _ := restored.Contains(ctx, "a"); !seen {
+		t.Error("Expected restored window to have seen the key")
+	}
+
+	// Once a full window has passed the key is forgotten
+	now = now.Add(2 * time.Hour)
+	if seen, _ := restored.Contains(ctx, "a"); seen {
+		t.Error("Expected key to expire after the window")
+	}
+}
+
+func TestBloomWindowReplicas(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{states: map[string][]byte{}}
+
+	first, _ := NewBloomWindow(ctx, "test", store, time.Hour, 1000, 0.001)
+	second, _ := NewBloomWindow(ctx, "test", store, time.Hour, 1000, 0.001)
+
+	if err := first.Commit(ctx, []string{"a"}); err != nil {
+		t.Fatalf("Failed to commit key: %v", err)
+	}
+
+	// A replica taking over the source reloads the window
+	if seen, _ := second.Contains(ctx, "a"); seen {
+		t.Error("Expected the key committed by another replica to be unknown before reloading")
+	}
+	if err := second.Reload(ctx); err != nil {
+		t.Fatalf("Failed to reload window: %v", err)
+	}
+	if seen, _ := second.Contains(ctx, "a"); !seen {
+		t.Error("Expected the key committed by another replica to be seen after reloading")
+	}
+
+	// Commits keep the keys committed by the other replica
+	if err := second.Commit(ctx, []string{"b"}); err != nil {
+		t.Fatalf("Failed to commit key: %v", err)
+	}
+	if err := first.Commit(ctx, []string{"c"}); err != nil {
+		t.Fatalf("Failed to commit key: %v", err)
+	}
+	restored, _ := NewBloomWindow(ctx, "test", store, time.Hour, 1000, 0.001)
+	for _, key := range []string{"a", "b", "c"} {
+		if seen, _ := restored.Contains(ctx, key); !seen {
+			t.Errorf("Expected key %s to be persisted", key)
+		}
+	}
+}
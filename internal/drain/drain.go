@@ -0,0 +1,224 @@
+// Package drain implements the Drain online log template miner (He et al.,
+// "Drain: An Online Log Parsing Approach with Fixed Depth Tree", ICWS 2017).
+//
+// Messages are split into tokens and routed through a fixed depth tree: by
+// token count first, then by their leading tokens. The leaf holds clusters
+// of messages whose templates are compared token by token with the message;
+// the message joins the most similar cluster above the threshold, turning
+// differing tokens into wildcards, or starts a new cluster.
+package drain
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Wildcard stands for the variable parts of a template
+const Wildcard = "<*>"
+
+// Config configures a Miner
+type Config struct {
+	// Depth is the depth of the parse tree, including the root and the
+	// token count level. It must be at least 3.
+	Depth int
+	// SimThreshold is the share of matching tokens for a message to join
+	// a cluster
+	SimThreshold float64
+	// MaxChildren bounds the children of inner nodes; tokens beyond it go
+	// under the wildcard node
+	MaxChildren int
+}
+
+// DefaultConfig returns the configuration recommended by the paper
+func DefaultConfig() Config {
+	return Config{Depth: 4, SimThreshold: 0.4, MaxChildren: 100}
+}
+
+// Cluster is a group of messages sharing a template
+type Cluster struct {
+	ID     string
+	Tokens []string
+}
+
+// Template returns the template of the cluster, e.g. "user <*> logged in"
+func (c *Cluster) Template() string {
+	return strings.Join(c.Tokens, " ")
+}
+
+// node is a node of the parse tree
+type node struct {
+	children map[string]*node
+	clusters []*Cluster
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+// Miner assigns messages to templates. It is safe for concurrent use.
+type Miner struct {
+	config Config
+	// seed makes cluster IDs unique per miner, e.g. per source
+	seed string
+
+	mu   sync.Mutex
+	root *node
+}
+
+// New creates a new Miner instance. Cluster IDs are derived from the seed
+// and the first message of the cluster so they are stable across restarts.
+func New(config Config, seed string) *Miner {
+	if config.Depth < 3 {
+		config.Depth = 3
+	}
+	if config.MaxChildren < 1 {
+		config.MaxChildren = 1
+	}
+	return &Miner{config: config, seed: seed, root: newNode()}
+}
+
+// Restore adds a previously mined cluster to the tree
+func (m *Miner) Restore(id, template string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := strings.Fields(template)
+	m.addCluster(&Cluster{ID: id, Tokens: tokens}, tokens)
+}
+
+// Match assigns a message to a cluster and returns a copy of the cluster
+// and the values of the wildcard tokens of its template. changed reports
+// whether the cluster was created or its template generalized.
+func (m *Miner) Match(message string) (cluster Cluster, params []string, changed bool) {
+	tokens := strings.Fields(message)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	match := m.search(tokens)
+	if match == nil {
+		match = &Cluster{ID: m.clusterID(tokens), Tokens: append([]string(nil), tokens...)}
+		m.addCluster(match, tokens)
+		changed = true
+	} else {
+		for i, token := range tokens {
+			if match.Tokens[i] != token && match.Tokens[i] != Wildcard {
+				match.Tokens[i] = Wildcard
+				changed = true
+			}
+		}
+	}
+
+	for i, token := range match.Tokens {
+		if token == Wildcard {
+			params = append(params, tokens[i])
+		}
+	}
+
+	return Cluster{ID: match.ID, Tokens: append([]string(nil), match.Tokens...)}, params, changed
+}
+
+// search returns the most similar cluster above the threshold in the leaf
+// the tokens route to, or nil
+func (m *Miner) search(tokens []string) *Cluster {
+	current, ok := m.root.children[strconv.Itoa(len(tokens))]
+	if !ok {
+		return nil
+	}
+
+	for depth := 0; depth < m.config.Depth-2 && depth < len(tokens); depth++ {
+		next, ok := current.children[tokens[depth]]
+		if !ok {
+			next, ok = current.children[Wildcard]
+		}
+		if !ok {
+			return nil
+		}
+		current = next
+	}
+
+	var best *Cluster
+	bestSim, bestParams := -1.0, -1
+	for _, cluster := range current.clusters {
+		sim, params := similarity(cluster.Tokens, tokens)
+		if sim > bestSim || (sim == bestSim && params > bestParams) {
+			best, bestSim, bestParams = cluster, sim, params
+		}
+	}
+	if best == nil || bestSim < m.config.SimThreshold {
+		return nil
+	}
+	return best
+}
+
+// addCluster routes a new cluster to its leaf, creating the path as needed
+func (m *Miner) addCluster(cluster *Cluster, tokens []string) {
+	length := strconv.Itoa(len(tokens))
+	current, ok := m.root.children[length]
+	if !ok {
+		current = newNode()
+		m.root.children[length] = current
+	}
+
+	for depth := 0; depth < m.config.Depth-2 && depth < len(tokens); depth++ {
+		key := tokens[depth]
+		if hasDigits(key) {
+			key = Wildcard
+		}
+
+		next, ok := current.children[key]
+		if !ok {
+			if len(current.children) >= m.config.MaxChildren && key != Wildcard {
+				key = Wildcard
+				next, ok = current.children[key]
+			}
+			if !ok {
+				next = newNode()
+				current.children[key] = next
+			}
+		}
+		current = next
+	}
+
+	current.clusters = append(current.clusters, cluster)
+}
+
+// clusterID derives the ID of a new cluster
+func (m *Miner) clusterID(tokens []string) string {
+	sum := sha1.Sum([]byte(m.seed + "\x00" + strings.Join(tokens, " ")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// similarity returns the share of template tokens equal to the message
+// tokens and the number of wildcards of the template
+func similarity(template, tokens []string) (float64, int) {
+	if len(template) == 0 {
+		return 1, 0
+	}
+
+	equal, params := 0, 0
+	for i, token := range template {
+		switch {
+		case token == Wildcard:
+			params++
+		case token == tokens[i]:
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(template)), params
+}
+
+// hasDigits reports whether a token contains a digit, in which case it is
+// likely a variable such as an ID and is not used to route messages
+func hasDigits(token string) bool {
+	for _, r := range token {
+		if unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
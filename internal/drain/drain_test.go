@@ -0,0 +1,91 @@
+package drain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	miner := New(DefaultConfig(), "test")
+
+	first, params, changed := miner.Match("user 42 logged in from 10.0.0.1")
+	if !changed || len(params) != 0 {
+		t.Errorf("Expected a new cluster without params, got changed=%v params=%v", changed, params)
+	}
+	if first.Template() != "user 42 logged in from 10.0.0.1" {
+		t.Errorf("Expected the message as first template, got %q", first.Template())
+	}
+
+	second, params, changed := miner.Match("user 7 logged in from 10.0.0.2")
+	if second.ID != first.ID || !changed {
+		t.Errorf("Expected the same cluster to be generalized, got %s and %s", first.ID, second.ID)
+	}
+	if second.Template() != "user <*> logged in from <*>" {
+		t.Errorf("Expected template 'user <*> logged in from <*>', got %q", second.Template())
+	}
+	if !reflect.DeepEqual(params, []string{"7", "10.0.0.2"}) {
+		t.Errorf("Expected params [7 10.0.0.2], got %v", params)
+	}
+
+	third, params, changed := miner.Match("user 9 logged in from 10.0.0.3")
+	if third.ID != first.ID || changed || !reflect.DeepEqual(params, []string{"9", "10.0.0.3"}) {
+		t.Errorf("Expected a stable match with params, got %s changed=%v params=%v", third.ID, changed, params)
+	}
+}
+
+func TestMatchSeparatesTemplates(t *testing.T) {
+	miner := New(DefaultConfig(), "test")
+
+	login, _, _ := miner.Match("user 42 logged in")
+	logout, _, _ := miner.Match("user 42 logged out")
+	disk, _, _ := miner.Match("disk /dev/sda1 is 91% full")
+	short, _, _ := miner.Match("user 42")
+
+	// One differing token out of four is above the default threshold
+	if login.ID != logout.ID {
+		t.Errorf("Expected login and logout to share a cluster")
+	}
+	if login.ID == disk.ID || login.ID == short.ID {
+		t.Errorf("Expected messages of different shape to get their own clusters")
+	}
+}
+
+func TestMatchThreshold(t *testing.T) {
+	config := DefaultConfig()
+	config.SimThreshold = 0.9
+	miner := New(config, "test")
+
+	a, _, _ := miner.Match("connection reset by peer")
+	b, _, _ := miner.Match("connection refused by peer")
+	if a.ID == b.ID {
+		t.Errorf("Expected a strict threshold to keep the messages apart")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	miner := New(DefaultConfig(), "test")
+	cluster, _, _ := miner.Match("job 1 finished in 20ms")
+	cluster, _, _ = miner.Match("job 2 finished in 35ms")
+
+	// A new miner restored from the template keeps the ID
+	restored := New(DefaultConfig(), "test")
+	restored.Restore(cluster.ID, cluster.Template())
+
+	match, params, changed := restored.Match("job 3 finished in 12ms")
+	if match.ID != cluster.ID || changed {
+		t.Errorf("Expected the restored cluster %s, got %s (changed=%v)", cluster.ID, match.ID, changed)
+	}
+	if !reflect.DeepEqual(params, []string{"3", "12ms"}) {
+		t.Errorf("Expected params [3 12ms], got %v", params)
+	}
+}
+
+func TestMatchEmpty(t *testing.T) {
+	miner := New(DefaultConfig(), "test")
+
+	a, params, _ := miner.Match("")
+	b, _, _ := miner.Match("   ")
+	if a.ID != b.ID || a.Template() != "" || len(params) != 0 {
+		t.Errorf("Expected empty messages to share an empty template, got %+v and %+v", a, b)
+	}
+}
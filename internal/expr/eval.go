@@ -0,0 +1,359 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// node is an evaluable part of an expression
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(env Env) (interface{}, error) {
+	return n.value, nil
+}
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(env Env) (interface{}, error) {
+	value, ok := env(n.name)
+	if !ok {
+		return nil, nil
+	}
+	return value, nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(env Env) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(env Env) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit evaluation
+	if n.op == "&&" && !truthy(left) {
+		return false, nil
+	}
+	if n.op == "||" && truthy(left) {
+		return true, nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type comparisonNode struct {
+	op          string
+	left, right node
+	re          *regexp.Regexp
+}
+
+func (n *comparisonNode) eval(env Env) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		return order(n.op, left, right)
+	case "=~", "!~":
+		matched, err := n.match(left, right)
+		if err != nil {
+			return nil, err
+		}
+		return matched == (n.op == "=~"), nil
+	case "contains":
+		return contains(left, right), nil
+	case "in":
+		return contains(right, left), nil
+	case "not in":
+		return !contains(right, left), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+// match applies the regular expression on the right to the left value
+func (n *comparisonNode) match(left, right interface{}) (bool, error) {
+	if left == nil {
+		return false, nil
+	}
+
+	re := n.re
+	if re == nil {
+		pattern, ok := right.(string)
+		if !ok {
+			return false, fmt.Errorf("regular expression must be a string, got %T", right)
+		}
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, fmt.Errorf("invalid regular expression: %w", err)
+		}
+	}
+	return re.MatchString(toString(left)), nil
+}
+
+// function is a built-in function. Arguments are passed unevaluated so that
+// exists can inspect field references.
+type function func(env Env, args []node) (interface{}, error)
+
+var functions = map[string]function{
+	"lower": stringFunction(strings.ToLower),
+	"upper": stringFunction(strings.ToUpper),
+	"len": func(env Env, args []node) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("len expects 1 argument, got %d", len(args))
+		}
+		value, err := args[0].eval(env)
+		if err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case nil:
+			return float64(0), nil
+		case string:
+			return float64(len(v)), nil
+		}
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return float64(rv.Len()), nil
+		}
+		return nil, fmt.Errorf("len of %T", value)
+	},
+	"exists": func(env Env, args []node) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("exists expects 1 argument, got %d", len(args))
+		}
+		field, ok := args[0].(*fieldNode)
+		if !ok {
+			return nil, fmt.Errorf("exists expects a field name")
+		}
+		value, ok := env(field.name)
+		return ok && value != nil, nil
+	},
+}
+
+// stringFunction wraps a single argument string function
+func stringFunction(fn func(string) string) function {
+	return func(env Env, args []node) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		value, err := args[0].eval(env)
+		if err != nil || value == nil {
+			return nil, err
+		}
+		return fn(toString(value)), nil
+	}
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n *callNode) eval(env Env) (interface{}, error) {
+	value, err := n.fn(env, n.args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return value, nil
+}
+
+// truthy converts a value to a boolean: null, false, zero, empty strings
+// and empty lists are false
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if f, ok := toNumber(value); ok {
+		return f != 0
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() > 0
+	}
+	return true
+}
+
+// toNumber converts numeric values to float64
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toString formats a value for string operations
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	if f, ok := toNumber(value); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// equal compares numbers numerically and everything else by value
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			return l == r
+		}
+	}
+	if l, ok := left.(bool); ok {
+		r, ok := right.(bool)
+		return ok && l == r
+	}
+	return toString(left) == toString(right)
+}
+
+// order compares two numbers or two strings
+func order(op string, left, right interface{}) (bool, error) {
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	var cmp int
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	switch {
+	case lok && rok:
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	default:
+		ls, lok := left.(string)
+		rs, rok := right.(string)
+		if !lok || !rok {
+			return false, fmt.Errorf("cannot compare %T and %T", left, right)
+		}
+		cmp = strings.Compare(ls, rs)
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// contains reports whether a string contains a substring or a list an element
+func contains(container, item interface{}) bool {
+	switch c := container.(type) {
+	case nil:
+		return false
+	case string:
+		return item != nil && strings.Contains(c, toString(item))
+	}
+
+	rv := reflect.ValueOf(container)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if equal(rv.Index(i).Interface(), item) {
+				return true
+			}
+		}
+	case reflect.Map:
+		if key, ok := item.(string); ok && rv.Type().Key().Kind() == reflect.String {
+			return rv.MapIndex(reflect.ValueOf(key)).IsValid()
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package expr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testEnv(fields map[string]interface{}) Env {
+	return func(name string) (interface{}, bool) {
+		value, ok := fields[name]
+		return value, ok
+	}
+}
+
+func TestMatch(t *testing.T) {
+	env := testEnv(map[string]interface{}{
+		"title":           "GET /healthcheck 200",
+		"body":            "Invoice #1234 paid",
+		"userId":          7,
+		"status":          json.Number("503"),
+		"severity":        "error",
+		"attributes.team": "payments",
+		"tags":            []string{"alert", "billing"},
+		"enabled":         true,
+	})
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`title contains "healthcheck"`, true},
+		{`title contains "login"`, false},
+		{`userId == 7`, true},
+		{`userId != 7`, false},
+		{`userId >= 5 && userId < 10`, true},
+		{`status >= 500`, true},
+		{`status == "503"`, true},
+		{`severity in ["error", "fatal"]`, true},
+		{`severity not in ["error", "fatal"]`, false},
+		{`"alert" in tags`, true},
+		{`tags contains "billing"`, true},
+		{`body =~ "(?i)invoice #\d+"`, true},
+		{`body matches "^Receipt"`, false},
+		{`body !~ "^Receipt"`, true},
+		{`attributes.team == "payments" or userId == 1`, true},
+		{`not (severity == "error")`, false},
+		{`!enabled || userId > 100`, false},
+		{`missing == null`, true},
+		{`missing contains "x"`, false},
+		{`missing > 3`, false},
+		{`exists(title) and not exists(missing)`, true},
+		{`lower(severity) == "error" and upper(severity) == "ERROR"`, true},
+		{`len(tags) == 2 and len(title) > 3`, true},
+		{`'single quoted' == "single quoted"`, true},
+		{`userId > -1`, true},
+		{`true and (false or enabled)`, true},
+	}
+
+	for _, tt := range tests {
+		expression, err := Compile(tt.expression)
+		if err != nil {
+			t.Errorf("Failed to compile %q: %v", tt.expression, err)
+			continue
+		}
+
+		got, err := expression.Match(env)
+		if err != nil {
+			t.Errorf("Failed to evaluate %q: %v", tt.expression, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Expected %q to be %v, got %v", tt.expression, tt.want, got)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, source := range []string{
+		``,
+		`title ==`,
+		`title contains`,
+		`(title == "a"`,
+		`title == "unterminated`,
+		`title =~ "("`,
+		`unknown(title)`,
+		`title not "a"`,
+		`title == "a" extra`,
+		`[1, 2`,
+		`title # "a"`,
+	} {
+		if _, err := Compile(source); err == nil {
+			t.Errorf("Expected error compiling %q, got nil", source)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	expression, err := Compile(`title > 3`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+
+	if _, err := expression.Match(testEnv(map[string]interface{}{"title": true})); err == nil {
+		t.Error("Expected error comparing a boolean with a number, got nil")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/tiwariayush700/log-ingestion-service/internal/models"
@@ -16,48 +17,142 @@ type Fetcher struct {
 	endpoint string
 	client   *http.Client
 	timeout  time.Duration
+
+	// retries is the number of attempts after the first one, waiting
+	// backoff before the first retry and twice as long before each next one
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Fetcher
+type Option func(*Fetcher)
+
+// WithRetries retries requests that fail with a transport error, a 5xx or
+// a 429 status code
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(f *Fetcher) {
+		f.retries = retries
+		f.backoff = backoff
+	}
 }
 
 // New creates a new Fetcher instance
-func New(endpoint string) *Fetcher {
-	return &Fetcher{
+func New(endpoint string, opts ...Option) *Fetcher {
+	f := &Fetcher{
 		endpoint: endpoint,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 		timeout: 30 * time.Second,
 	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Stats describes the HTTP exchange of a fetch
+type Stats struct {
+	// Bytes is the size of the response body of the last attempt
+	Bytes      int64
+	StatusCode int
+	Attempts   int
 }
 
 // FetchPosts retrieves posts from the API
 func (f *Fetcher) FetchPosts(ctx context.Context) ([]models.Post, error) {
+	posts, _, err := f.Fetch(ctx)
+	return posts, err
+}
+
+// Fetch retrieves posts from the API, retrying failed attempts, and reports
+// the size, status code and number of attempts of the exchange
+func (f *Fetcher) Fetch(ctx context.Context) ([]models.Post, Stats, error) {
+	return f.FetchQuery(ctx, nil)
+}
+
+// FetchQuery retrieves posts like Fetch, adding query parameters to the
+// endpoint, e.g. to bound the range of a backfill
+func (f *Fetcher) FetchQuery(ctx context.Context, query url.Values) ([]models.Post, Stats, error) {
+	var stats Stats
+	backoff := f.backoff
+
+	endpoint, err := withQuery(f.endpoint, query)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	for {
+		stats.Attempts++
+		body, retry, err := f.attempt(ctx, endpoint, &stats)
+		if err == nil {
+			var posts []models.Post
+			if err := json.Unmarshal(body, &posts); err != nil {
+				return nil, stats, fmt.Errorf("failed to unmarshal response: %w", err)
+			}
+			return posts, stats, nil
+		}
+		if !retry || stats.Attempts > f.retries {
+			return nil, stats, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, stats, err
+		}
+		backoff *= 2
+	}
+}
+
+// withQuery adds query parameters to an endpoint, replacing those it
+// already sets
+func withQuery(endpoint string, query url.Values) (string, error) {
+	if len(query) == 0 {
+		return endpoint, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse endpoint: %w", err)
+	}
+	values := u.Query()
+	for key, value := range query {
+		values[key] = value
+	}
+	u.RawQuery = values.Encode()
+	return u.String(), nil
+}
+
+// attempt makes a single request and reports whether a failure may be retried
+func (f *Fetcher) attempt(ctx context.Context, endpoint string, stats *Stats) ([]byte, bool, error) {
 	ctx, cancel := context.WithTimeout(ctx, f.timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.endpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	resp, err := f.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch data: %w", err)
+		return nil, true, fmt.Errorf("failed to fetch data: %w", err)
 	}
 	defer resp.Body.Close()
+	stats.StatusCode = resp.StatusCode
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
+	stats.Bytes = int64(len(body))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	var posts []models.Post
-	if err := json.Unmarshal(body, &posts); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		return nil, true, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	return posts, nil
+	return body, false, nil
 }
@@ -2,9 +2,12 @@ package fetcher
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestFetchPosts(t *testing.T) {
@@ -17,7 +20,8 @@ func TestFetchPosts(t *testing.T) {
 				"userId": 1,
 				"id": 1,
 				"title": "Test Title",
-				"body": "Test Body"
+				"body": "Test Body",
+				"timestamp": 1710498600
 			}
 		]`))
 	}))
@@ -43,6 +47,11 @@ func TestFetchPosts(t *testing.T) {
 	if posts[0].Title != "Test Title" {
 		t.Errorf("Expected title 'Test Title', got '%s'", posts[0].Title)
 	}
+
+	// Fields not mapped onto Post are kept in the raw payload
+	if ts, ok := posts[0].Fields["timestamp"].(json.Number); !ok || ts.String() != "1710498600" {
+		t.Errorf("Expected raw timestamp field 1710498600, got %v", posts[0].Fields["timestamp"])
+	}
 }
 
 func TestFetchPostsError(t *testing.T) {
@@ -80,3 +89,99 @@ func TestFetchPostsInvalidJSON(t *testing.T) {
 		t.Fatal("Expected error for invalid JSON, got nil")
 	}
 }
+
+func TestFetchPostsMistypedField(t *testing.T) {
+	// Create a test server that returns a post with a string userId
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"userId": "one", "id": 1, "title": "Test Title"}, {"userId": 2, "id": 2}]`))
+	}))
+	defer server.Close()
+
+	f := New(server.URL)
+
+	// The batch still decodes and the mistyped post is flagged
+	posts, err := f.FetchPosts(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("Expected 2 posts, got %d", len(posts))
+	}
+	if posts[0].DecodeError == "" || posts[0].Title != "Test Title" {
+		t.Errorf("Expected a decode error and the remaining fields, got %+v", posts[0])
+	}
+	if posts[1].DecodeError != "" {
+		t.Errorf("Expected no decode error, got %s", posts[1].DecodeError)
+	}
+}
+
+func TestFetchRetries(t *testing.T) {
+	// The server fails twice before answering
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"id": 1}]`))
+	}))
+	defer server.Close()
+
+	f := New(server.URL, WithRetries(2, time.Millisecond))
+	posts, stats, err := f.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("Expected 1 post, got %d", len(posts))
+	}
+	if stats.Attempts != 3 || stats.StatusCode != http.StatusOK || stats.Bytes != 11 {
+		t.Errorf("Expected 3 attempts, status 200 and 11 bytes, got %+v", stats)
+	}
+
+	// Out of retries
+	calls = 0
+	f = New(server.URL, WithRetries(1, time.Millisecond))
+	_, stats, err = f.Fetch(context.Background())
+	if err == nil || stats.Attempts != 2 || stats.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected a failure after 2 attempts with status 503, got %v and %+v", err, stats)
+	}
+}
+
+func TestFetchNoRetryOnClientError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	f := New(server.URL, WithRetries(3, time.Millisecond))
+	_, stats, err := f.Fetch(context.Background())
+	if err == nil || calls != 1 || stats.Attempts != 1 {
+		t.Errorf("Expected a single failed attempt, got %v after %d calls", err, calls)
+	}
+}
+
+func TestFetchQuery(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	f := New(server.URL + "/posts?limit=100&from=0")
+	_, _, err := f.FetchQuery(context.Background(), url.Values{"from": {"2023-01-01T00:00:00Z"}, "to": {"2023-01-01T01:00:00Z"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := url.Values{"limit": {"100"}, "from": {"2023-01-01T00:00:00Z"}, "to": {"2023-01-01T01:00:00Z"}}
+	if query.Encode() != want.Encode() {
+		t.Errorf("Expected query %s, got %s", want.Encode(), query.Encode())
+	}
+}
@@ -0,0 +1,185 @@
+// Package history keeps the version history of upstream records, detecting
+// when the content of a record with a known upstream ID changes
+package history
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Store persists the versions of upstream records
+type Store interface {
+	// GetCurrentVersions returns the current version of the given upstream
+	// records of a source, leaving out the records never seen
+	GetCurrentVersions(ctx context.Context, source string, postIDs []int) ([]models.PostVersion, error)
+	// SaveVersions inserts new versions, closing the versions they replace
+	SaveVersions(ctx context.Context, versions []models.PostVersion) error
+}
+
+// Summary counts the records of a run by how they compare with their
+// current version
+type Summary struct {
+	New       int
+	Changed   int
+	Unchanged int
+}
+
+// Recorder compares the records of each run with their current version and
+// records a new version when the content changed
+type Recorder struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewRecorder creates a new Recorder instance
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Observe records the posts of a run that are new or changed. Posts without
+// an upstream ID are ignored.
+func (r *Recorder) Observe(ctx context.Context, source string, posts []models.Post) (Summary, error) {
+	var summary Summary
+
+	var ids []int
+	for _, post := range posts {
+		if _, ok := post.Fields["id"]; ok {
+			ids = append(ids, post.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return summary, nil
+	}
+
+	stored, err := r.store.GetCurrentVersions(ctx, source, ids)
+	if err != nil {
+		return summary, err
+	}
+	current := make(map[int]models.PostVersion, len(stored))
+	for _, version := range stored {
+		current[version.PostID] = version
+	}
+
+	now := r.now().UTC()
+	var versions []models.PostVersion
+	for _, post := range posts {
+		if _, ok := post.Fields["id"]; !ok {
+			continue
+		}
+
+		payload := models.Document(post.Fields)
+		hash, err := contentHash(payload)
+		if err != nil {
+			return summary, fmt.Errorf("failed to hash post %d: %w", post.ID, err)
+		}
+
+		version := models.PostVersion{
+			Source:    source,
+			PostID:    post.ID,
+			Version:   1,
+			Hash:      hash,
+			Payload:   payload,
+			ValidFrom: now,
+		}
+
+		previous, ok := current[post.ID]
+		switch {
+		case !ok:
+			summary.New++
+		case previous.Hash == hash:
+			summary.Unchanged++
+			continue
+		default:
+			summary.Changed++
+			version.Version = previous.Version + 1
+			version.Changes = Diff(previous.Payload, payload)
+		}
+
+		// Later posts of the run with the same ID compare with this version
+		current[post.ID] = version
+		versions = append(versions, version)
+	}
+
+	if err := r.store.SaveVersions(ctx, versions); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// contentHash hashes the canonical JSON encoding of a payload, with object
+// keys sorted
+func contentHash(payload map[string]interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Diff returns the fields that differ between two payloads by dotted path,
+// sorted by path. Nested objects are compared field by field and arrays as
+// a whole.
+func Diff(before, after map[string]interface{}) []models.FieldDiff {
+	beforeFields := make(map[string]interface{})
+	flatten("", before, beforeFields)
+	afterFields := make(map[string]interface{})
+	flatten("", after, afterFields)
+
+	var diffs []models.FieldDiff
+	for path, value := range beforeFields {
+		other, ok := afterFields[path]
+		if !ok {
+			diffs = append(diffs, models.FieldDiff{Path: path, Before: value})
+			continue
+		}
+		if !equal(value, other) {
+			diffs = append(diffs, models.FieldDiff{Path: path, Before: value, After: other})
+		}
+	}
+	for path, value := range afterFields {
+		if _, ok := beforeFields[path]; !ok {
+			diffs = append(diffs, models.FieldDiff{Path: path, After: value})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs
+}
+
+// flatten adds the leaf values of a payload to fields by dotted path
+func flatten(prefix string, value map[string]interface{}, fields map[string]interface{}) {
+	for key, v := range value {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+			flatten(path, nested, fields)
+			continue
+		}
+		fields[path] = v
+	}
+}
+
+// equal compares values by their JSON encoding, so numbers decoded with
+// different Go types compare equal
+func equal(a, b interface{}) bool {
+	aData, aErr := json.Marshal(a)
+	bData, bErr := json.Marshal(b)
+	if aErr != nil || bErr != nil {
+		return false
+	}
+	return bytes.Equal(aData, bData)
+}
@@ -0,0 +1,140 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryStore keeps versions in memory
+type memoryStore struct {
+	versions []models.PostVersion
+}
+
+func (s *memoryStore) GetCurrentVersions(ctx context.Context, source string, postIDs []int) ([]models.PostVersion, error) {
+	ids := make(map[int]bool)
+	for _, id := range postIDs {
+		ids[id] = true
+	}
+	var current []models.PostVersion
+	for _, version := range s.versions {
+		if version.Source == source && ids[version.PostID] && version.ValidTo == nil {
+			current = append(current, version)
+		}
+	}
+	return current, nil
+}
+
+func (s *memoryStore) SaveVersions(ctx context.Context, versions []models.PostVersion) error {
+	for _, version := range versions {
+		for i := range s.versions {
+			previous := &s.versions[i]
+			if previous.Source == version.Source && previous.PostID == version.PostID && previous.Version == version.Version-1 {
+				validTo := version.ValidFrom
+				previous.ValidTo = &validTo
+			}
+		}
+		s.versions = append(s.versions, version)
+	}
+	return nil
+}
+
+func decodePosts(t *testing.T, payloads ...string) []models.Post {
+	posts := make([]models.Post, len(payloads))
+	for i, payload := range payloads {
+		if err := json.Unmarshal([]byte(payload), &posts[i]); err != nil {
+			t.Fatalf("Failed to decode post: %v", err)
+		}
+	}
+	return posts
+}
+
+func TestObserve(t *testing.T) {
+	store := &memoryStore{}
+	recorder := NewRecorder(store)
+	first := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return first }
+
+	summary, err := recorder.Observe(context.Background(), "test", decodePosts(t,
+		`{"id":1,"title":"a","user":{"name":"x"}}`,
+		`{"id":2,"title":"b"}`,
+		`{"title":"no id"}`,
+	))
+	if err != nil {
+		t.Fatalf("Observe returned an error: %v", err)
+	}
+	if summary != (Summary{New: 2}) {
+		t.Errorf("Expected 2 new records, got %+v", summary)
+	}
+
+	second := first.Add(time.Hour)
+	recorder.now = func() time.Time { return second }
+	summary, err = recorder.Observe(context.Background(), "test", decodePosts(t,
+		`{"id":1,"title":"a2","user":{"name":"x","age":3}}`,
+		`{"title":"b","id":2}`,
+	))
+	if err != nil {
+		t.Fatalf("Observe returned an error: %v", err)
+	}
+	if summary != (Summary{Changed: 1, Unchanged: 1}) {
+		t.Errorf("Expected 1 changed and 1 unchanged record, got %+v", summary)
+	}
+
+	if len(store.versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %d", len(store.versions))
+	}
+	original, latest := store.versions[0], store.versions[2]
+	if original.ValidTo == nil || !original.ValidTo.Equal(second) {
+		t.Errorf("Expected the first version to be valid until %v, got %v", second, original.ValidTo)
+	}
+	if latest.Version != 2 || latest.ValidTo != nil || !latest.ValidFrom.Equal(second) {
+		t.Errorf("Expected an open second version from %v, got %+v", second, latest)
+	}
+
+	want := []models.FieldDiff{
+		{Path: "title", Before: "a", After: "a2"},
+		{Path: "user.age", After: int64(3)},
+	}
+	if !reflect.DeepEqual(latest.Changes, want) {
+		t.Errorf("Expected changes %+v, got %+v", want, latest.Changes)
+	}
+}
+
+func TestObserveRepeatedID(t *testing.T) {
+	store := &memoryStore{}
+	recorder := NewRecorder(store)
+
+	summary, err := recorder.Observe(context.Background(), "test", decodePosts(t,
+		`{"id":1,"title":"a"}`,
+		`{"id":1,"title":"b"}`,
+		`{"id":1,"title":"b"}`,
+	))
+	if err != nil {
+		t.Fatalf("Observe returned an error: %v", err)
+	}
+	if summary != (Summary{New: 1, Changed: 1, Unchanged: 1}) {
+		t.Errorf("Expected the run to compare with its own earlier records, got %+v", summary)
+	}
+	if len(store.versions) != 2 || store.versions[1].Version != 2 {
+		t.Errorf("Expected 2 versions, got %+v", store.versions)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := map[string]interface{}{"a": int32(1), "b": "x", "c": []interface{}{"1"}, "d": map[string]interface{}{"e": 1.5}}
+	after := map[string]interface{}{"a": int64(1), "b": "y", "c": []interface{}{"1", "2"}, "d": "flat"}
+
+	want := []models.FieldDiff{
+		{Path: "b", Before: "x", After: "y"},
+		{Path: "c", Before: []interface{}{"1"}, After: []interface{}{"1", "2"}},
+		{Path: "d", After: "flat"},
+		{Path: "d.e", Before: 1.5},
+	}
+	if diffs := Diff(before, after); !reflect.DeepEqual(diffs, want) {
+		t.Errorf("Expected diffs %+v, got %+v", want, diffs)
+	}
+}
@@ -0,0 +1,298 @@
+// Package jobs runs backfill jobs, re-ingesting a source over a time range
+// one chunk at a time. Jobs and their progress are kept in the database:
+// each chunk is checkpointed, so a job interrupted by a restart resumes
+// from the last completed chunk on whichever replica claims it next.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrInvalidRequest is returned when creating a job from an invalid request
+	ErrInvalidRequest = errors.New("invalid job request")
+	// ErrUnknownSource is returned when creating a job for a source without
+	// pipeline
+	ErrUnknownSource = errors.New("unknown source")
+	// ErrJobNotFound is returned when a job does not exist
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished is returned when cancelling a job that already finished
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// Store keeps the jobs and their progress
+type Store interface {
+	CreateJob(ctx context.Context, job models.Job) (models.Job, error)
+	GetJob(ctx context.Context, id primitive.ObjectID) (*models.Job, error)
+	GetJobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error)
+	// ClaimJob hands a pending job, or a running one whose lease expired,
+	// to owner; it returns nil when there is none
+	ClaimJob(ctx context.Context, owner string, now, expiresAt time.Time) (*models.Job, error)
+	// CheckpointJob records the progress of a job and returns it as stored,
+	// nil if the owner lost it
+	CheckpointJob(ctx context.Context, job models.Job) (*models.Job, error)
+	FinishJob(ctx context.Context, job models.Job) error
+	ReleaseJob(ctx context.Context, id primitive.ObjectID, owner string, now time.Time) error
+	// CancelJob cancels a pending job or flags a running one, returning nil
+	// if the job is neither
+	CancelJob(ctx context.Context, id primitive.ObjectID, now time.Time) (*models.Job, error)
+}
+
+// Counts is the outcome of a chunk
+type Counts struct {
+	Fetched  int
+	Stored   int
+	Rejected int
+}
+
+// Backfiller re-ingests one chunk of a job
+type Backfiller interface {
+	Backfill(ctx context.Context, job models.Job, from, to time.Time) (Counts, error)
+}
+
+// Runner creates jobs and runs them on behalf of a replica
+type Runner struct {
+	store    Store
+	backfill Backfiller
+	sources  map[string]bool
+	owner    string
+	// ttl is how long a claimed job is held without a checkpoint; a chunk
+	// taking longer may be claimed again by another replica
+	ttl time.Duration
+	now func() time.Time
+}
+
+// New creates a new Runner instance running jobs of the given sources as owner
+func New(store Store, backfill Backfiller, sources []string, owner string, ttl time.Duration) *Runner {
+	known := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		known[source] = true
+	}
+	return &Runner{
+		store:    store,
+		backfill: backfill,
+		sources:  known,
+		owner:    owner,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// Create validates a request and queues its job
+func (r *Runner) Create(ctx context.Context, request models.JobRequest) (models.Job, error) {
+	if request.Source == "" {
+		return models.Job{}, fmt.Errorf("%w: source is required", ErrInvalidRequest)
+	}
+	if !r.sources[request.Source] {
+		return models.Job{}, fmt.Errorf("%w: %s", ErrUnknownSource, request.Source)
+	}
+	if request.From.IsZero() || request.To.IsZero() || !request.From.Before(request.To) {
+		return models.Job{}, fmt.Errorf("%w: from and to are required, from before to", ErrInvalidRequest)
+	}
+	chunk, err := time.ParseDuration(request.Chunk)
+	if err != nil || chunk <= 0 {
+		return models.Job{}, fmt.Errorf("%w: invalid chunk %q", ErrInvalidRequest, request.Chunk)
+	}
+	if request.FromParam == "" {
+		request.FromParam = "from"
+	}
+	if request.ToParam == "" {
+		request.ToParam = "to"
+	}
+
+	now := r.now().UTC()
+	span := request.To.Sub(request.From)
+	job := models.Job{
+		Type:        models.JobBackfill,
+		Source:      request.Source,
+		From:        request.From.UTC(),
+		To:          request.To.UTC(),
+		Chunk:       request.Chunk,
+		FromParam:   request.FromParam,
+		ToParam:     request.ToParam,
+		State:       models.JobPending,
+		Cursor:      request.From.UTC(),
+		ChunksTotal: int((span + chunk - 1) / chunk),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	return r.store.CreateJob(ctx, job)
+}
+
+// Get returns a job by its ID
+func (r *Runner) Get(ctx context.Context, id string) (models.Job, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+
+	job, err := r.store.GetJob(ctx, objectID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if job == nil {
+		return models.Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return *job, nil
+}
+
+// List returns the jobs matching the filter, most recent first
+func (r *Runner) List(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	return r.store.GetJobs(ctx, filter)
+}
+
+// Cancel cancels a pending job, or stops a running one after its current
+// chunk
+func (r *Runner) Cancel(ctx context.Context, id string) (models.Job, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+
+	job, err := r.store.CancelJob(ctx, objectID, r.now().UTC())
+	if err != nil {
+		return models.Job{}, err
+	}
+	if job != nil {
+		return *job, nil
+	}
+
+	// The job is missing or no longer pending or running
+	stored, err := r.Get(ctx, id)
+	if err != nil {
+		return models.Job{}, err
+	}
+	return stored, fmt.Errorf("%w: %s is %s", ErrJobFinished, id, stored.State)
+}
+
+// Run claims and runs jobs one at a time, polling for new ones at every
+// interval, until ctx is done. A job interrupted by shutdown is released
+// for any replica to resume.
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && r.runNext(ctx) {
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runNext claims a job and runs it, reporting whether there was one
+func (r *Runner) runNext(ctx context.Context) bool {
+	now := r.now().UTC()
+	job, err := r.store.ClaimJob(ctx, r.owner, now, now.Add(r.ttl))
+	if err != nil {
+		log.Printf("Error claiming job: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	log.Printf("Running job %s, backfill of %s from %s", job.ID.Hex(), job.Source, job.Cursor.Format(time.RFC3339))
+	r.process(ctx, *job)
+	return true
+}
+
+// process runs the remaining chunks of a job, checkpointing after each one
+func (r *Runner) process(ctx context.Context, job models.Job) {
+	chunk, err := time.ParseDuration(job.Chunk)
+	if err != nil || chunk <= 0 {
+		r.finish(job, models.JobFailed, fmt.Sprintf("invalid chunk %q", job.Chunk))
+		return
+	}
+
+	for job.Cursor.Before(job.To) {
+		if job.CancelRequested {
+			r.finish(job, models.JobCancelled, "")
+			return
+		}
+		if ctx.Err() != nil {
+			r.release(job)
+			return
+		}
+
+		end := job.Cursor.Add(chunk)
+		if end.After(job.To) {
+			end = job.To
+		}
+		counts, err := r.backfill.Backfill(ctx, job, job.Cursor, end)
+		if err != nil {
+			if ctx.Err() != nil {
+				r.release(job)
+				return
+			}
+			r.finish(job, models.JobFailed, err.Error())
+			return
+		}
+
+		now := r.now().UTC()
+		expiresAt := now.Add(r.ttl)
+		job.Cursor = end
+		job.ChunksDone++
+		job.Fetched += counts.Fetched
+		job.Stored += counts.Stored
+		job.Rejected += counts.Rejected
+		job.LeaseExpiresAt = &expiresAt
+		job.UpdatedAt = now
+
+		stored, err := r.store.CheckpointJob(ctx, job)
+		if err != nil {
+			// The job resumes from its last checkpoint once the lease expires
+			log.Printf("Error checkpointing job %s: %v", job.ID.Hex(), err)
+			return
+		}
+		if stored == nil {
+			log.Printf("Lost job %s to another replica", job.ID.Hex())
+			return
+		}
+		job = *stored
+	}
+
+	r.finish(job, models.JobSucceeded, "")
+}
+
+// finish records the final state of a job. It does not use the run
+// context, which may be done by then.
+func (r *Runner) finish(job models.Job, state, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := r.now().UTC()
+	job.State = state
+	job.Error = message
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	if err := r.store.FinishJob(ctx, job); err != nil {
+		log.Printf("Error finishing job %s: %v", job.ID.Hex(), err)
+		return
+	}
+	log.Printf("Job %s %s after %d of %d chunks: %d fetched, %d stored, %d rejected",
+		job.ID.Hex(), state, job.ChunksDone, job.ChunksTotal, job.Fetched, job.Stored, job.Rejected)
+}
+
+// release hands an interrupted job back to the queue
+func (r *Runner) release(job models.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.store.ReleaseJob(ctx, job.ID, r.owner, r.now().UTC()); err != nil {
+		log.Printf("Error releasing job %s: %v", job.ID.Hex(), err)
+		return
+	}
+	log.Printf("Released job %s at %s", job.ID.Hex(), job.Cursor.Format(time.RFC3339))
+}
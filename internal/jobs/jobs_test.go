@@ -0,0 +1,281 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryStore keeps jobs in memory
+type memoryStore struct {
+	jobs map[primitive.ObjectID]models.Job
+	ids  []primitive.ObjectID
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{jobs: make(map[primitive.ObjectID]models.Job)}
+}
+
+func (s *memoryStore) CreateJob(ctx context.Context, job models.Job) (models.Job, error) {
+	job.ID = primitive.NewObjectID()
+	s.jobs[job.ID] = job
+	s.ids = append(s.ids, job.ID)
+	return job, nil
+}
+
+func (s *memoryStore) GetJob(ctx context.Context, id primitive.ObjectID) (*models.Job, error) {
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (s *memoryStore) GetJobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	var jobs []models.Job
+	for i := len(s.ids) - 1; i >= 0; i-- {
+		jobs = append(jobs, s.jobs[s.ids[i]])
+	}
+	return jobs, nil
+}
+
+func (s *memoryStore) ClaimJob(ctx context.Context, owner string, now, expiresAt time.Time) (*models.Job, error) {
+	for _, id := range s.ids {
+		job := s.jobs[id]
+		expired := job.State == models.JobRunning && !job.LeaseExpiresAt.After(now)
+		if job.State == models.JobPending || expired {
+			job.State = models.JobRunning
+			job.Owner = owner
+			job.LeaseExpiresAt = &expiresAt
+			s.jobs[id] = job
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) CheckpointJob(ctx context.Context, job models.Job) (*models.Job, error) {
+	stored := s.jobs[job.ID]
+	if stored.Owner != job.Owner || stored.State != models.JobRunning {
+		return nil, nil
+	}
+	job.CancelRequested = stored.CancelRequested
+	s.jobs[job.ID] = job
+	return &job, nil
+}
+
+func (s *memoryStore) FinishJob(ctx context.Context, job models.Job) error {
+	if s.jobs[job.ID].Owner == job.Owner {
+		job.Owner = ""
+		job.LeaseExpiresAt = nil
+		s.jobs[job.ID] = job
+	}
+	return nil
+}
+
+func (s *memoryStore) ReleaseJob(ctx context.Context, id primitive.ObjectID, owner string, now time.Time) error {
+	job := s.jobs[id]
+	if job.Owner == owner && job.State == models.JobRunning {
+		job.State = models.JobPending
+		job.Owner = ""
+		job.LeaseExpiresAt = nil
+		s.jobs[id] = job
+	}
+	return nil
+}
+
+func (s *memoryStore) CancelJob(ctx context.Context, id primitive.ObjectID, now time.Time) (*models.Job, error) {
+	job, ok := s.jobs[id]
+	switch {
+	case !ok:
+		return nil, nil
+	case job.State == models.JobPending:
+		job.State = models.JobCancelled
+		job.FinishedAt = &now
+	case job.State == models.JobRunning:
+		job.CancelRequested = true
+	default:
+		return nil, nil
+	}
+	s.jobs[id] = job
+	return &job, nil
+}
+
+// chunkRecorder records the chunks it backfills and runs hook after each one
+type chunkRecorder struct {
+	chunks [][2]time.Time
+	hook   func(chunk int) error
+}
+
+func (c *chunkRecorder) Backfill(ctx context.Context, job models.Job, from, to time.Time) (Counts, error) {
+	c.chunks = append(c.chunks, [2]time.Time{from, to})
+	if c.hook != nil {
+		if err := c.hook(len(c.chunks)); err != nil {
+			return Counts{}, err
+		}
+	}
+	return Counts{Fetched: 10, Stored: 9, Rejected: 1}, nil
+}
+
+var start = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func backfillRequest() models.JobRequest {
+	return models.JobRequest{Source: "a", From: start, To: start.Add(150 * time.Minute), Chunk: "1h"}
+}
+
+func TestCreate(t *testing.T) {
+	runner := New(newMemoryStore(), &chunkRecorder{}, []string{"a"}, "r1", time.Minute)
+	ctx := context.Background()
+
+	job, err := runner.Create(ctx, backfillRequest())
+	if err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+	if job.State != models.JobPending || job.ChunksTotal != 3 || !job.Cursor.Equal(start) || job.FromParam != "from" || job.ToParam != "to" {
+		t.Errorf("Expected a pending job of 3 chunks from the start, got %+v", job)
+	}
+
+	invalid := []models.JobRequest{
+		{Source: "a", From: start, To: start, Chunk: "1h"},
+		{Source: "a", From: start, To: start.Add(time.Hour), Chunk: "hourly"},
+		{Source: "a", From: start, To: start.Add(time.Hour), Chunk: "-1h"},
+		{From: start, To: start.Add(time.Hour), Chunk: "1h"},
+	}
+	for _, request := range invalid {
+		if _, err := runner.Create(ctx, request); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Expected ErrInvalidRequest for %+v, got %v", request, err)
+		}
+	}
+
+	request := backfillRequest()
+	request.Source = "b"
+	if _, err := runner.Create(ctx, request); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("Expected ErrUnknownSource, got %v", err)
+	}
+}
+
+func TestRunNext(t *testing.T) {
+	store := newMemoryStore()
+	backfill := &chunkRecorder{}
+	runner := New(store, backfill, []string{"a"}, "r1", time.Minute)
+	ctx := context.Background()
+
+	job, _ := runner.Create(ctx, backfillRequest())
+	if !runner.runNext(ctx) {
+		t.Fatalf("Expected a job to run")
+	}
+
+	want := [][2]time.Time{
+		{start, start.Add(time.Hour)},
+		{start.Add(time.Hour), start.Add(2 * time.Hour)},
+		{start.Add(2 * time.Hour), start.Add(150 * time.Minute)},
+	}
+	if len(backfill.chunks) != len(want) {
+		t.Fatalf("Expected chunks %v, got %v", want, backfill.chunks)
+	}
+	for i := range want {
+		if !backfill.chunks[i][0].Equal(want[i][0]) || !backfill.chunks[i][1].Equal(want[i][1]) {
+			t.Errorf("Expected chunk %d to be %v, got %v", i, want[i], backfill.chunks[i])
+		}
+	}
+
+	job, _ = runner.Get(ctx, job.ID.Hex())
+	if job.State != models.JobSucceeded || job.ChunksDone != 3 || job.Stored != 27 || job.Rejected != 3 || job.Owner != "" {
+		t.Errorf("Expected a succeeded job with 27 stored, got %+v", job)
+	}
+
+	if runner.runNext(ctx) {
+		t.Errorf("Expected no job left to run")
+	}
+}
+
+func TestResume(t *testing.T) {
+	store := newMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	backfill := &chunkRecorder{hook: func(chunk int) error {
+		// Shutdown during the second chunk
+		if chunk == 2 {
+			cancel()
+			return context.Canceled
+		}
+		return nil
+	}}
+	runner := New(store, backfill, []string{"a"}, "r1", time.Minute)
+
+	job, _ := runner.Create(context.Background(), backfillRequest())
+	runner.runNext(ctx)
+
+	job, _ = runner.Get(context.Background(), job.ID.Hex())
+	if job.State != models.JobPending || job.ChunksDone != 1 || !job.Cursor.Equal(start.Add(time.Hour)) {
+		t.Fatalf("Expected the job released after its first chunk, got %+v", job)
+	}
+
+	// Another replica resumes from the checkpoint
+	backfill = &chunkRecorder{}
+	New(store, backfill, []string{"a"}, "r2", time.Minute).runNext(context.Background())
+	if len(backfill.chunks) != 2 || !backfill.chunks[0][0].Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the last 2 chunks to run, got %v", backfill.chunks)
+	}
+	job, _ = runner.Get(context.Background(), job.ID.Hex())
+	if job.State != models.JobSucceeded || job.ChunksDone != 3 {
+		t.Errorf("Expected the resumed job to succeed, got %+v", job)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	var runner *Runner
+	var job models.Job
+	backfill := &chunkRecorder{hook: func(chunk int) error {
+		if chunk == 1 {
+			runner.Cancel(ctx, job.ID.Hex())
+		}
+		return nil
+	}}
+	runner = New(store, backfill, []string{"a"}, "r1", time.Minute)
+
+	// A running job stops after its current chunk
+	job, _ = runner.Create(ctx, backfillRequest())
+	runner.runNext(ctx)
+	job, _ = runner.Get(ctx, job.ID.Hex())
+	if job.State != models.JobCancelled || job.ChunksDone != 1 || len(backfill.chunks) != 1 {
+		t.Errorf("Expected the job cancelled after one chunk, got %+v", job)
+	}
+
+	// A pending job is cancelled right away
+	pending, _ := runner.Create(ctx, backfillRequest())
+	if pending, _ = runner.Cancel(ctx, pending.ID.Hex()); pending.State != models.JobCancelled {
+		t.Errorf("Expected the pending job cancelled, got %+v", pending)
+	}
+
+	if _, err := runner.Cancel(ctx, job.ID.Hex()); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("Expected ErrJobFinished, got %v", err)
+	}
+	if _, err := runner.Cancel(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+	if _, err := runner.Get(ctx, "not-an-id"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestFailedChunk(t *testing.T) {
+	store := newMemoryStore()
+	backfill := &chunkRecorder{hook: func(chunk int) error {
+		return errors.New("unexpected status code: 500")
+	}}
+	runner := New(store, backfill, []string{"a"}, "r1", time.Minute)
+	ctx := context.Background()
+
+	job, _ := runner.Create(ctx, backfillRequest())
+	runner.runNext(ctx)
+	job, _ = runner.Get(ctx, job.ID.Hex())
+	if job.State != models.JobFailed || job.Error != "unexpected status code: 500" || job.FinishedAt == nil {
+		t.Errorf("Expected the job failed with the chunk error, got %+v", job)
+	}
+}
@@ -0,0 +1,163 @@
+// Package lease elects the replica that runs ingestion. The leader holds a
+// lease in the database and renews it periodically; when it stops renewing,
+// the lease expires and another replica takes over.
+package lease
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Store keeps the leases
+type Store interface {
+	// AcquireLease takes or renews a lease, reporting whether the holder
+	// now holds it
+	AcquireLease(ctx context.Context, lease models.Lease) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+	GetLease(ctx context.Context, name string) (*models.Lease, error)
+}
+
+// Elector campaigns for a lease on behalf of a replica
+type Elector struct {
+	store  Store
+	name   string
+	holder string
+	ttl    time.Duration
+	now    func() time.Time
+
+	// lease is the lease held by this replica, nil when it does not lead
+	lease *models.Lease
+}
+
+// NewElector creates a new Elector instance for the replica holder. The
+// lease expires ttl after each renewal and is renewed three times per ttl.
+func NewElector(store Store, name, holder string, ttl time.Duration) *Elector {
+	return &Elector{
+		store:  store,
+		name:   name,
+		holder: holder,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// Run campaigns for the lease until ctx is done, running lead while this
+// replica holds it. The context of lead is cancelled when the lease is
+// lost. On shutdown lead is stopped and the lease released, so another
+// replica takes over without waiting for the lease to expire.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	// stop ends lead, nil when this replica does not lead
+	var stop func()
+
+	for {
+		leading := e.step(ctx)
+		switch {
+		case leading && stop == nil:
+			log.Printf("Acquired lease %s as %s", e.name, e.holder)
+			stop = start(ctx, lead)
+		case !leading && stop != nil:
+			log.Printf("Lost lease %s as %s", e.name, e.holder)
+			stop()
+			stop = nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if stop != nil {
+				stop()
+			}
+			e.release()
+			return
+		}
+	}
+}
+
+// start runs lead in the background and returns a function cancelling it
+// and waiting for it to return
+func start(ctx context.Context, lead func(ctx context.Context)) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// step acquires or renews the lease and reports whether this replica leads
+func (e *Elector) step(ctx context.Context) bool {
+	now := e.now().UTC()
+	lease := models.Lease{
+		Name:       e.name,
+		Holder:     e.holder,
+		AcquiredAt: now,
+		RenewedAt:  now,
+		ExpiresAt:  now.Add(e.ttl),
+	}
+	if e.lease != nil {
+		lease.AcquiredAt = e.lease.AcquiredAt
+	}
+
+	acquired, err := e.store.AcquireLease(ctx, lease)
+	if err != nil {
+		log.Printf("Error renewing lease %s: %v", e.name, err)
+		// No other replica can take the lease before it expires. The leader
+		// steps down a third of the ttl early to allow for clock skew.
+		return e.lease != nil && now.Before(e.lease.ExpiresAt.Add(-e.ttl/3))
+	}
+	if !acquired {
+		e.lease = nil
+		return false
+	}
+
+	e.lease = &lease
+	return true
+}
+
+// release gives up the lease if this replica holds it. It does not use the
+// campaign context, which is done by then.
+func (e *Elector) release() {
+	if e.lease == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := e.store.ReleaseLease(ctx, e.name, e.holder); err != nil {
+		log.Printf("Error releasing lease %s: %v", e.name, err)
+		return
+	}
+	e.lease = nil
+	log.Printf("Released lease %s as %s", e.name, e.holder)
+}
+
+// Leader returns the lease of the current leader, or nil if no replica
+// holds an unexpired lease
+func (e *Elector) Leader(ctx interface{}) (*models.Lease, error) {
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	lease, err := e.store.GetLease(ctxValue, e.name)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil || !lease.ExpiresAt.After(e.now()) {
+		return nil, nil
+	}
+	return lease, nil
+}
@@ -0,0 +1,154 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryStore keeps leases in memory
+type memoryStore struct {
+	mu     sync.Mutex
+	leases map[string]models.Lease
+	err    error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{leases: make(map[string]models.Lease)}
+}
+
+func (s *memoryStore) AcquireLease(ctx context.Context, lease models.Lease) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	current, ok := s.leases[lease.Name]
+	if ok && current.Holder != lease.Holder && current.ExpiresAt.After(lease.RenewedAt) {
+		return false, nil
+	}
+	s.leases[lease.Name] = lease
+	return true, nil
+}
+
+func (s *memoryStore) ReleaseLease(ctx context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[name].Holder == holder {
+		delete(s.leases, name)
+	}
+	return nil
+}
+
+func (s *memoryStore) GetLease(ctx context.Context, name string) (*models.Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lease, ok := s.leases[name]
+	if !ok {
+		return nil, nil
+	}
+	return &lease, nil
+}
+
+func TestStep(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	a := NewElector(store, "ingestion", "a", 30*time.Second)
+	b := NewElector(store, "ingestion", "b", 30*time.Second)
+	a.now, b.now = clock, clock
+	ctx := context.Background()
+
+	if !a.step(ctx) || b.step(ctx) {
+		t.Fatalf("Expected a to lead and b to follow")
+	}
+
+	now = now.Add(10 * time.Second)
+	if !a.step(ctx) || a.lease.AcquiredAt.Equal(now) {
+		t.Errorf("Expected a to renew the lease it acquired earlier, got %+v", a.lease)
+	}
+
+	// A leader that cannot reach the store steps down before its lease expires
+	store.err = errors.New("unreachable")
+	now = now.Add(15 * time.Second)
+	if !a.step(ctx) {
+		t.Errorf("Expected a to keep leading within its lease")
+	}
+	now = now.Add(5 * time.Second)
+	if a.step(ctx) {
+		t.Errorf("Expected a to step down close to the lease expiry")
+	}
+
+	store.err = nil
+	now = now.Add(10 * time.Second)
+	if !b.step(ctx) {
+		t.Fatalf("Expected b to take over the expired lease")
+	}
+	if a.step(ctx) {
+		t.Errorf("Expected a to follow b")
+	}
+
+	leader, err := a.Leader(ctx)
+	if err != nil || leader == nil || leader.Holder != "b" {
+		t.Errorf("Expected b to be the leader, got %+v and %v", leader, err)
+	}
+	now = now.Add(time.Minute)
+	if leader, _ := a.Leader(ctx); leader != nil {
+		t.Errorf("Expected no leader once the lease expired, got %+v", leader)
+	}
+}
+
+func TestRunHandoff(t *testing.T) {
+	store := newMemoryStore()
+	a := NewElector(store, "ingestion", "a", 30*time.Millisecond)
+	b := NewElector(store, "ingestion", "b", 30*time.Millisecond)
+
+	var mu sync.Mutex
+	var leaders []string
+	lead := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			mu.Lock()
+			leaders = append(leaders, name)
+			mu.Unlock()
+			<-ctx.Done()
+		}
+	}
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() {
+		defer close(doneA)
+		a.Run(ctxA, lead("a"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	doneB := make(chan struct{})
+	go func() {
+		defer close(doneB)
+		b.Run(ctxB, lead("b"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Shutting a down releases the lease for b
+	cancelA()
+	<-doneA
+	time.Sleep(40 * time.Millisecond)
+	cancelB()
+	<-doneB
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(leaders) != 2 || leaders[0] != "a" || leaders[1] != "b" {
+		t.Errorf("Expected a then b to lead, got %v", leaders)
+	}
+	if lease, _ := store.GetLease(context.Background(), "ingestion"); lease != nil {
+		t.Errorf("Expected the lease to be released, got %+v", lease)
+	}
+}
@@ -1,6 +1,12 @@
 package models
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,6 +18,113 @@ type Post struct {
 	ID     int    `json:"id" bson:"id"`
 	Title  string `json:"title" bson:"title"`
 	Body   string `json:"body" bson:"body"`
+
+	// Fields holds every field of the upstream payload, including the ones
+	// that have no dedicated struct field. Numbers are kept as json.Number.
+	Fields map[string]interface{} `json:"-" bson:"-"`
+
+	// DecodeError is set when a field has the wrong type for its struct
+	// field, e.g. a string userId. The struct field is left at its zero
+	// value instead of failing the whole batch.
+	DecodeError string `json:"-" bson:"-"`
+
+	// Payload holds the upstream record as received
+	Payload json.RawMessage `json:"-" bson:"-"`
+
+	// Stored is the document a reprocessed post was read from, nil for
+	// posts fetched from upstream
+	Stored *EnrichedPost `json:"-" bson:"-"`
+}
+
+// UnmarshalJSON decodes the typed fields and keeps the full payload in Fields
+func (p *Post) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return err
+	}
+
+	// encoding/json keeps decoding the remaining fields after a type error
+	type post Post
+	var typed post
+	err := json.Unmarshal(data, &typed)
+	var typeErr *json.UnmarshalTypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return err
+	}
+
+	*p = Post(typed)
+	p.Fields = fields
+	p.Payload = append(json.RawMessage(nil), data...)
+	if typeErr != nil {
+		p.DecodeError = typeErr.Error()
+	}
+	return nil
+}
+
+// Document converts json.Number values so the payload can be stored as a
+// BSON document with native numbers
+func Document(value map[string]interface{}) map[string]interface{} {
+	if value == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(value))
+	for k, v := range value {
+		result[k] = convertNumbers(v)
+	}
+	return result
+}
+
+func convertNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]interface{}:
+		return Document(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = convertNumbers(item)
+		}
+		return items
+	}
+	return value
+}
+
+// CompressPayload gzips a raw upstream record for storage
+func CompressPayload(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(payload); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressPayload restores a raw upstream record compressed by
+// CompressPayload
+func DecompressPayload(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	defer reader.Close()
+
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	return payload, nil
 }
 
 // EnrichedPost represents a post with additional metadata
@@ -21,15 +134,497 @@ type EnrichedPost struct {
 	PostID     int                `json:"postId" bson:"postId"`
 	Title      string             `json:"title" bson:"title"`
 	Body       string             `json:"body" bson:"body"`
+	EventTime  time.Time          `json:"event_time" bson:"event_time"`
 	IngestedAt time.Time          `json:"ingested_at" bson:"ingested_at"`
 	Source     string             `json:"source" bson:"source"`
+
+	Severity       string `json:"severity,omitempty" bson:"severity,omitempty"`
+	SeverityNumber int    `json:"severity_number,omitempty" bson:"severity_number,omitempty"`
+
+	Fingerprint string `json:"fingerprint,omitempty" bson:"fingerprint,omitempty"`
+	Duplicate   bool   `json:"duplicate,omitempty" bson:"duplicate,omitempty"`
+
+	// Attributes holds values attached by enrichment stages such as lookups
+	Attributes map[string]interface{} `json:"attributes,omitempty" bson:"attributes,omitempty"`
+	Geo        *Geo                   `json:"geo,omitempty" bson:"geo,omitempty"`
+	Tags       []string               `json:"tags,omitempty" bson:"tags,omitempty"`
+
+	// TemplateID identifies the message template mined from the record,
+	// with the values of its variable parts in TemplateParams
+	TemplateID     string   `json:"template_id,omitempty" bson:"template_id,omitempty"`
+	TemplateParams []string `json:"template_params,omitempty" bson:"template_params,omitempty"`
+
+	// SampleRate is the probability the record had of being kept by
+	// sampling; weight it by 1/SampleRate in aggregations. Unset without
+	// sampling.
+	SampleRate float64 `json:"sample_rate,omitempty" bson:"sample_rate,omitempty"`
+
+	// RawPayload is the upstream record the post was transformed from,
+	// gzipped, TransformerVersion the version of the pipeline that
+	// transformed it and ReprocessedAt when it was last reprocessed
+	RawPayload         []byte     `json:"-" bson:"raw_payload,omitempty"`
+	TransformerVersion string     `json:"transformer_version,omitempty" bson:"transformer_version,omitempty"`
+	ReprocessedAt      *time.Time `json:"reprocessed_at,omitempty" bson:"reprocessed_at,omitempty"`
+
+	// DeletedAt is set when the record disappeared from a snapshot source
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+
+	// Collection is the destination set by routing rules, the default
+	// collection when empty. It is not persisted.
+	Collection string `json:"-" bson:"-"`
 }
 
-// IngestStatus represents the status of the latest ingestion
+// Geo holds the location resolved from a client IP address
+type Geo struct {
+	CountryCode    string  `json:"country_code,omitempty" bson:"country_code,omitempty"`
+	Country        string  `json:"country,omitempty" bson:"country,omitempty"`
+	City           string  `json:"city,omitempty" bson:"city,omitempty"`
+	Latitude       float64 `json:"latitude,omitempty" bson:"latitude,omitempty"`
+	Longitude      float64 `json:"longitude,omitempty" bson:"longitude,omitempty"`
+	ASN            uint    `json:"asn,omitempty" bson:"asn,omitempty"`
+	ASOrganization string  `json:"as_organization,omitempty" bson:"as_organization,omitempty"`
+}
+
+// LogFilter holds the filtering and sorting options for querying logs
+type LogFilter struct {
+	From      time.Time
+	To        time.Time
+	SortField string
+	SortDesc  bool
+
+	// MinSeverity and MaxSeverity are inclusive bounds on the severity
+	// number, zero meaning unbounded
+	MinSeverity int
+	MaxSeverity int
+}
+
+// ReprocessRequest selects the stored posts to run through the current
+// pipeline again
+type ReprocessRequest struct {
+	Source string    `json:"source"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// Collection receives the reprocessed posts, which replace the stored
+	// ones by ID when empty
+	Collection string `json:"collection,omitempty"`
+}
+
+// RawPostFilter selects the stored posts of a source that carry their raw
+// payload, paged by ascending ID
+type RawPostFilter struct {
+	Source string
+	From   time.Time
+	To     time.Time
+	// After and Before are exclusive bounds on the document ID, unbounded
+	// when zero
+	After  primitive.ObjectID
+	Before primitive.ObjectID
+	// Collections are read besides the default collection, such as the
+	// collections rules route posts to
+	Collections []string
+}
+
+// ReprocessResult reports the outcome of reprocessing
+type ReprocessResult struct {
+	Source             string `json:"source"`
+	TransformerVersion string `json:"transformer_version"`
+	// Read is the number of stored posts matched, Replayed the number of
+	// upstream records they were transformed from
+	Read     int `json:"read"`
+	Replayed int `json:"replayed"`
+	Stored   int `json:"stored"`
+	Dropped  int `json:"dropped"`
+	Rejected int `json:"rejected"`
+	Deleted  int `json:"deleted"`
+}
+
+// States of an ingestion run
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// IngestStatus represents the status of an ingestion run. The document ID
+// is the run ID; the run is recorded when it starts and finalized when it
+// finishes.
 type IngestStatus struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Timestamp  time.Time          `json:"timestamp" bson:"timestamp"`
+	Success    bool               `json:"success" bson:"success"`
+	Count      int                `json:"count" bson:"count"`
+	Dropped    int                `json:"dropped" bson:"dropped"`
+	Duplicates int                `json:"duplicates" bson:"duplicates"`
+	Rejected   int                `json:"rejected" bson:"rejected"`
+	Counters   map[string]int     `json:"counters,omitempty" bson:"counters,omitempty"`
+	Error      string             `json:"error,omitempty" bson:"error,omitempty"`
+
+	Source     string     `json:"source,omitempty" bson:"source,omitempty"`
+	State      string     `json:"state,omitempty" bson:"state,omitempty"`
+	StartedAt  time.Time  `json:"started_at" bson:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+	DurationMs float64    `json:"duration_ms" bson:"duration_ms"`
+	Timings    RunTimings `json:"timings" bson:"timings"`
+
+	// Fetched and Transformed count the records received from upstream and
+	// output by the transformer; Count is the number stored
+	Fetched     int `json:"fetched" bson:"fetched"`
+	Transformed int `json:"transformed" bson:"transformed"`
+
+	// Bytes, HTTPStatus and Attempts describe the upstream request
+	Bytes      int64 `json:"bytes" bson:"bytes"`
+	HTTPStatus int   `json:"http_status,omitempty" bson:"http_status,omitempty"`
+	Attempts   int   `json:"attempts" bson:"attempts"`
+
+	// New, Changed and Unchanged count the upstream records seen for the
+	// first time, with a different content and with the same content
+	New       int `json:"new" bson:"new"`
+	Changed   int `json:"changed" bson:"changed"`
+	Unchanged int `json:"unchanged" bson:"unchanged"`
+
+	// Deleted counts the records of a snapshot source that disappeared
+	// since the previous run, Purged the tombstoned posts removed after
+	// their grace period
+	Deleted int `json:"deleted" bson:"deleted"`
+	Purged  int `json:"purged,omitempty" bson:"purged,omitempty"`
+
+	// StageLatency holds the time spent in each transform stage
+	StageLatency map[string]StageLatency `json:"stage_latency,omitempty" bson:"stage_latency,omitempty"`
+
+	// Anomaly compares the records fetched by a successful run with the
+	// baseline of its source, once the baseline has enough runs
+	Anomaly *CountAnomaly `json:"anomaly,omitempty" bson:"anomaly,omitempty"`
+}
+
+// CountAnomaly scores the number of records fetched by a run against the
+// median and median absolute deviation of the previous runs of its source
+type CountAnomaly struct {
+	Count  int     `json:"count" bson:"count"`
+	Median float64 `json:"median" bson:"median"`
+	MAD    float64 `json:"mad" bson:"mad"`
+	// Score is the robust z-score of the count, negative below the median
+	Score     float64 `json:"score" bson:"score"`
+	Anomalous bool    `json:"anomalous" bson:"anomalous"`
+}
+
+// SkippedRun records a scheduled run that did not start because the
+// previous run of its source was still going
+type SkippedRun struct {
 	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
-	Success   bool               `json:"success" bson:"success"`
-	Count     int                `json:"count" bson:"count"`
-	Error     string             `json:"error,omitempty" bson:"error,omitempty"`
+	Source    string             `json:"source" bson:"source"`
+	DueAt     time.Time          `json:"due_at" bson:"due_at"`
+	SkippedAt time.Time          `json:"skipped_at" bson:"skipped_at"`
+	// Policy is the overlap policy of the source, Reason why it skipped
+	// the run
+	Policy string `json:"policy" bson:"policy"`
+	Reason string `json:"reason" bson:"reason"`
+}
+
+// RunFilter holds the filtering and paging options for querying runs
+type RunFilter struct {
+	Source string
+	// Success selects successful or failed runs, all runs when nil
+	Success *bool
+	// Anomalous selects the runs with or without an anomalous count
+	Anomalous *bool
+	// From and To bound the start time of the runs
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// RunStats summarizes the finished runs over a window
+type RunStats struct {
+	Source         string    `json:"source,omitempty"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Runs           int       `json:"runs" bson:"runs"`
+	Succeeded      int       `json:"succeeded" bson:"succeeded"`
+	SuccessRate    float64   `json:"success_rate" bson:"success_rate"`
+	MeanDurationMs float64   `json:"mean_duration_ms" bson:"mean_duration_ms"`
+	P95DurationMs  float64   `json:"p95_duration_ms" bson:"p95_duration_ms"`
+	// MeanRecords is the mean number of records stored per run
+	MeanRecords float64 `json:"mean_records" bson:"mean_records"`
+}
+
+// Source status values of a rollup
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+// SourceStatus is the ingestion state of a source, kept up to date as its
+// runs finish. The freshness fields are computed when the status is read.
+type SourceStatus struct {
+	Source              string             `json:"source" bson:"_id"`
+	LastRunID           primitive.ObjectID `json:"last_run_id,omitempty" bson:"last_run_id,omitempty"`
+	LastRunAt           *time.Time         `json:"last_run_at,omitempty" bson:"last_run_at,omitempty"`
+	LastSuccessAt       *time.Time         `json:"last_success_at,omitempty" bson:"last_success_at,omitempty"`
+	LastFailureAt       *time.Time         `json:"last_failure_at,omitempty" bson:"last_failure_at,omitempty"`
+	LastError           string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	ConsecutiveFailures int                `json:"consecutive_failures" bson:"consecutive_failures"`
+
+	// RecentCounts are the records fetched by the latest successful runs,
+	// the baseline of anomaly detection, and LastAnomaly the score of the
+	// latest successful run against it
+	RecentCounts []int         `json:"recent_counts,omitempty" bson:"recent_counts,omitempty"`
+	LastAnomaly  *CountAnomaly `json:"last_anomaly,omitempty" bson:"last_anomaly,omitempty"`
+
+	// Schedule is the schedule the source runs on and NextRunAt the time
+	// its next run is due
+	Schedule  string     `json:"schedule,omitempty" bson:"schedule,omitempty"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty" bson:"next_run_at,omitempty"`
+
+	// SkippedRuns counts the runs skipped because the previous run of the
+	// source was still going
+	SkippedRuns   int        `json:"skipped_runs,omitempty" bson:"skipped_runs,omitempty"`
+	LastSkippedAt *time.Time `json:"last_skipped_at,omitempty" bson:"last_skipped_at,omitempty"`
+
+	// FreshnessLagSeconds is the time since the last successful run, or
+	// since tracking started for a source that never succeeded, and
+	// MaxStalenessSeconds the lag allowed by the SLO of the source, none
+	// when zero
+	FreshnessLagSeconds float64 `json:"freshness_lag_seconds" bson:"-"`
+	MaxStalenessSeconds float64 `json:"max_staleness_seconds,omitempty" bson:"-"`
+	Breached            bool    `json:"breached" bson:"-"`
+}
+
+// StatusRollup is the ingestion status of every source, degraded when any
+// source breaches its SLO
+type StatusRollup struct {
+	Status    string         `json:"status"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Sources   []SourceStatus `json:"sources"`
+	// Leader is the replica holding the ingestion lease, if any
+	Leader *Lease `json:"leader,omitempty"`
+	// Members are the live replicas sharing the sources
+	Members []Member `json:"members,omitempty"`
+}
+
+// Alert states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert is the state of an alerting rule for a source. It fires once when
+// the rule starts matching and resolves once when it stops.
+type Alert struct {
+	// ID is the rule and source, separated by a slash
+	ID         string     `json:"id" bson:"_id"`
+	Rule       string     `json:"rule" bson:"rule"`
+	Source     string     `json:"source" bson:"source"`
+	State      string     `json:"state" bson:"state"`
+	Message    string     `json:"message" bson:"message"`
+	FiredAt    time.Time  `json:"fired_at" bson:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+	// Pending are the channels the current state was not delivered to yet,
+	// retried from RetryAt. The delivery state is not part of notifications.
+	Pending       []string   `json:"-" bson:"pending,omitempty"`
+	Attempts      int        `json:"-" bson:"attempts,omitempty"`
+	RetryAt       *time.Time `json:"-" bson:"retry_at,omitempty"`
+	DeliveryError string     `json:"-" bson:"delivery_error,omitempty"`
+}
+
+// Member is a replica taking part in sharded ingestion, alive as long as
+// it keeps sending heartbeats
+type Member struct {
+	ID          string    `json:"id" bson:"_id"`
+	StartedAt   time.Time `json:"started_at" bson:"started_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at" bson:"heartbeat_at"`
+	ExpiresAt   time.Time `json:"expires_at" bson:"expires_at"`
+	// Sources are the sources the member ingests
+	Sources []string `json:"sources" bson:"sources"`
+}
+
+// Lease is a named lock held by one replica until it expires, unless the
+// holder renews it
+type Lease struct {
+	Name       string    `json:"name" bson:"_id"`
+	Holder     string    `json:"holder" bson:"holder"`
+	AcquiredAt time.Time `json:"acquired_at" bson:"acquired_at"`
+	RenewedAt  time.Time `json:"renewed_at" bson:"renewed_at"`
+	ExpiresAt  time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// Job states
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// JobBackfill is the type of the jobs re-ingesting a range of a source
+const JobBackfill = "backfill"
+
+// JobRequest asks for a source to be re-ingested over a time range, fetched
+// one chunk at a time
+type JobRequest struct {
+	Source string    `json:"source"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// Chunk is the length of the range fetched at once, e.g. 1h
+	Chunk string `json:"chunk"`
+	// FromParam and ToParam are the upstream query parameters bounding a
+	// chunk, from and to when empty
+	FromParam string `json:"from_param,omitempty"`
+	ToParam   string `json:"to_param,omitempty"`
+}
+
+// Job is a backfill persisted with its progress, so it resumes from its
+// last checkpoint after a restart
+type Job struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Type      string             `json:"type" bson:"type"`
+	Source    string             `json:"source" bson:"source"`
+	From      time.Time          `json:"from" bson:"from"`
+	To        time.Time          `json:"to" bson:"to"`
+	Chunk     string             `json:"chunk" bson:"chunk"`
+	FromParam string             `json:"from_param" bson:"from_param"`
+	ToParam   string             `json:"to_param" bson:"to_param"`
+	State     string             `json:"state" bson:"state"`
+	// Cursor is the start of the next chunk, the checkpoint a resumed job
+	// continues from
+	Cursor      time.Time `json:"cursor" bson:"cursor"`
+	ChunksDone  int       `json:"chunks_done" bson:"chunks_done"`
+	ChunksTotal int       `json:"chunks_total" bson:"chunks_total"`
+	Fetched     int       `json:"fetched" bson:"fetched"`
+	Stored      int       `json:"stored" bson:"stored"`
+	Rejected    int       `json:"rejected" bson:"rejected"`
+	Error       string    `json:"error,omitempty" bson:"error,omitempty"`
+	// CancelRequested stops a running job at its next checkpoint
+	CancelRequested bool `json:"cancel_requested,omitempty" bson:"cancel_requested,omitempty"`
+	// Owner is the replica running the job, which holds it until
+	// LeaseExpiresAt unless it checkpoints
+	Owner          string     `json:"owner,omitempty" bson:"owner,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" bson:"lease_expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	StartedAt      *time.Time `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at" bson:"updated_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// JobFilter selects jobs, most recent first
+type JobFilter struct {
+	Source string
+	State  string
+	Limit  int
+}
+
+// RunTimings is the time spent in each step of a run
+type RunTimings struct {
+	FetchMs     float64 `json:"fetch_ms" bson:"fetch_ms"`
+	TransformMs float64 `json:"transform_ms" bson:"transform_ms"`
+	StoreMs     float64 `json:"store_ms" bson:"store_ms"`
+}
+
+// StageLatency is the time spent in a transform stage over a run
+type StageLatency struct {
+	Records int     `json:"records" bson:"records"`
+	TotalMs float64 `json:"total_ms" bson:"total_ms"`
+	MeanMs  float64 `json:"mean_ms" bson:"mean_ms"`
+	MaxMs   float64 `json:"max_ms" bson:"max_ms"`
+}
+
+// Reasons a record was rejected
+const (
+	RejectedSchema = "schema"
+	RejectedScript = "script"
+)
+
+// RejectedRecord is an upstream record that could not be ingested, kept with
+// the reason so it can be inspected and replayed
+type RejectedRecord struct {
+	ID         primitive.ObjectID     `json:"_id,omitempty" bson:"_id,omitempty"`
+	Source     string                 `json:"source" bson:"source"`
+	Reason     string                 `json:"reason" bson:"reason"`
+	Payload    map[string]interface{} `json:"payload" bson:"payload"`
+	Errors     []string               `json:"errors" bson:"errors"`
+	RejectedAt time.Time              `json:"rejected_at" bson:"rejected_at"`
+}
+
+// FieldProfile describes a payload field over the records of a run
+type FieldProfile struct {
+	// Path is the dotted path of the field, e.g. "user.address.city"
+	Path  string   `json:"path" bson:"path"`
+	Types []string `json:"types" bson:"types"`
+	// NullRate is the share of records where the field is missing or null
+	NullRate float64 `json:"null_rate" bson:"null_rate"`
+}
+
+// SchemaProfile is the schema inferred from the records of a source
+type SchemaProfile struct {
+	Records   int            `json:"records" bson:"records"`
+	Fields    []FieldProfile `json:"fields" bson:"fields"`
+	UpdatedAt time.Time      `json:"updated_at" bson:"updated_at"`
+}
+
+// Kinds of schema changes
+const (
+	FieldAdded           = "added"
+	FieldRemoved         = "removed"
+	FieldTypeChanged     = "type_changed"
+	FieldNullRateChanged = "null_rate_changed"
+)
+
+// SchemaChange is a difference between two schema profiles
+type SchemaChange struct {
+	Path   string      `json:"path" bson:"path"`
+	Kind   string      `json:"kind" bson:"kind"`
+	Before interface{} `json:"before,omitempty" bson:"before,omitempty"`
+	After  interface{} `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// DriftEvent records the changes found between a source's baseline schema
+// and the schema of a run
+type DriftEvent struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Source     string             `json:"source" bson:"source"`
+	DetectedAt time.Time          `json:"detected_at" bson:"detected_at"`
+	Changes    []SchemaChange     `json:"changes" bson:"changes"`
+}
+
+// SourceSchema is the schema state of a source: the baseline drift is
+// detected against and the profile of the latest run
+type SourceSchema struct {
+	Source   string        `json:"source" bson:"_id"`
+	Baseline SchemaProfile `json:"baseline" bson:"baseline"`
+	Latest   SchemaProfile `json:"latest" bson:"latest"`
+	Drift    []DriftEvent  `json:"drift,omitempty" bson:"-"`
+}
+
+// PostVersion is a version of an upstream record, identified by its source
+// and upstream ID, valid from ValidFrom until ValidTo or open-ended for the
+// current version
+type PostVersion struct {
+	ID        primitive.ObjectID     `json:"_id,omitempty" bson:"_id,omitempty"`
+	Source    string                 `json:"source" bson:"source"`
+	PostID    int                    `json:"postId" bson:"post_id"`
+	Version   int                    `json:"version" bson:"version"`
+	Hash      string                 `json:"hash" bson:"hash"`
+	Payload   map[string]interface{} `json:"payload" bson:"payload"`
+	Changes   []FieldDiff            `json:"changes,omitempty" bson:"changes,omitempty"`
+	ValidFrom time.Time              `json:"valid_from" bson:"valid_from"`
+	ValidTo   *time.Time             `json:"valid_to" bson:"valid_to"`
+}
+
+// FieldDiff is a field that differs between two versions of a record,
+// missing on one side when Before or After is unset
+type FieldDiff struct {
+	Path   string      `json:"path" bson:"path"`
+	Before interface{} `json:"before,omitempty" bson:"before,omitempty"`
+	After  interface{} `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// LogTemplate is a message template mined from the records of a source
+type LogTemplate struct {
+	ID        string    `json:"id" bson:"_id"`
+	Source    string    `json:"source" bson:"source"`
+	Template  string    `json:"template" bson:"template"`
+	Count     int64     `json:"count" bson:"count"`
+	FirstSeen time.Time `json:"first_seen" bson:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" bson:"last_seen"`
 }
@@ -0,0 +1,244 @@
+// Package reprocess runs stored posts through the current pipeline again,
+// starting from the raw upstream record kept with each post
+package reprocess
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"github.com/tiwariayush700/log-ingestion-service/internal/transformer"
+	"github.com/tiwariayush700/log-ingestion-service/internal/validator"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnknownSource is returned when reprocessing a source without pipeline
+var ErrUnknownSource = errors.New("unknown source")
+
+// Store reads the stored posts and writes the reprocessed ones
+type Store interface {
+	GetRawPosts(ctx context.Context, filter models.RawPostFilter, limit int) ([]models.EnrichedPost, error)
+	StorePosts(ctx context.Context, posts []models.EnrichedPost) error
+	// ReplacePosts writes posts over the stored posts with the same ID,
+	// moving them out of the other collections when routed elsewhere
+	ReplacePosts(ctx context.Context, posts []models.EnrichedPost, collections []string) error
+	StoreRejected(ctx context.Context, records []models.RejectedRecord) error
+	DeletePosts(ctx context.Context, posts []models.EnrichedPost) error
+}
+
+// Reprocessor replays the stored posts of a source through its validator
+// and transformer
+type Reprocessor struct {
+	source    string
+	validate  *validator.Validator
+	transform *transformer.Transformer
+	store     Store
+	batchSize int
+	// collections are read besides the default collection
+	collections []string
+}
+
+// New creates a new Reprocessor instance reading batchSize posts at a time
+// from the default collection and the given collections, such as the ones
+// rules route posts to
+func New(source string, validate *validator.Validator, transform *transformer.Transformer, store Store, batchSize int, collections []string) *Reprocessor {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Reprocessor{
+		source:      source,
+		validate:    validate,
+		transform:   transform,
+		store:       store,
+		batchSize:   batchSize,
+		collections: collections,
+	}
+}
+
+// Reprocess transforms the stored posts matching the request again. The
+// reprocessed posts replace the stored ones by ID unless the request names
+// another collection, and stored posts no longer output are deleted, so an
+// interrupted run can be started again. Posts stored without their raw
+// payload are skipped.
+func (r *Reprocessor) Reprocess(ctx context.Context, request models.ReprocessRequest) (models.ReprocessResult, error) {
+	result := models.ReprocessResult{
+		Source:             request.Source,
+		TransformerVersion: r.transform.Version(),
+	}
+	if request.Source != r.source {
+		return result, fmt.Errorf("%w: %s", ErrUnknownSource, request.Source)
+	}
+
+	// Posts written from now on, including the reprocessed ones, are left out
+	filter := models.RawPostFilter{
+		Source: request.Source,
+		From:   request.From,
+		To:     request.To,
+		Before: primitive.NewObjectIDFromTimestamp(time.Now()),
+		// Posts routed by rules are replayed and routed again
+		Collections: r.collections,
+	}
+
+	// Records split by a stage share their raw payload and are replayed
+	// once, from the first of the posts stored for them
+	replayed := make(map[[sha256.Size]byte]primitive.ObjectID)
+
+	for {
+		stored, err := r.store.GetRawPosts(ctx, filter, r.batchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(stored) == 0 {
+			return result, nil
+		}
+		filter.After = stored[len(stored)-1].ID
+		result.Read += len(stored)
+
+		// siblings holds the posts of the page stored for each replayed
+		// record, by the ID of the post it is replayed from
+		posts := make([]models.Post, 0, len(stored))
+		siblings := make(map[primitive.ObjectID][]models.EnrichedPost)
+		for i := range stored {
+			key := replayKey(&stored[i])
+			if id, ok := replayed[key]; ok {
+				siblings[id] = append(siblings[id], stored[i])
+				continue
+			}
+			replayed[key] = stored[i].ID
+			siblings[stored[i].ID] = append(siblings[stored[i].ID], stored[i])
+
+			post, err := replay(&stored[i])
+			if err != nil {
+				return result, err
+			}
+			posts = append(posts, post)
+		}
+		result.Replayed += len(posts)
+
+		posts, rejected := r.validate.Validate(r.source, posts)
+		transformed, err := r.transform.Transform(ctx, posts)
+		if err != nil {
+			return result, fmt.Errorf("failed to transform posts: %w", err)
+		}
+
+		now := time.Now().UTC()
+		for i := range transformed.Posts {
+			transformed.Posts[i].ReprocessedAt = &now
+			if request.Collection != "" {
+				// Copies get new IDs
+				transformed.Posts[i].ID = primitive.ObjectID{}
+				transformed.Posts[i].Collection = request.Collection
+			}
+		}
+
+		rejected = append(rejected, transformed.Rejected...)
+		if err := r.store.StoreRejected(ctx, rejected); err != nil {
+			return result, err
+		}
+
+		var stale []models.EnrichedPost
+		if request.Collection == "" {
+			stale = assignIDs(transformed.Posts, siblings)
+			err = r.store.ReplacePosts(ctx, transformed.Posts, r.collections)
+		} else {
+			err = r.store.StorePosts(ctx, transformed.Posts)
+		}
+		if err != nil {
+			return result, err
+		}
+		if err := r.transform.Commit(ctx, transformed); err != nil {
+			return result, err
+		}
+		result.Stored += len(transformed.Posts)
+		result.Dropped += transformed.Dropped
+		result.Rejected += len(rejected)
+
+		if err := r.store.DeletePosts(ctx, stale); err != nil {
+			return result, err
+		}
+		result.Deleted += len(stale)
+	}
+}
+
+// assignIDs gives the posts output for each replayed record the IDs of the
+// posts stored for it, in order, and new IDs to the extra ones. The posts
+// transformed from a record carry the ID of the post it was replayed from.
+// It returns the stored posts whose ID was not given out, which are stale.
+func assignIDs(posts []models.EnrichedPost, siblings map[primitive.ObjectID][]models.EnrichedPost) []models.EnrichedPost {
+	used := make(map[primitive.ObjectID]bool)
+	for i := range posts {
+		replayedFrom := posts[i].ID
+		posts[i].ID = primitive.NewObjectID()
+		for _, stored := range siblings[replayedFrom] {
+			if !used[stored.ID] {
+				used[stored.ID] = true
+				posts[i].ID = stored.ID
+				break
+			}
+		}
+	}
+
+	var stale []models.EnrichedPost
+	for _, group := range siblings {
+		for _, stored := range group {
+			if !used[stored.ID] {
+				stale = append(stale, stored)
+			}
+		}
+	}
+	return stale
+}
+
+// Router dispatches reprocessing requests to the reprocessor of their source
+type Router struct {
+	reprocessors map[string]*Reprocessor
+}
+
+// NewRouter creates a new Router over the reprocessors of each source
+func NewRouter(reprocessors ...*Reprocessor) *Router {
+	router := &Router{reprocessors: make(map[string]*Reprocessor, len(reprocessors))}
+	for _, reprocessor := range reprocessors {
+		router.reprocessors[reprocessor.source] = reprocessor
+	}
+	return router
+}
+
+// Reprocess runs the request through the reprocessor of its source
+func (r *Router) Reprocess(ctx context.Context, request models.ReprocessRequest) (models.ReprocessResult, error) {
+	reprocessor, ok := r.reprocessors[request.Source]
+	if !ok {
+		return models.ReprocessResult{Source: request.Source}, fmt.Errorf("%w: %s", ErrUnknownSource, request.Source)
+	}
+	return reprocessor.Reprocess(ctx, request)
+}
+
+// replayKey identifies the upstream record a stored post was transformed
+// from within its ingestion run
+func replayKey(post *models.EnrichedPost) [sha256.Size]byte {
+	hash := sha256.New()
+	hash.Write(post.RawPayload)
+	hash.Write([]byte(post.IngestedAt.UTC().Format(time.RFC3339Nano)))
+
+	var key [sha256.Size]byte
+	copy(key[:], hash.Sum(nil))
+	return key
+}
+
+// replay decodes the raw payload of a stored post
+func replay(stored *models.EnrichedPost) (models.Post, error) {
+	payload, err := models.DecompressPayload(stored.RawPayload)
+	if err != nil {
+		return models.Post{}, fmt.Errorf("failed to read payload of post %s: %w", stored.ID.Hex(), err)
+	}
+
+	var post models.Post
+	if err := json.Unmarshal(payload, &post); err != nil {
+		return models.Post{}, fmt.Errorf("failed to decode payload of post %s: %w", stored.ID.Hex(), err)
+	}
+	post.Stored = stored
+	return post, nil
+}
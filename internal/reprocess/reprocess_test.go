@@ -0,0 +1,312 @@
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"github.com/tiwariayush700/log-ingestion-service/internal/transformer"
+	"github.com/tiwariayush700/log-ingestion-service/internal/validator"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryStore keeps posts in memory, assigning IDs on insert like MongoDB
+type memoryStore struct {
+	posts    []models.EnrichedPost
+	rejected []models.RejectedRecord
+	// deleteErr fails the next delete
+	deleteErr error
+}
+
+func (s *memoryStore) GetRawPosts(ctx context.Context, filter models.RawPostFilter, limit int) ([]models.EnrichedPost, error) {
+	sort.Slice(s.posts, func(i, j int) bool { return s.posts[i].ID.Hex() < s.posts[j].ID.Hex() })
+
+	collections := map[string]bool{"": true}
+	for _, name := range filter.Collections {
+		collections[name] = true
+	}
+
+	var posts []models.EnrichedPost
+	for _, post := range s.posts {
+		switch {
+		case post.Source != filter.Source || !collections[post.Collection] || len(post.RawPayload) == 0:
+		case !filter.After.IsZero() && post.ID.Hex() <= filter.After.Hex():
+		case !filter.Before.IsZero() && post.ID.Hex() >= filter.Before.Hex():
+		case !filter.From.IsZero() && post.EventTime.Before(filter.From):
+		case !filter.To.IsZero() && !post.EventTime.Before(filter.To):
+		default:
+			posts = append(posts, post)
+		}
+		if len(posts) == limit {
+			break
+		}
+	}
+	return posts, nil
+}
+
+func (s *memoryStore) StorePosts(ctx context.Context, posts []models.EnrichedPost) error {
+	for _, post := range posts {
+		post.ID = primitive.NewObjectID()
+		s.posts = append(s.posts, post)
+	}
+	return nil
+}
+
+func (s *memoryStore) ReplacePosts(ctx context.Context, posts []models.EnrichedPost, collections []string) error {
+	replaced := make(map[primitive.ObjectID]bool)
+	for _, post := range posts {
+		replaced[post.ID] = true
+	}
+	kept := s.posts[:0]
+	for _, post := range s.posts {
+		if !replaced[post.ID] {
+			kept = append(kept, post)
+		}
+	}
+	s.posts = append(kept, posts...)
+	return nil
+}
+
+func (s *memoryStore) StoreRejected(ctx context.Context, records []models.RejectedRecord) error {
+	s.rejected = append(s.rejected, records...)
+	return nil
+}
+
+func (s *memoryStore) DeletePosts(ctx context.Context, stored []models.EnrichedPost) error {
+	if err := s.deleteErr; err != nil {
+		s.deleteErr = nil
+		return err
+	}
+	deleted := make(map[primitive.ObjectID]bool)
+	for _, post := range stored {
+		deleted[post.ID] = true
+	}
+	posts := s.posts[:0]
+	for _, post := range s.posts {
+		if !deleted[post.ID] {
+			posts = append(posts, post)
+		}
+	}
+	s.posts = posts
+	return nil
+}
+
+// storedPost builds a post as stored by an earlier ingestion
+func storedPost(t *testing.T, id primitive.ObjectID, payload string, ingestedAt time.Time) models.EnrichedPost {
+	raw, err := models.CompressPayload([]byte(payload))
+	if err != nil {
+		t.Fatalf("Failed to compress payload: %v", err)
+	}
+	return models.EnrichedPost{
+		ID:                 id,
+		Title:              "old title",
+		EventTime:          ingestedAt,
+		IngestedAt:         ingestedAt,
+		Source:             "test_source",
+		RawPayload:         raw,
+		TransformerVersion: "v1",
+		SampleRate:         0.5,
+	}
+}
+
+func TestReprocessInPlace(t *testing.T) {
+	ingestedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := time.Now().Add(-time.Hour)
+	payload := `{"userId":1,"id":7,"title":"new title","body":"body"}`
+
+	first := primitive.NewObjectIDFromTimestamp(past)
+	later := primitive.NewObjectIDFromTimestamp(past.Add(2 * time.Second))
+	store := &memoryStore{posts: []models.EnrichedPost{
+		// Two records split from the same upstream record
+		storedPost(t, first, payload, ingestedAt),
+		storedPost(t, primitive.NewObjectIDFromTimestamp(past.Add(time.Second)), payload, ingestedAt),
+		// The same upstream record fetched by a later run
+		storedPost(t, later, payload, ingestedAt.Add(time.Minute)),
+		// A record whose payload now fails validation
+		storedPost(t, primitive.NewObjectIDFromTimestamp(past.Add(3*time.Second)), `{"id":"8"}`, ingestedAt),
+	}}
+
+	validate, err := validator.New(nil)
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+	transform := transformer.New("test_source", transformer.WithVersion("v2"))
+	reprocessor := New("test_source", validate, transform, store, 2, nil)
+
+	result, err := reprocessor.Reprocess(context.Background(), models.ReprocessRequest{Source: "test_source"})
+	if err != nil {
+		t.Fatalf("Reprocess returned an error: %v", err)
+	}
+
+	want := models.ReprocessResult{
+		Source:             "test_source",
+		TransformerVersion: "v2",
+		Read:               4,
+		Replayed:           3,
+		Stored:             2,
+		Rejected:           1,
+		// The second split record and the rejected one
+		Deleted: 2,
+	}
+	if result != want {
+		t.Errorf("Expected result %+v, got %+v", want, result)
+	}
+
+	if len(store.posts) != 2 || len(store.rejected) != 1 {
+		t.Fatalf("Expected 2 posts and 1 rejected record, got %d and %d", len(store.posts), len(store.rejected))
+	}
+	for _, post := range store.posts {
+		if post.Title != "new title" || post.TransformerVersion != "v2" || post.ReprocessedAt == nil {
+			t.Errorf("Expected a post transformed by v2, got %+v", post)
+		}
+		if post.SampleRate != 0.5 || len(post.RawPayload) == 0 {
+			t.Errorf("Expected the sample rate and payload to be kept, got %+v", post)
+		}
+	}
+	if !store.posts[0].IngestedAt.Equal(ingestedAt) {
+		t.Errorf("Expected the ingestion time %v to be kept, got %v", ingestedAt, store.posts[0].IngestedAt)
+	}
+	// The reprocessed posts replace the stored ones under their IDs
+	if store.posts[0].ID != first || store.posts[1].ID != later {
+		t.Errorf("Expected the IDs %s and %s to be kept, got %s and %s", first.Hex(), later.Hex(), store.posts[0].ID.Hex(), store.posts[1].ID.Hex())
+	}
+}
+
+func TestReprocessInPlaceInterrupted(t *testing.T) {
+	ingestedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := time.Now().Add(-time.Hour)
+
+	first := primitive.NewObjectIDFromTimestamp(past)
+	store := &memoryStore{posts: []models.EnrichedPost{
+		storedPost(t, first, `{"id":1,"title":"a"}`, ingestedAt),
+		storedPost(t, primitive.NewObjectIDFromTimestamp(past.Add(time.Second)), `{"id":"2"}`, ingestedAt),
+	}}
+
+	validate, _ := validator.New(nil)
+	reprocessor := New("test_source", validate, transformer.New("test_source", transformer.WithVersion("v2")), store, 10, nil)
+
+	// The posts are replaced, then the stale one fails to be deleted
+	store.deleteErr = errors.New("connection lost")
+	if _, err := reprocessor.Reprocess(context.Background(), models.ReprocessRequest{Source: "test_source"}); err == nil {
+		t.Fatal("Expected the failed delete to be returned")
+	}
+	if len(store.posts) != 2 {
+		t.Fatalf("Expected the replaced post and the stale one, got %+v", store.posts)
+	}
+
+	// Running again neither duplicates the replaced post nor keeps the stale one
+	result, err := reprocessor.Reprocess(context.Background(), models.ReprocessRequest{Source: "test_source"})
+	if err != nil {
+		t.Fatalf("Reprocess returned an error: %v", err)
+	}
+	if result.Stored != 1 || result.Deleted != 1 {
+		t.Errorf("Expected 1 post stored and 1 deleted, got %+v", result)
+	}
+	if len(store.posts) != 1 || store.posts[0].ID != first || store.posts[0].TransformerVersion != "v2" {
+		t.Errorf("Expected only the reprocessed post under its ID, got %+v", store.posts)
+	}
+}
+
+func TestReprocessIntoCollection(t *testing.T) {
+	ingestedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := time.Now().Add(-time.Hour)
+
+	store := &memoryStore{posts: []models.EnrichedPost{
+		storedPost(t, primitive.NewObjectIDFromTimestamp(past), `{"id":1,"title":"a"}`, ingestedAt),
+		storedPost(t, primitive.NewObjectIDFromTimestamp(past.Add(time.Second)), `{"id":2,"title":"b"}`, ingestedAt.Add(24*time.Hour)),
+	}}
+
+	validate, _ := validator.New(nil)
+	reprocessor := New("test_source", validate, transformer.New("test_source"), store, 10, nil)
+
+	result, err := reprocessor.Reprocess(context.Background(), models.ReprocessRequest{
+		Source:     "test_source",
+		To:         ingestedAt.Add(time.Hour),
+		Collection: "posts_v2",
+	})
+	if err != nil {
+		t.Fatalf("Reprocess returned an error: %v", err)
+	}
+	if result.Read != 1 || result.Stored != 1 || result.Deleted != 0 {
+		t.Errorf("Expected 1 post read and stored, none deleted, got %+v", result)
+	}
+	if len(store.posts) != 3 || store.posts[2].Collection != "posts_v2" || store.posts[2].PostID != 1 {
+		t.Errorf("Expected the reprocessed post in posts_v2 next to the stored ones, got %+v", store.posts)
+	}
+}
+
+func TestReprocessUnknownSource(t *testing.T) {
+	validate, _ := validator.New(nil)
+	reprocessor := New("test_source", validate, transformer.New("test_source"), &memoryStore{}, 10, nil)
+
+	_, err := reprocessor.Reprocess(context.Background(), models.ReprocessRequest{Source: "other"})
+	if !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("Expected ErrUnknownSource, got %v", err)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	store := &memoryStore{posts: []models.EnrichedPost{
+		storedPost(t, primitive.NewObjectIDFromTimestamp(past), `{"id":1,"title":"a"}`, past),
+	}}
+
+	validate, _ := validator.New(nil)
+	router := NewRouter(
+		New("test_source", validate, transformer.New("test_source", transformer.WithVersion("v2")), store, 10, nil),
+		New("other", validate, transformer.New("other", transformer.WithVersion("v3")), store, 10, nil),
+	)
+
+	result, err := router.Reprocess(context.Background(), models.ReprocessRequest{Source: "test_source", Collection: "posts_v2"})
+	if err != nil {
+		t.Fatalf("Reprocess returned an error: %v", err)
+	}
+	if result.TransformerVersion != "v2" || result.Stored != 1 {
+		t.Errorf("Expected the post to be reprocessed by the test_source pipeline, got %+v", result)
+	}
+
+	if _, err := router.Reprocess(context.Background(), models.ReprocessRequest{Source: "unknown"}); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("Expected ErrUnknownSource, got %v", err)
+	}
+}
+
+func TestReprocessRoutedCollections(t *testing.T) {
+	ingestedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := time.Now().Add(-time.Hour)
+
+	routed := storedPost(t, primitive.NewObjectIDFromTimestamp(past), `{"id":1,"title":"a"}`, ingestedAt)
+	routed.Collection = "errors"
+	routed.TemplateID = "t1"
+	store := &memoryStore{posts: []models.EnrichedPost{
+		routed,
+		storedPost(t, primitive.NewObjectIDFromTimestamp(past.Add(time.Second)), `{"id":2,"title":"b"}`, ingestedAt),
+	}}
+
+	// Without rules the routed post now lands in the default collection
+	validate, _ := validator.New(nil)
+	reprocessor := New("test_source", validate, transformer.New("test_source"), store, 10, []string{"errors"})
+
+	result, err := reprocessor.Reprocess(context.Background(), models.ReprocessRequest{Source: "test_source"})
+	if err != nil {
+		t.Fatalf("Reprocess returned an error: %v", err)
+	}
+	if result.Read != 2 || result.Stored != 2 || result.Deleted != 0 {
+		t.Errorf("Expected both posts reprocessed and replaced, got %+v", result)
+	}
+	for _, post := range store.posts {
+		if post.Collection != "" || post.ReprocessedAt == nil {
+			t.Errorf("Expected only reprocessed posts in the default collection, got %+v", post)
+		}
+		// Templates are not mined again when reprocessing
+		if post.PostID == 1 && post.TemplateID != "t1" {
+			t.Errorf("Expected the template to be kept, got %q", post.TemplateID)
+		}
+		// The moved post keeps its ID
+		if post.PostID == 1 && post.ID != routed.ID {
+			t.Errorf("Expected the ID %s to be kept, got %s", routed.ID.Hex(), post.ID.Hex())
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Overlap policies, applied when a run is due while the previous run of
+// the source is still going
+const (
+	// OverlapSkip records the due run as skipped
+	OverlapSkip = "skip"
+	// OverlapQueue starts the due run once the previous one ends. A single
+	// run is queued, runs due while one is queued are skipped.
+	OverlapQueue = "queue"
+	// OverlapCancel cancels the previous run and starts the due one
+	OverlapCancel = "cancel"
+)
+
+var (
+	// ErrRunTimeout is the cause of the cancellation of a run that
+	// exceeded its timeout
+	ErrRunTimeout = errors.New("run timed out")
+	// ErrRunCancelled is the cause of the cancellation of a run replaced
+	// by the next one
+	ErrRunCancelled = errors.New("run cancelled by the next scheduled run")
+)
+
+// Tracker keeps the schedule state of the sources
+type Tracker interface {
+	GetSourceState(ctx context.Context, source string) (*models.SourceStatus, error)
+	ScheduleRun(ctx context.Context, source, schedule string, next time.Time) error
+	RecordSkippedRun(ctx context.Context, skipped models.SkippedRun) error
+}
+
+// Runner runs a source on its schedule, one run at a time
+type Runner struct {
+	source    string
+	scheduler *Scheduler
+	tracker   Tracker
+	// timeout bounds each run, none when zero
+	timeout time.Duration
+	overlap string
+	now     func() time.Time
+}
+
+// NewRunner creates a new Runner instance
+func NewRunner(source string, scheduler *Scheduler, tracker Tracker, timeout time.Duration, overlap string) (*Runner, error) {
+	if overlap != OverlapSkip && overlap != OverlapQueue && overlap != OverlapCancel {
+		return nil, fmt.Errorf("invalid overlap policy %q", overlap)
+	}
+	return &Runner{
+		source:    source,
+		scheduler: scheduler,
+		tracker:   tracker,
+		timeout:   timeout,
+		overlap:   overlap,
+		now:       time.Now,
+	}, nil
+}
+
+// execution is the run in progress of a Runner
+type execution struct {
+	// stop cancels the run in progress, nil when there is none
+	stop context.CancelCauseFunc
+	done chan struct{}
+	// queued is set when a run is due once the one in progress ends
+	queued bool
+}
+
+// Run calls run at every due time of the schedule until ctx is done, each
+// run delayed by the jitter of the schedule. The time the next run is due,
+// before its jitter, is kept in the tracker, so a restarted replica,
+// or the replica a source moves to, keeps to the schedule and applies the
+// catch-up policy to the runs that were missed. The context of a run is
+// cancelled with ErrRunTimeout as its cause when it exceeds the timeout,
+// and with ErrRunCancelled when the next run replaces it. On shutdown the
+// run in progress is cancelled and waited for.
+func (r *Runner) Run(ctx context.Context, run func(ctx context.Context)) {
+	state, err := r.tracker.GetSourceState(ctx, r.source)
+	if err != nil {
+		log.Printf("Error reading the state of %s: %v", r.source, err)
+	}
+	due := r.scheduler.Resume(state)
+	current := &execution{done: make(chan struct{})}
+
+	for {
+		if err := r.tracker.ScheduleRun(ctx, r.source, r.scheduler.String(), due); err != nil {
+			log.Printf("Error recording the next run of %s: %v", r.source, err)
+		}
+
+		timer := time.NewTimer(time.Until(r.scheduler.Jitter(due)))
+		for waiting := true; waiting; {
+			select {
+			case <-timer.C:
+				waiting = false
+			case <-current.done:
+				r.ended(ctx, current, run)
+			case <-ctx.Done():
+				timer.Stop()
+				if current.stop != nil {
+					<-current.done
+					current.stop(nil)
+				}
+				return
+			}
+		}
+
+		r.trigger(ctx, current, run, due)
+		due = r.scheduler.Next(due)
+	}
+}
+
+// trigger starts the run due at due, or applies the overlap policy when a
+// run is in progress
+func (r *Runner) trigger(ctx context.Context, current *execution, run func(ctx context.Context), due time.Time) {
+	switch {
+	case current.stop == nil:
+		r.start(ctx, current, run)
+	case r.overlap == OverlapCancel:
+		log.Printf("Cancelling the run in progress of %s for the run due at %s", r.source, due.Format(time.RFC3339))
+		current.stop(ErrRunCancelled)
+		<-current.done
+		current.stop = nil
+		r.start(ctx, current, run)
+	case r.overlap == OverlapQueue && !current.queued:
+		log.Printf("Queueing the run of %s due at %s", r.source, due.Format(time.RFC3339))
+		current.queued = true
+	default:
+		reason := "previous run still running"
+		if current.queued {
+			reason = "a run is already queued"
+		}
+		r.skip(ctx, due, reason)
+	}
+}
+
+// ended releases a run that returned and starts the queued run, if any
+func (r *Runner) ended(ctx context.Context, current *execution, run func(ctx context.Context)) {
+	current.stop(nil)
+	current.stop = nil
+	if current.queued {
+		current.queued = false
+		r.start(ctx, current, run)
+	}
+}
+
+// start calls run in the background with a context bounded by the timeout
+func (r *Runner) start(ctx context.Context, current *execution, run func(ctx context.Context)) {
+	runCtx, stop := context.WithCancelCause(ctx)
+	current.stop = stop
+
+	go func() {
+		if r.timeout > 0 {
+			timer := time.AfterFunc(r.timeout, func() {
+				stop(fmt.Errorf("%w after %s", ErrRunTimeout, r.timeout))
+			})
+			defer timer.Stop()
+		}
+		run(runCtx)
+		current.done <- struct{}{}
+	}()
+}
+
+// skip records a run that did not start
+func (r *Runner) skip(ctx context.Context, due time.Time, reason string) {
+	log.Printf("Skipping the run of %s due at %s: %s", r.source, due.Format(time.RFC3339), reason)
+	skipped := models.SkippedRun{
+		Source:    r.source,
+		DueAt:     due,
+		SkippedAt: r.now().UTC(),
+		Policy:    r.overlap,
+		Reason:    reason,
+	}
+	if err := r.tracker.RecordSkippedRun(ctx, skipped); err != nil {
+		log.Printf("Error recording skipped run: %v", err)
+	}
+}
+
+// Interruption returns why the context of a run was cancelled before the
+// run ended, ErrRunTimeout or ErrRunCancelled, or nil
+func Interruption(ctx context.Context) error {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, ErrRunTimeout) || errors.Is(cause, ErrRunCancelled) {
+		return cause
+	}
+	return nil
+}
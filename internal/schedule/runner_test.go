@@ -0,0 +1,187 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryTracker keeps the schedule and the skipped runs in memory
+type memoryTracker struct {
+	mu      sync.Mutex
+	next    time.Time
+	skipped []models.SkippedRun
+}
+
+func (m *memoryTracker) GetSourceState(ctx context.Context, source string) (*models.SourceStatus, error) {
+	return nil, nil
+}
+
+func (m *memoryTracker) ScheduleRun(ctx context.Context, source, schedule string, next time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next = next
+	return nil
+}
+
+func (m *memoryTracker) RecordSkippedRun(ctx context.Context, skipped models.SkippedRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.skipped = append(m.skipped, skipped)
+	return nil
+}
+
+func (m *memoryTracker) reasons() map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reasons := make(map[string]int)
+	for _, skipped := range m.skipped {
+		reasons[skipped.Reason]++
+	}
+	return reasons
+}
+
+// probe records the runs it is called for
+type probe struct {
+	mu            sync.Mutex
+	started       int
+	active        int
+	maxActive     int
+	interruptions []error
+}
+
+// run blocks for d or until ctx is done
+func (p *probe) run(d time.Duration) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		p.mu.Lock()
+		p.started++
+		p.active++
+		if p.active > p.maxActive {
+			p.maxActive = p.active
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+
+		p.mu.Lock()
+		p.active--
+		if err := Interruption(ctx); err != nil {
+			p.interruptions = append(p.interruptions, err)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// runFor runs a source every 20ms for d, with runs taking runTime
+func runFor(t *testing.T, overlap string, timeout, runTime, d time.Duration) (*memoryTracker, *probe) {
+	scheduler, err := New("@every 20ms", CatchUpOnce, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	tracker := &memoryTracker{}
+	runner, err := NewRunner("a", scheduler, tracker, timeout, overlap)
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+
+	p := &probe{}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	runner.Run(ctx, p.run(runTime))
+	return tracker, p
+}
+
+func TestRunnerSkip(t *testing.T) {
+	tracker, p := runFor(t, OverlapSkip, 0, 70*time.Millisecond, 150*time.Millisecond)
+
+	if p.maxActive != 1 {
+		t.Errorf("Expected one run at a time, got %d", p.maxActive)
+	}
+	if reasons := tracker.reasons(); reasons["previous run still running"] < 2 || len(reasons) != 1 {
+		t.Errorf("Expected the runs due during a run to be skipped, got %v", reasons)
+	}
+	if p.active != 0 {
+		t.Errorf("Expected the run in progress to end on shutdown")
+	}
+}
+
+func TestRunnerQueue(t *testing.T) {
+	tracker, p := runFor(t, OverlapQueue, 0, 50*time.Millisecond, 150*time.Millisecond)
+
+	if p.maxActive != 1 {
+		t.Errorf("Expected one run at a time, got %d", p.maxActive)
+	}
+	// Runs start back to back: one on startup, then each queued run
+	if p.started < 3 {
+		t.Errorf("Expected the queued runs to start, got %d runs", p.started)
+	}
+	if reasons := tracker.reasons(); reasons["a run is already queued"] == 0 || reasons["previous run still running"] != 0 {
+		t.Errorf("Expected the runs due while one is queued to be skipped, got %v", reasons)
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	tracker, p := runFor(t, OverlapCancel, 0, time.Hour, 100*time.Millisecond)
+
+	if p.maxActive != 1 || p.started < 3 {
+		t.Errorf("Expected each run to replace the previous one, got %d runs and %d at once", p.started, p.maxActive)
+	}
+	for _, err := range p.interruptions {
+		if !errors.Is(err, ErrRunCancelled) {
+			t.Errorf("Expected the runs to be cancelled by the next one, got %v", err)
+		}
+	}
+	if len(p.interruptions) < 2 {
+		t.Errorf("Expected the replaced runs to be interrupted, got %v", p.interruptions)
+	}
+	if len(tracker.reasons()) != 0 {
+		t.Errorf("Expected no skipped run, got %v", tracker.reasons())
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	_, p := runFor(t, OverlapSkip, 5*time.Millisecond, time.Hour, 50*time.Millisecond)
+
+	if len(p.interruptions) == 0 || !errors.Is(p.interruptions[0], ErrRunTimeout) {
+		t.Errorf("Expected the run to time out, got %v", p.interruptions)
+	}
+	if p.started < 2 {
+		t.Errorf("Expected a run after the one that timed out, got %d", p.started)
+	}
+}
+
+func TestRunnerJitter(t *testing.T) {
+	scheduler, err := New("@every 20ms jitter 15ms", CatchUpOnce, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	runner, err := NewRunner("a", scheduler, &memoryTracker{}, 0, OverlapSkip)
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+
+	p := &probe{}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	runner.Run(ctx, p.run(0))
+
+	// 25 runs are due in 500ms; runs planned from the jittered time of the
+	// previous run would only come every 27.5ms on average
+	if p.started < 22 {
+		t.Errorf("Expected a run every 20ms on average, got %d runs in 500ms", p.started)
+	}
+}
+
+func TestNewRunnerInvalid(t *testing.T) {
+	scheduler, _ := New("@hourly", CatchUpOnce, time.UTC)
+	if _, err := NewRunner("a", scheduler, &memoryTracker{}, 0, "parallel"); err == nil {
+		t.Errorf("Expected an error for an unknown overlap policy")
+	}
+}
@@ -0,0 +1,277 @@
+// Package schedule decides when the runs of a source are due. A schedule
+// is a cron expression or a fixed interval, optionally jittered and
+// restricted to time windows, with a policy for the runs it missed.
+package schedule
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Catch-up policies, applied when the time of a run passed without it
+// running, e.g. while the service was down
+const (
+	// CatchUpSkip drops the missed runs and waits for the next scheduled one
+	CatchUpSkip = "skip"
+	// CatchUpOnce runs once right away, however many runs were missed
+	CatchUpOnce = "once"
+)
+
+// Schedule returns the scheduled times of a source
+type Schedule interface {
+	// Next returns the first scheduled time after a time, or the zero time
+	// if there is none
+	Next(after time.Time) time.Time
+}
+
+// every fires at a fixed interval
+type every struct {
+	interval time.Duration
+}
+
+// Next returns the time an interval after a time
+func (e every) Next(after time.Time) time.Time {
+	return after.Add(e.interval)
+}
+
+// Scheduler plans the runs of a source
+type Scheduler struct {
+	spec    string
+	base    Schedule
+	jitter  time.Duration
+	window  *window
+	catchUp string
+	now     func() time.Time
+	rand    func(n int64) int64
+}
+
+// New creates a new Scheduler from a spec evaluated in loc. A spec is a
+// cron expression, an @ shorthand such as @hourly or an interval such as
+// @every 5m, followed by any of:
+//
+//	jitter 30s                  delay each run by up to 30s
+//	window Mon-Fri 09:00-17:00  only run within these hours of these days
+func New(spec, catchUp string, loc *time.Location) (*Scheduler, error) {
+	if catchUp != CatchUpSkip && catchUp != CatchUpOnce {
+		return nil, fmt.Errorf("invalid catch-up policy %q", catchUp)
+	}
+
+	s := &Scheduler{
+		spec:    spec,
+		catchUp: catchUp,
+		now:     time.Now,
+		rand:    rand.Int63n,
+	}
+	if err := s.parse(spec, loc); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	if s.base.Next(s.now()).IsZero() {
+		return nil, fmt.Errorf("schedule %q never fires", spec)
+	}
+	return s, nil
+}
+
+// parse reads the schedule, jitter and window of a spec
+func (s *Scheduler) parse(spec string, loc *time.Location) error {
+	var base []string
+	tokens := strings.Fields(spec)
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "jitter":
+			if i+1 == len(tokens) {
+				return fmt.Errorf("jitter requires a duration")
+			}
+			i++
+			jitter, err := time.ParseDuration(tokens[i])
+			if err != nil || jitter < 0 {
+				return fmt.Errorf("invalid jitter %q", tokens[i])
+			}
+			s.jitter = jitter
+		case "window":
+			// The days are optional, the hours are not
+			days := "*"
+			if i+1 < len(tokens) && !strings.Contains(tokens[i+1], ":") {
+				i++
+				days = tokens[i]
+			}
+			if i+1 == len(tokens) {
+				return fmt.Errorf("window requires hours")
+			}
+			i++
+			w, err := parseWindow(days, tokens[i], loc)
+			if err != nil {
+				return err
+			}
+			s.window = w
+		default:
+			base = append(base, tokens[i])
+		}
+	}
+
+	if len(base) == 2 && base[0] == "@every" {
+		interval, err := time.ParseDuration(base[1])
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid interval %q", base[1])
+		}
+		s.base = every{interval: interval}
+		return nil
+	}
+
+	c, err := parseCron(strings.Join(base, " "), loc)
+	if err != nil {
+		return err
+	}
+	s.base = c
+	return nil
+}
+
+// String returns the spec of the schedule
+func (s *Scheduler) String() string {
+	return s.spec
+}
+
+// Next returns when the run following the one planned at last is due.
+// Planned times are not jittered, so the jitter of a run does not shift
+// the runs after it.
+func (s *Scheduler) Next(last time.Time) time.Time {
+	return s.Due(s.plan(last))
+}
+
+// Jitter returns when a run planned at a time fires: delayed by up to the
+// jitter, and moved to the start of the next window when that falls
+// outside one
+func (s *Scheduler) Jitter(planned time.Time) time.Time {
+	if s.jitter <= 0 || planned.IsZero() {
+		return planned
+	}
+	fire := planned.Add(time.Duration(s.rand(int64(s.jitter))))
+	if s.window != nil {
+		fire = s.window.next(fire)
+	}
+	return fire
+}
+
+// Resume returns when the next run of a source is due, from the state left
+// by the replica that ran it before. The planned time is kept as long as
+// the schedule did not change; without a planned time the schedule
+// continues from the last run, and a source that never ran is due as if
+// its first run was missed.
+func (s *Scheduler) Resume(state *models.SourceStatus) time.Time {
+	switch {
+	case state == nil:
+		return s.Due(time.Time{})
+	case state.Schedule == s.spec && state.NextRunAt != nil:
+		return s.Due(*state.NextRunAt)
+	case state.LastRunAt != nil:
+		return s.Next(*state.LastRunAt)
+	default:
+		return s.Due(time.Time{})
+	}
+}
+
+// Due applies the catch-up policy to a planned run. A run planned in the
+// future is due then. A run whose time passed, or the zero time, was
+// missed: it is due right away with CatchUpOnce, or at the start of the
+// next window, and skipped with CatchUpSkip.
+func (s *Scheduler) Due(planned time.Time) time.Time {
+	now := s.now()
+	if planned.After(now) {
+		return planned
+	}
+	if s.catchUp == CatchUpOnce {
+		if s.window != nil {
+			return s.window.next(now)
+		}
+		return now
+	}
+	return s.plan(now)
+}
+
+// plan returns the first scheduled time after a time, moved to the start
+// of the next window when outside one
+func (s *Scheduler) plan(after time.Time) time.Time {
+	next := s.base.Next(after)
+	if next.IsZero() {
+		return next
+	}
+	if s.window != nil {
+		next = s.window.next(next)
+	}
+	return next
+}
+
+// window is a daily range of hours on some days of the week. A range
+// ending before it starts ends the next day.
+type window struct {
+	days uint64
+	// start and end are minutes since midnight
+	start, end int
+	loc        *time.Location
+}
+
+// parseWindow parses days such as Mon-Fri or Sat,Sun and hours such as
+// 09:00-17:00
+func parseWindow(days, hours string, loc *time.Location) (*window, error) {
+	w := &window{loc: loc}
+	var err error
+	if w.days, err = parseDays(days); err != nil {
+		return nil, fmt.Errorf("invalid window days: %w", err)
+	}
+
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid window hours %q", hours)
+	}
+	if w.start, err = parseClock(from); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// parseClock parses a time of day such as 09:30 into minutes since midnight
+func parseClock(value string) (int, error) {
+	hour, minute, ok := strings.Cut(value, ":")
+	h, err := strconv.Atoi(hour)
+	if !ok || err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	m, err := strconv.Atoi(minute)
+	if err != nil || m < 0 || m > 59 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return h*60 + m, nil
+}
+
+// next returns t if it falls within the window, or else the start of the
+// next window
+func (w *window) next(t time.Time) time.Time {
+	t = t.In(w.loc)
+	length := w.end - w.start
+	if length <= 0 {
+		length += 24 * 60
+	}
+
+	// A window that started the day before may still be open
+	year, month, day := t.Date()
+	for i := -1; i <= 7; i++ {
+		start := time.Date(year, month, day+i, w.start/60, w.start%60, 0, 0, w.loc)
+		if w.days&(1<<uint(start.Weekday())) == 0 {
+			continue
+		}
+		if t.Before(start) {
+			return start
+		}
+		if t.Before(start.Add(time.Duration(length) * time.Minute)) {
+			return t
+		}
+	}
+	return time.Time{}
+}
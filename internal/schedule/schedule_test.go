@@ -0,0 +1,207 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// 2023-01-02 is a Monday
+var monday = time.Date(2023, 1, 2, 10, 7, 30, 0, time.UTC)
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"*/15 * * * *", monday, time.Date(2023, 1, 2, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * *", monday, time.Date(2023, 1, 2, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * sat,sun", monday, time.Date(2023, 1, 7, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", monday, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", monday, time.Date(2023, 1, 2, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", monday, time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", monday, time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)},
+		// Either day matches when both are restricted
+		{"0 0 15 * 5", monday, time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{"0-30/10 10 * * *", monday, time.Date(2023, 1, 2, 10, 10, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr, time.UTC)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+		}
+		if got := c.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("Expected %q after %s to fire at %s, got %s", tt.expr, tt.after, tt.want, got)
+		}
+	}
+}
+
+func TestCronTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No timezone database: %v", err)
+	}
+	c, err := parseCron("0 9 * * *", loc)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if got := c.Next(monday); !got.Equal(time.Date(2023, 1, 2, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 9:00 in New York, got %s", got.UTC())
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"* * * *",
+		"60 * * * *",
+		"*/0 * * * *",
+		"0 0 31 2 *",
+		"@every",
+		"@every -5m",
+		"@every 5m jitter",
+		"@every 5m jitter soon",
+		"@every 5m window Mon-Fri",
+		"@every 5m window 9-17",
+		"@every 5m window Mon-Fri 09:00-25:00",
+	} {
+		if _, err := New(spec, CatchUpOnce, time.UTC); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+	if _, err := New("@hourly", "always", time.UTC); err == nil {
+		t.Errorf("Expected an error for an unknown catch-up policy")
+	}
+}
+
+func TestWindow(t *testing.T) {
+	s, err := New("@every 1h window Mon-Fri 09:00-17:00", CatchUpOnce, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+
+	tests := []struct {
+		after time.Time
+		want  time.Time
+	}{
+		{monday, monday.Add(time.Hour)},
+		// Past the end of the day, runs at the next opening
+		{time.Date(2023, 1, 2, 16, 30, 0, 0, time.UTC), time.Date(2023, 1, 3, 9, 0, 0, 0, time.UTC)},
+		// Friday evening, runs on Monday
+		{time.Date(2023, 1, 6, 16, 30, 0, 0, time.UTC), time.Date(2023, 1, 9, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := s.plan(tt.after); !got.Equal(tt.want) {
+			t.Errorf("Expected the run after %s at %s, got %s", tt.after, tt.want, got)
+		}
+	}
+
+	// A window ending before it starts ends the next day
+	night, err := New("*/30 * * * * window 22:00-06:00", CatchUpOnce, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	if got := night.plan(time.Date(2023, 1, 3, 2, 10, 0, 0, time.UTC)); !got.Equal(time.Date(2023, 1, 3, 2, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected a run within the night window, got %s", got)
+	}
+	if got := night.plan(monday); !got.Equal(time.Date(2023, 1, 2, 22, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the run at the opening of the window, got %s", got)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	s, err := New("@every 5m jitter 30s", CatchUpOnce, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	s.rand = func(n int64) int64 { return n - 1 }
+
+	if got := s.plan(monday); !got.Equal(monday.Add(5 * time.Minute)) {
+		t.Errorf("Expected the run planned without jitter, got %s", got)
+	}
+	want := monday.Add(5*time.Minute + 30*time.Second - 1)
+	if got := s.Jitter(s.plan(monday)); !got.Equal(want) {
+		t.Errorf("Expected the run delayed by the jitter at %s, got %s", want, got)
+	}
+}
+
+func TestJitterInterval(t *testing.T) {
+	s, err := New("@every 5m jitter 1m", CatchUpOnce, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	s.now = func() time.Time { return monday }
+
+	// Runs are planned from the planned time of the previous run, as the
+	// runner does, and fire after their jitter
+	const runs = 1000
+	planned := s.Next(monday)
+	first := s.Jitter(planned)
+	var last time.Time
+	for i := 0; i < runs; i++ {
+		planned = s.Next(planned)
+		last = s.Jitter(planned)
+	}
+
+	average := last.Sub(first) / runs
+	if diff := average - 5*time.Minute; diff < -time.Minute/runs || diff > time.Minute/runs {
+		t.Errorf("Expected runs every 5m on average, got %s", average)
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	now := monday
+	clock := func() time.Time { return now }
+
+	once, _ := New("@every 1h", CatchUpOnce, time.UTC)
+	once.now = clock
+	skip, _ := New("@every 1h", CatchUpSkip, time.UTC)
+	skip.now = clock
+
+	// A run planned in the future is kept
+	planned := now.Add(20 * time.Minute)
+	if got := skip.Due(planned); !got.Equal(planned) {
+		t.Errorf("Expected the planned run at %s, got %s", planned, got)
+	}
+
+	// Three missed runs are caught up once, or skipped
+	missed := now.Add(-3 * time.Hour)
+	if got := once.Due(missed); !got.Equal(now) {
+		t.Errorf("Expected the missed run now, got %s", got)
+	}
+	if got := skip.Due(missed); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the missed runs skipped, got %s", got)
+	}
+
+	// Catching up waits for the window
+	windowed, _ := New("@every 1h window 12:00-13:00", CatchUpOnce, time.UTC)
+	windowed.now = clock
+	if got := windowed.Due(missed); !got.Equal(time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the missed run at the opening of the window, got %s", got)
+	}
+}
+
+func TestResume(t *testing.T) {
+	now := monday
+	s, _ := New("@every 1h", CatchUpSkip, time.UTC)
+	s.now = func() time.Time { return now }
+
+	planned := now.Add(10 * time.Minute)
+	lastRun := now.Add(-30 * time.Minute)
+	tests := []struct {
+		name  string
+		state *models.SourceStatus
+		want  time.Time
+	}{
+		{"never ran", nil, now.Add(time.Hour)},
+		{"planned", &models.SourceStatus{Schedule: "@every 1h", NextRunAt: &planned}, planned},
+		{"schedule changed", &models.SourceStatus{Schedule: "@every 2h", NextRunAt: &planned, LastRunAt: &lastRun}, now.Add(30 * time.Minute)},
+		{"not planned", &models.SourceStatus{LastRunAt: &lastRun}, now.Add(30 * time.Minute)},
+	}
+	for _, tt := range tests {
+		if got := s.Resume(tt.state); !got.Equal(tt.want) {
+			t.Errorf("%s: expected the next run at %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package schema infers the schema of upstream payloads and detects drift
+// against a stored baseline
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Store persists the schema state and drift events of each source
+type Store interface {
+	// GetSourceSchema returns the schema state of a source, or nil if the
+	// source has not been profiled yet
+	GetSourceSchema(ctx context.Context, source string) (*models.SourceSchema, error)
+	SaveSourceSchema(ctx context.Context, schema models.SourceSchema) error
+	StoreDriftEvent(ctx context.Context, event models.DriftEvent) error
+}
+
+// Detector profiles the payloads of each run and records drift against the
+// baseline of the source. The baseline is set by the first run and replaced
+// whenever drift is recorded, so each change is reported once.
+type Detector struct {
+	store Store
+	// nullRateDelta is the change in null rate reported as drift
+	nullRateDelta float64
+	now           func() time.Time
+}
+
+// NewDetector creates a new Detector instance
+func NewDetector(store Store, nullRateDelta float64) *Detector {
+	return &Detector{
+		store:         store,
+		nullRateDelta: nullRateDelta,
+		now:           time.Now,
+	}
+}
+
+// Observe profiles the posts of a run and returns the changes against the
+// baseline. Empty runs are ignored.
+func (d *Detector) Observe(ctx context.Context, source string, posts []models.Post) ([]models.SchemaChange, error) {
+	if len(posts) == 0 {
+		return nil, nil
+	}
+
+	payloads := make([]map[string]interface{}, len(posts))
+	for i, post := range posts {
+		payloads[i] = post.Fields
+	}
+	now := d.now().UTC()
+	profile := Infer(payloads)
+	profile.UpdatedAt = now
+
+	state, err := d.store.GetSourceSchema(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, d.store.SaveSourceSchema(ctx, models.SourceSchema{Source: source, Baseline: profile, Latest: profile})
+	}
+
+	state.Latest = profile
+	changes := Diff(state.Baseline, profile, d.nullRateDelta)
+	if len(changes) > 0 {
+		event := models.DriftEvent{Source: source, DetectedAt: now, Changes: changes}
+		if err := d.store.StoreDriftEvent(ctx, event); err != nil {
+			return nil, err
+		}
+		state.Baseline = profile
+	}
+
+	if err := d.store.SaveSourceSchema(ctx, *state); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Infer builds the schema profile of a set of payloads. Nested objects are
+// flattened into dotted paths; arrays are profiled as a whole.
+func Infer(payloads []map[string]interface{}) models.SchemaProfile {
+	type stats struct {
+		types   map[string]bool
+		present int
+	}
+	fields := make(map[string]*stats)
+
+	var walk func(prefix string, object map[string]interface{})
+	walk = func(prefix string, object map[string]interface{}) {
+		for key, value := range object {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			s, ok := fields[path]
+			if !ok {
+				s = &stats{types: make(map[string]bool)}
+				fields[path] = s
+			}
+
+			kind := typeOf(value)
+			if kind == "null" {
+				continue
+			}
+			s.present++
+			s.types[kind] = true
+			if nested, ok := value.(map[string]interface{}); ok {
+				walk(path, nested)
+			}
+		}
+	}
+	for _, payload := range payloads {
+		walk("", payload)
+	}
+
+	profile := models.SchemaProfile{Records: len(payloads), Fields: make([]models.FieldProfile, 0, len(fields))}
+	for path, s := range fields {
+		types := make([]string, 0, len(s.types))
+		for kind := range s.types {
+			types = append(types, kind)
+		}
+		sort.Strings(types)
+
+		profile.Fields = append(profile.Fields, models.FieldProfile{
+			Path:     path,
+			Types:    types,
+			NullRate: roundRate(1 - float64(s.present)/float64(len(payloads))),
+		})
+	}
+	sort.Slice(profile.Fields, func(i, j int) bool {
+		return profile.Fields[i].Path < profile.Fields[j].Path
+	})
+
+	return profile
+}
+
+// Diff compares a profile to its baseline. Null rates are compared only
+// when they moved by at least nullRateDelta.
+func Diff(baseline, current models.SchemaProfile, nullRateDelta float64) []models.SchemaChange {
+	before := make(map[string]models.FieldProfile, len(baseline.Fields))
+	for _, field := range baseline.Fields {
+		before[field.Path] = field
+	}
+
+	var changes []models.SchemaChange
+	for _, field := range current.Fields {
+		previous, ok := before[field.Path]
+		delete(before, field.Path)
+
+		switch {
+		case !ok:
+			changes = append(changes, models.SchemaChange{Path: field.Path, Kind: models.FieldAdded, After: field.Types})
+		case len(previous.Types) > 0 && len(field.Types) > 0 && !sameTypes(previous.Types, field.Types):
+			changes = append(changes, models.SchemaChange{Path: field.Path, Kind: models.FieldTypeChanged, Before: previous.Types, After: field.Types})
+		case math.Abs(field.NullRate-previous.NullRate) >= nullRateDelta:
+			changes = append(changes, models.SchemaChange{Path: field.Path, Kind: models.FieldNullRateChanged, Before: previous.NullRate, After: field.NullRate})
+		}
+	}
+	for path, field := range before {
+		changes = append(changes, models.SchemaChange{Path: path, Kind: models.FieldRemoved, Before: field.Types})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// typeOf returns the JSON Schema type name of a decoded value
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func sameTypes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// roundRate keeps stored rates readable
+func roundRate(rate float64) float64 {
+	return math.Round(rate*1e4) / 1e4
+}
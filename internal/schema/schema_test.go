@@ -0,0 +1,145 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryStore keeps schema state in memory
+type memoryStore struct {
+	schemas map[string]models.SourceSchema
+	events  []models.DriftEvent
+}
+
+func (m *memoryStore) GetSourceSchema(ctx context.Context, source string) (*models.SourceSchema, error) {
+	schema, ok := m.schemas[source]
+	if !ok {
+		return nil, nil
+	}
+	return &schema, nil
+}
+
+func (m *memoryStore) SaveSourceSchema(ctx context.Context, schema models.SourceSchema) error {
+	m.schemas[schema.Source] = schema
+	return nil
+}
+
+func (m *memoryStore) StoreDriftEvent(ctx context.Context, event models.DriftEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func decodePosts(t *testing.T, data string) []models.Post {
+	var posts []models.Post
+	if err := json.Unmarshal([]byte(data), &posts); err != nil {
+		t.Fatalf("Failed to decode posts: %v", err)
+	}
+	return posts
+}
+
+func payloads(posts []models.Post) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(posts))
+	for i, post := range posts {
+		result[i] = post.Fields
+	}
+	return result
+}
+
+func TestInfer(t *testing.T) {
+	posts := decodePosts(t, `[
+		{"id": 1, "title": "a", "score": 1.5, "user": {"name": "x"}, "tags": ["a"]},
+		{"id": 2, "title": null, "score": 2, "user": {"name": "y", "age": 3}}
+	]`)
+
+	profile := Infer(payloads(posts))
+	if profile.Records != 2 {
+		t.Errorf("Expected 2 records, got %d", profile.Records)
+	}
+
+	expected := []models.FieldProfile{
+		{Path: "id", Types: []string{"integer"}, NullRate: 0},
+		{Path: "score", Types: []string{"integer", "number"}, NullRate: 0},
+		{Path: "tags", Types: []string{"array"}, NullRate: 0.5},
+		{Path: "title", Types: []string{"string"}, NullRate: 0.5},
+		{Path: "user", Types: []string{"object"}, NullRate: 0},
+		{Path: "user.age", Types: []string{"integer"}, NullRate: 0.5},
+		{Path: "user.name", Types: []string{"string"}, NullRate: 0},
+	}
+	if !reflect.DeepEqual(profile.Fields, expected) {
+		t.Errorf("Expected fields %+v, got %+v", expected, profile.Fields)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	baseline := models.SchemaProfile{Fields: []models.FieldProfile{
+		{Path: "id", Types: []string{"integer"}},
+		{Path: "legacy", Types: []string{"string"}},
+		{Path: "title", Types: []string{"string"}, NullRate: 0},
+		{Path: "userId", Types: []string{"integer"}},
+	}}
+	current := models.SchemaProfile{Fields: []models.FieldProfile{
+		{Path: "id", Types: []string{"integer"}},
+		{Path: "level", Types: []string{"string"}},
+		{Path: "title", Types: []string{"string"}, NullRate: 0.4},
+		{Path: "userId", Types: []string{"string"}},
+	}}
+
+	changes := Diff(baseline, current, 0.25)
+	kinds := make(map[string]string)
+	for _, change := range changes {
+		kinds[change.Path] = change.Kind
+	}
+
+	expected := map[string]string{
+		"legacy": models.FieldRemoved,
+		"level":  models.FieldAdded,
+		"title":  models.FieldNullRateChanged,
+		"userId": models.FieldTypeChanged,
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Expected changes %v, got %v", expected, kinds)
+	}
+
+	// Small null rate moves are ignored
+	if changes := Diff(baseline, baseline, 0.25); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestDetectorObserve(t *testing.T) {
+	store := &memoryStore{schemas: make(map[string]models.SourceSchema)}
+	detector := NewDetector(store, 0.25)
+	ctx := context.Background()
+
+	// The first run sets the baseline
+	changes, err := detector.Observe(ctx, "posts", decodePosts(t, `[{"id": 1, "userId": 1}]`))
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("Expected no changes on the first run, got %v, %v", changes, err)
+	}
+	if _, ok := store.schemas["posts"]; !ok {
+		t.Fatal("Expected the baseline to be saved")
+	}
+
+	// A retyped field is reported once
+	changes, err = detector.Observe(ctx, "posts", decodePosts(t, `[{"id": 1, "userId": "1"}]`))
+	if err != nil {
+		t.Fatalf("Failed to observe: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Kind != models.FieldTypeChanged || len(store.events) != 1 {
+		t.Fatalf("Expected a single type change event, got %+v", changes)
+	}
+
+	changes, _ = detector.Observe(ctx, "posts", decodePosts(t, `[{"id": 2, "userId": "2"}]`))
+	if len(changes) != 0 || len(store.events) != 1 {
+		t.Errorf("Expected the new baseline to be kept, got %+v", changes)
+	}
+
+	// Empty runs are ignored
+	if changes, _ := detector.Observe(ctx, "posts", nil); len(changes) != 0 {
+		t.Errorf("Expected no changes for an empty run, got %+v", changes)
+	}
+}
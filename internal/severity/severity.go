@@ -0,0 +1,136 @@
+package severity
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Level is a normalized severity using the OpenTelemetry severity numbers
+type Level int
+
+// Severity levels, each set to the first number of its OpenTelemetry range
+const (
+	Unspecified Level = 0
+	Trace       Level = 1
+	Debug       Level = 5
+	Info        Level = 9
+	Warn        Level = 13
+	Error       Level = 17
+	Fatal       Level = 21
+)
+
+// Scheme describes how a bare number maps onto a severity level
+type Scheme string
+
+// Supported numeric schemes
+const (
+	// SchemeSyslog maps the RFC 5424 levels 0 (emergency) to 7 (debug)
+	SchemeSyslog Scheme = "syslog"
+	// SchemeOTel maps the OpenTelemetry severity numbers 1 to 24
+	SchemeOTel Scheme = "otel"
+	// SchemeBunyan maps the bunyan/pino levels 10 (trace) to 60 (fatal)
+	SchemeBunyan Scheme = "bunyan"
+)
+
+var names = map[Level]string{
+	Trace: "trace",
+	Debug: "debug",
+	Info:  "info",
+	Warn:  "warn",
+	Error: "error",
+	Fatal: "fatal",
+}
+
+var aliases = map[string]Level{
+	"trace":         Trace,
+	"finest":        Trace,
+	"verbose":       Trace,
+	"debug":         Debug,
+	"dbg":           Debug,
+	"fine":          Debug,
+	"info":          Info,
+	"information":   Info,
+	"informational": Info,
+	"notice":        Info,
+	"inf":           Info,
+	"warn":          Warn,
+	"warning":       Warn,
+	"wrn":           Warn,
+	"error":         Error,
+	"err":           Error,
+	"severe":        Error,
+	"fatal":         Fatal,
+	"critical":      Fatal,
+	"crit":          Fatal,
+	"alert":         Fatal,
+	"emergency":     Fatal,
+	"emerg":         Fatal,
+	"panic":         Fatal,
+}
+
+// String returns the lowercase name of the level
+func (l Level) String() string {
+	if name, ok := names[l]; ok {
+		return name
+	}
+	return ""
+}
+
+// Parse converts a level name such as "ERR" or "Warning" into a Level
+func Parse(s string) (Level, bool) {
+	level, ok := aliases[strings.ToLower(strings.TrimSpace(s))]
+	return level, ok
+}
+
+// FromNumber converts a numeric level using the given scheme
+func FromNumber(n int, scheme Scheme) (Level, bool) {
+	switch scheme {
+	case SchemeOTel:
+		if n < 1 || n > 24 {
+			return Unspecified, false
+		}
+		// Collapse TRACE2..TRACE4 and friends onto the base of their range
+		return Level((n-1)/4*4 + 1), true
+	case SchemeBunyan:
+		switch {
+		case n >= 60:
+			return Fatal, true
+		case n >= 50:
+			return Error, true
+		case n >= 40:
+			return Warn, true
+		case n >= 30:
+			return Info, true
+		case n >= 20:
+			return Debug, true
+		case n >= 10:
+			return Trace, true
+		}
+		return Unspecified, false
+	default:
+		switch {
+		case n >= 0 && n <= 2:
+			return Fatal, true
+		case n == 3:
+			return Error, true
+		case n == 4:
+			return Warn, true
+		case n == 5 || n == 6:
+			return Info, true
+		case n == 7:
+			return Debug, true
+		}
+		return Unspecified, false
+	}
+}
+
+// ParseValue converts a name or a number, given as text, into a Level
+func ParseValue(s string, scheme Scheme) (Level, bool) {
+	if level, ok := Parse(s); ok {
+		return level, true
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return FromNumber(n, scheme)
+	}
+	return Unspecified, false
+}
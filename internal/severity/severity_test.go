@@ -0,0 +1,81 @@
+package severity
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := map[string]Level{
+		"ERR":     Error,
+		"error":   Error,
+		"Warning": Warn,
+		" info ":  Info,
+		"fatal":   Fatal,
+		"CRIT":    Fatal,
+		"trace":   Trace,
+		"debug":   Debug,
+	}
+
+	for input, want := range tests {
+		got, ok := Parse(input)
+		if !ok {
+			t.Errorf("Expected %q to parse", input)
+			continue
+		}
+		if got != want {
+			t.Errorf("Expected %q to parse as %s, got %s", input, want, got)
+		}
+	}
+
+	if _, ok := Parse("loud"); ok {
+		t.Error("Expected unknown level not to parse")
+	}
+}
+
+func TestFromNumber(t *testing.T) {
+	tests := []struct {
+		n      int
+		scheme Scheme
+		want   Level
+	}{
+		{3, SchemeSyslog, Error},
+		{4, SchemeSyslog, Warn},
+		{6, SchemeSyslog, Info},
+		{7, SchemeSyslog, Debug},
+		{0, SchemeSyslog, Fatal},
+		{17, SchemeOTel, Error},
+		{19, SchemeOTel, Error},
+		{24, SchemeOTel, Fatal},
+		{1, SchemeOTel, Trace},
+		{30, SchemeBunyan, Info},
+		{50, SchemeBunyan, Error},
+		{60, SchemeBunyan, Fatal},
+	}
+
+	for _, tt := range tests {
+		got, ok := FromNumber(tt.n, tt.scheme)
+		if !ok {
+			t.Errorf("Expected %d (%s) to map to a level", tt.n, tt.scheme)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Expected %d (%s) to map to %s, got %s", tt.n, tt.scheme, tt.want, got)
+		}
+	}
+
+	for _, tt := range []struct {
+		n      int
+		scheme Scheme
+	}{{8, SchemeSyslog}, {25, SchemeOTel}, {5, SchemeBunyan}} {
+		if level, ok := FromNumber(tt.n, tt.scheme); ok {
+			t.Errorf("Expected %d (%s) not to map, got %s", tt.n, tt.scheme, level)
+		}
+	}
+}
+
+func TestLevelOrdering(t *testing.T) {
+	ordered := []Level{Trace, Debug, Info, Warn, Error, Fatal}
+	for i := 1; i < len(ordered); i++ {
+		if ordered[i-1] >= ordered[i] {
+			t.Errorf("Expected %s to be below %s", ordered[i-1], ordered[i])
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package shard
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// virtualNodes is the number of points each member has on the ring, which
+// evens out the share of sources of each member
+const virtualNodes = 100
+
+// Ring assigns keys to members by consistent hashing. When a member joins
+// or leaves, only the keys it gains or loses move.
+type Ring struct {
+	points  []uint64
+	members map[uint64]string
+}
+
+// NewRing creates a new Ring over the given members
+func NewRing(members []string) *Ring {
+	ring := &Ring{
+		points:  make([]uint64, 0, len(members)*virtualNodes),
+		members: make(map[uint64]string, len(members)*virtualNodes),
+	}
+	for _, member := range members {
+		for i := 0; i < virtualNodes; i++ {
+			point := hash(member + "#" + strconv.Itoa(i))
+			// On the rare collision the lowest member ID keeps the point,
+			// whatever the order the members are listed in
+			owner, taken := ring.members[point]
+			if !taken {
+				ring.points = append(ring.points, point)
+			}
+			if !taken || member < owner {
+				ring.members[point] = member
+			}
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+// Owner returns the member a key is assigned to, the first member point
+// clockwise from the key, or an empty string on an empty ring
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	point := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= point })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.members[r.points[i]]
+}
+
+// hash places a key on the ring. Member and source names are short and
+// similar, so a hash mixing every byte into every bit keeps the points
+// evenly spread.
+func hash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,270 @@
+// Package shard splits the sources between the replicas. Each replica sends
+// heartbeats to the database and sources are assigned to the live replicas
+// by consistent hashing, so they move when a replica joins or dies. Each
+// source is also fenced by a lease, so a source moving to another replica
+// does not start there before its previous owner stopped it.
+package shard
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Store keeps the membership of the replicas and the leases of the sources
+type Store interface {
+	Heartbeat(ctx context.Context, member models.Member) error
+	GetLiveMembers(ctx context.Context, now time.Time) ([]models.Member, error)
+	RemoveMember(ctx context.Context, id string) error
+	// AcquireLease takes or renews a lease, reporting whether the holder
+	// now holds it
+	AcquireLease(ctx context.Context, lease models.Lease) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// Coordinator runs the sources assigned to a replica
+type Coordinator struct {
+	store   Store
+	id      string
+	sources []string
+	ttl     time.Duration
+	started time.Time
+	now     func() time.Time
+
+	// assigned are the sources of this replica as of its last heartbeat,
+	// expiring at expiresAt
+	assigned  []string
+	expiresAt time.Time
+
+	// leases are the source leases held by this replica
+	leases map[string]*models.Lease
+}
+
+// NewCoordinator creates a new Coordinator instance for the replica id.
+// Its membership expires ttl after each heartbeat, sent three times per
+// ttl.
+func NewCoordinator(store Store, id string, sources []string, ttl time.Duration) *Coordinator {
+	return &Coordinator{
+		store:   store,
+		id:      id,
+		sources: sources,
+		ttl:     ttl,
+		started: time.Now().UTC(),
+		now:     time.Now,
+		leases:  make(map[string]*models.Lease),
+	}
+}
+
+// Run sends heartbeats until ctx is done, running each source while it is
+// assigned to this replica and it holds the lease of the source. The
+// context of a source run is cancelled when the source moves to another
+// replica, and its lease released once the run returned, so the new owner
+// starts it on its next heartbeat. On shutdown the sources are stopped, the
+// leases released and the membership removed, so the other replicas take
+// them over on their next heartbeat.
+func (c *Coordinator) Run(ctx context.Context, run func(ctx context.Context, source string)) {
+	ticker := time.NewTicker(c.ttl / 3)
+	defer ticker.Stop()
+
+	// running holds the function stopping each running source
+	running := make(map[string]func())
+
+	for {
+		owned := make(map[string]bool)
+		assigned := c.step(ctx)
+		for _, source := range assigned {
+			owned[source] = true
+		}
+		for source, stop := range running {
+			if !owned[source] {
+				log.Printf("Source %s moved away from %s", source, c.id)
+				stop()
+				delete(running, source)
+			}
+		}
+		c.releaseMoved(owned)
+
+		for _, source := range assigned {
+			_, ok := running[source]
+			switch fenced := c.fence(ctx, source); {
+			case fenced && !ok:
+				log.Printf("Source %s assigned to %s", source, c.id)
+				running[source] = start(ctx, source, run)
+			case !fenced && ok:
+				log.Printf("Lost lease of source %s as %s", source, c.id)
+				running[source]()
+				delete(running, source)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			for _, stop := range running {
+				stop()
+			}
+			c.releaseMoved(nil)
+			c.leave()
+			return
+		}
+	}
+}
+
+// start runs a source in the background and returns a function cancelling
+// it and waiting for it to return
+func start(ctx context.Context, source string, run func(ctx context.Context, source string)) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx, source)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// step sends a heartbeat and returns the sources assigned to this replica
+func (c *Coordinator) step(ctx context.Context) []string {
+	now := c.now().UTC()
+
+	members, err := c.store.GetLiveMembers(ctx, now)
+	if err != nil {
+		return c.keep(now, err)
+	}
+
+	// This replica is live whether or not its membership expired
+	ids := []string{c.id}
+	for _, member := range members {
+		if member.ID != c.id {
+			ids = append(ids, member.ID)
+		}
+	}
+	assigned := Assign(c.sources, ids)[c.id]
+
+	member := models.Member{
+		ID:          c.id,
+		StartedAt:   c.started,
+		HeartbeatAt: now,
+		ExpiresAt:   now.Add(c.ttl),
+		Sources:     assigned,
+	}
+	if err := c.store.Heartbeat(ctx, member); err != nil {
+		return c.keep(now, err)
+	}
+
+	c.assigned = assigned
+	c.expiresAt = member.ExpiresAt
+	return assigned
+}
+
+// fence acquires or renews the lease of a source and reports whether this
+// replica holds it. A source moving here is only taken once its previous
+// owner released the lease, or the lease expired.
+func (c *Coordinator) fence(ctx context.Context, source string) bool {
+	now := c.now().UTC()
+	lease := models.Lease{
+		Name:       leaseName(source),
+		Holder:     c.id,
+		AcquiredAt: now,
+		RenewedAt:  now,
+		ExpiresAt:  now.Add(c.ttl),
+	}
+	held := c.leases[source]
+	if held != nil {
+		lease.AcquiredAt = held.AcquiredAt
+	}
+
+	acquired, err := c.store.AcquireLease(ctx, lease)
+	if err != nil {
+		log.Printf("Error renewing lease of source %s: %v", source, err)
+		// As with the membership, the source is given up a third of the ttl
+		// before its lease expires
+		return held != nil && now.Before(held.ExpiresAt.Add(-c.ttl/3))
+	}
+	if !acquired {
+		delete(c.leases, source)
+		return false
+	}
+
+	c.leases[source] = &lease
+	return true
+}
+
+// releaseMoved releases the leases of the sources that are not owned, all
+// of them when owned is nil. The sources must be stopped first. It does not
+// use the run context, which may be done by then.
+func (c *Coordinator) releaseMoved(owned map[string]bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for source := range c.leases {
+		if owned[source] {
+			continue
+		}
+		if err := c.store.ReleaseLease(ctx, leaseName(source), c.id); err != nil {
+			log.Printf("Error releasing lease of source %s: %v", source, err)
+		}
+		delete(c.leases, source)
+	}
+}
+
+// leaseName returns the name of the lease fencing a source
+func leaseName(source string) string {
+	return "source/" + source
+}
+
+// keep returns the sources assigned before a failed heartbeat. The other
+// replicas take them over once the membership expires, so they are given
+// up a third of the ttl early to allow for clock skew.
+func (c *Coordinator) keep(now time.Time, err error) []string {
+	log.Printf("Error sending heartbeat of %s: %v", c.id, err)
+	if now.Before(c.expiresAt.Add(-c.ttl / 3)) {
+		return c.assigned
+	}
+	c.assigned = nil
+	return nil
+}
+
+// leave removes the membership of this replica. It does not use the run
+// context, which is done by then.
+func (c *Coordinator) leave() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.store.RemoveMember(ctx, c.id); err != nil {
+		log.Printf("Error removing member %s: %v", c.id, err)
+		return
+	}
+	log.Printf("Member %s left", c.id)
+}
+
+// Members returns the live replicas and their sources
+func (c *Coordinator) Members(ctx interface{}) ([]models.Member, error) {
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	return c.store.GetLiveMembers(ctxValue, c.now().UTC())
+}
+
+// Assign maps each member to its sources, sorted
+func Assign(sources, members []string) map[string][]string {
+	ring := NewRing(members)
+	assignment := make(map[string][]string, len(members))
+	for _, source := range sources {
+		owner := ring.Owner(source)
+		assignment[owner] = append(assignment[owner], source)
+	}
+	for _, owned := range assignment {
+		sort.Strings(owned)
+	}
+	return assignment
+}
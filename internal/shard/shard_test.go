@@ -0,0 +1,322 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryStore keeps members and leases in memory
+type memoryStore struct {
+	mu      sync.Mutex
+	members map[string]models.Member
+	leases  map[string]models.Lease
+	err     error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{members: make(map[string]models.Member), leases: make(map[string]models.Lease)}
+}
+
+func (s *memoryStore) Heartbeat(ctx context.Context, member models.Member) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.members[member.ID] = member
+	return nil
+}
+
+func (s *memoryStore) GetLiveMembers(ctx context.Context, now time.Time) ([]models.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	var members []models.Member
+	for _, member := range s.members {
+		if member.ExpiresAt.After(now) {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	return members, nil
+}
+
+func (s *memoryStore) RemoveMember(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.members, id)
+	return nil
+}
+
+func (s *memoryStore) AcquireLease(ctx context.Context, lease models.Lease) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	held, ok := s.leases[lease.Name]
+	if ok && held.Holder != lease.Holder && held.ExpiresAt.After(lease.RenewedAt) {
+		return false, nil
+	}
+	s.leases[lease.Name] = lease
+	return true, nil
+}
+
+func (s *memoryStore) ReleaseLease(ctx context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[name].Holder == holder {
+		delete(s.leases, name)
+	}
+	return nil
+}
+
+func sourceNames(n int) []string {
+	sources := make([]string, n)
+	for i := range sources {
+		sources[i] = fmt.Sprintf("source_%d", i)
+	}
+	return sources
+}
+
+func TestAssign(t *testing.T) {
+	sources := sourceNames(300)
+
+	before := Assign(sources, []string{"a", "b", "c"})
+	total := 0
+	for member, owned := range before {
+		if len(owned) < 50 {
+			t.Errorf("Expected member %s to own a fair share of 300 sources, got %d", member, len(owned))
+		}
+		total += len(owned)
+	}
+	if total != len(sources) {
+		t.Errorf("Expected every source to be assigned once, got %d", total)
+	}
+
+	// The order members are listed in does not matter
+	if again := Assign(sources, []string{"c", "a", "b"}); !reflect.DeepEqual(before, again) {
+		t.Errorf("Expected the same assignment for the same members")
+	}
+
+	// Only the sources of the new member move
+	after := Assign(sources, []string{"a", "b", "c", "d"})
+	owner := make(map[string]string)
+	for member, owned := range before {
+		for _, source := range owned {
+			owner[source] = member
+		}
+	}
+	for member, owned := range after {
+		for _, source := range owned {
+			if member != "d" && owner[source] != member {
+				t.Errorf("Expected source %s to stay with %s, moved to %s", source, owner[source], member)
+			}
+		}
+	}
+	if len(after["d"]) == 0 {
+		t.Errorf("Expected the new member to take sources")
+	}
+}
+
+func TestStep(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	sources := sourceNames(20)
+
+	a := NewCoordinator(store, "a", sources, 30*time.Second)
+	b := NewCoordinator(store, "b", sources, 30*time.Second)
+	a.now, b.now = clock, clock
+	ctx := context.Background()
+
+	if owned := a.step(ctx); len(owned) != len(sources) {
+		t.Fatalf("Expected the only member to own every source, got %v", owned)
+	}
+
+	// b joins, a sees it on its next heartbeat
+	now = now.Add(10 * time.Second)
+	ownedB := b.step(ctx)
+	ownedA := a.step(ctx)
+	want := Assign(sources, []string{"a", "b"})
+	if !reflect.DeepEqual(ownedA, want["a"]) || !reflect.DeepEqual(ownedB, want["b"]) {
+		t.Errorf("Expected the sources to be split as %v, got %v and %v", want, ownedA, ownedB)
+	}
+
+	members, err := a.Members(ctx)
+	if err != nil || len(members) != 2 || !reflect.DeepEqual(members[1].Sources, ownedB) {
+		t.Errorf("Expected both members with their sources, got %+v and %v", members, err)
+	}
+
+	// a keeps its sources through a short outage, then gives them up
+	store.err = errors.New("unreachable")
+	now = now.Add(15 * time.Second)
+	if owned := a.step(ctx); !reflect.DeepEqual(owned, ownedA) {
+		t.Errorf("Expected a to keep its sources within its membership, got %v", owned)
+	}
+	now = now.Add(10 * time.Second)
+	if owned := a.step(ctx); owned != nil {
+		t.Errorf("Expected a to give up its sources, got %v", owned)
+	}
+
+	// b takes every source once a expired
+	store.err = nil
+	now = now.Add(10 * time.Second)
+	if owned := b.step(ctx); len(owned) != len(sources) {
+		t.Errorf("Expected b to take over every source, got %v", owned)
+	}
+}
+
+func TestRunHandoff(t *testing.T) {
+	store := newMemoryStore()
+	sources := sourceNames(10)
+	a := NewCoordinator(store, "a", sources, 30*time.Millisecond)
+	b := NewCoordinator(store, "b", sources, 30*time.Millisecond)
+
+	// running holds the members running each source
+	var mu sync.Mutex
+	running := make(map[string]map[string]bool)
+	run := func(member string) func(ctx context.Context, source string) {
+		return func(ctx context.Context, source string) {
+			mu.Lock()
+			if running[source] == nil {
+				running[source] = make(map[string]bool)
+			}
+			running[source][member] = true
+			mu.Unlock()
+			<-ctx.Done()
+			mu.Lock()
+			delete(running[source], member)
+			mu.Unlock()
+		}
+	}
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() {
+		defer close(doneA)
+		a.Run(ctxA, run("a"))
+	}()
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	doneB := make(chan struct{})
+	go func() {
+		defer close(doneB)
+		b.Run(ctxB, run("b"))
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	want := Assign(sources, []string{"a", "b"})
+	for member, owned := range want {
+		for _, source := range owned {
+			if len(running[source]) != 1 || !running[source][member] {
+				t.Errorf("Expected source %s to run on %s, got %v", source, member, running[source])
+			}
+		}
+	}
+	mu.Unlock()
+
+	// a leaves and b takes its sources over
+	cancelA()
+	<-doneA
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	for _, source := range sources {
+		if len(running[source]) != 1 || !running[source]["b"] {
+			t.Errorf("Expected source %s to run on b, got %v", source, running[source])
+		}
+	}
+	mu.Unlock()
+
+	cancelB()
+	<-doneB
+	if len(store.members) != 0 || len(store.leases) != 0 {
+		t.Errorf("Expected both members to leave and release their leases, got %+v and %+v", store.members, store.leases)
+	}
+}
+
+func TestRunFencing(t *testing.T) {
+	store := newMemoryStore()
+	sources := sourceNames(10)
+	a := NewCoordinator(store, "a", sources, 60*time.Millisecond)
+	b := NewCoordinator(store, "b", sources, 60*time.Millisecond)
+
+	// running holds the member running each source, and overlaps the
+	// sources that ran on both members at once
+	var mu sync.Mutex
+	running := make(map[string]string)
+	var overlaps []string
+	run := func(member string) func(ctx context.Context, source string) {
+		return func(ctx context.Context, source string) {
+			mu.Lock()
+			if other, ok := running[source]; ok {
+				overlaps = append(overlaps, fmt.Sprintf("%s on %s and %s", source, other, member))
+			}
+			running[source] = member
+			mu.Unlock()
+
+			// A run takes a while to stop
+			<-ctx.Done()
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			if running[source] == member {
+				delete(running, source)
+			}
+			mu.Unlock()
+		}
+	}
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() {
+		defer close(doneA)
+		a.Run(ctxA, run("a"))
+	}()
+	time.Sleep(30 * time.Millisecond)
+
+	// b joins while a runs every source, then a leaves
+	ctxB, cancelB := context.WithCancel(context.Background())
+	doneB := make(chan struct{})
+	go func() {
+		defer close(doneB)
+		b.Run(ctxB, run("b"))
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	want := Assign(sources, []string{"a", "b"})
+	for member, owned := range want {
+		for _, source := range owned {
+			if running[source] != member {
+				t.Errorf("Expected source %s to run on %s, got %q", source, member, running[source])
+			}
+		}
+	}
+	mu.Unlock()
+
+	cancelA()
+	<-doneA
+	time.Sleep(60 * time.Millisecond)
+	cancelB()
+	<-doneB
+
+	if len(overlaps) > 0 {
+		t.Errorf("Expected no source to run on both members at once, got %v", overlaps)
+	}
+	if len(running) != 0 {
+		t.Errorf("Expected every source to stop, got %v", running)
+	}
+}
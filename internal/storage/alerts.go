@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// alertCollection holds the state of each alert, keyed by rule and source
+const alertCollection = "alerts"
+
+// FireAlert records an alert as firing unless it already is, and reports
+// whether it did. Replicas evaluating the same rule race on the update, so
+// only one of them notifies.
+func (s *Storage) FireAlert(ctx context.Context, alert models.Alert) (bool, error) {
+	collection := s.client.Database(s.database).Collection(alertCollection)
+
+	alert.State = models.AlertFiring
+	alert.ResolvedAt = nil
+	query := bson.M{"_id": alert.ID, "state": bson.M{"$ne": models.AlertFiring}}
+	// A firing alert does not match the query, and the upsert then fails on
+	// the existing _id
+	_, err := collection.ReplaceOne(ctx, query, alert, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to fire alert %s: %w", alert.ID, err)
+	}
+
+	return true, nil
+}
+
+// ResolveAlert records a firing alert as resolved, pending delivery to the
+// channels, and returns it, or nil if the alert was not firing
+func (s *Storage) ResolveAlert(ctx context.Context, id string, at time.Time, pending []string, retryAt time.Time) (*models.Alert, error) {
+	collection := s.client.Database(s.database).Collection(alertCollection)
+
+	query := bson.M{"_id": id, "state": models.AlertFiring}
+	update := bson.M{
+		"$set": bson.M{
+			"state":       models.AlertResolved,
+			"resolved_at": at,
+			"pending":     pending,
+			"attempts":    1,
+			"retry_at":    retryAt,
+		},
+		"$unset": bson.M{"delivery_error": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var alert models.Alert
+	err := collection.FindOneAndUpdate(ctx, query, update, opts).Decode(&alert)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to resolve alert %s: %w", id, err)
+	}
+
+	return &alert, nil
+}
+
+// GetUndeliveredAlerts returns the alerts with channels pending delivery
+// whose retry is due
+func (s *Storage) GetUndeliveredAlerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	collection := s.client.Database(s.database).Collection(alertCollection)
+
+	query := bson.M{"pending.0": bson.M{"$exists": true}, "retry_at": bson.M{"$lte": now}}
+	cursor, err := collection.Find(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query undelivered alerts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var alerts []models.Alert
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, fmt.Errorf("failed to decode undelivered alerts: %w", err)
+	}
+
+	return alerts, nil
+}
+
+// ClaimDelivery takes the next delivery attempt of an alert and moves its
+// retry to retryAt, reporting whether it did. The attempt is taken only if
+// the alert is unchanged since it was read, so a single replica retries.
+func (s *Storage) ClaimDelivery(ctx context.Context, alert models.Alert, retryAt time.Time) (bool, error) {
+	collection := s.client.Database(s.database).Collection(alertCollection)
+
+	update := bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"retry_at": retryAt}}
+	result, err := collection.UpdateOne(ctx, deliveryQuery(alert), update)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim delivery of alert %s: %w", alert.ID, err)
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// RecordDelivery records the outcome of a delivery attempt: the channels
+// still pending and the error of the attempt. Nothing is recorded if the
+// alert changed state or was claimed again since the attempt started.
+func (s *Storage) RecordDelivery(ctx context.Context, alert models.Alert, pending []string, deliveryErr string) error {
+	collection := s.client.Database(s.database).Collection(alertCollection)
+
+	update := bson.M{"$set": bson.M{"pending": pending, "delivery_error": deliveryErr}}
+	if len(pending) == 0 {
+		update = bson.M{"$unset": bson.M{"pending": "", "retry_at": "", "delivery_error": ""}}
+	}
+	if _, err := collection.UpdateOne(ctx, deliveryQuery(alert), update); err != nil {
+		return fmt.Errorf("failed to record delivery of alert %s: %w", alert.ID, err)
+	}
+
+	return nil
+}
+
+// deliveryQuery matches an alert in the state and delivery attempt it was
+// read in
+func deliveryQuery(alert models.Alert) bson.M {
+	return bson.M{
+		"_id":      alert.ID,
+		"state":    alert.State,
+		"fired_at": alert.FiredAt,
+		"attempts": alert.Attempts,
+	}
+}
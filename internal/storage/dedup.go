@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexOptionsConflict is the code of the error creating an index that
+// exists with other options
+const indexOptionsConflict = 85
+
+// FingerprintWindow is an exact dedup window that keeps every fingerprint
+// seen within the window in MongoDB, expired by a TTL index
+type FingerprintWindow struct {
+	collection *mongo.Collection
+	window     time.Duration
+}
+
+// FingerprintWindow returns an exact dedup window stored in the named collection
+func (s *Storage) FingerprintWindow(ctx context.Context, name string, window time.Duration) (*FingerprintWindow, error) {
+	collection := s.client.Database(s.database).Collection(name)
+
+	// Documents are removed once they have not been seen for a whole window
+	expireAfter := int32(window.Seconds())
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "last_seen", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(expireAfter),
+	})
+	// The index exists with the expiry of another window when the window
+	// changed since the last deploy
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == indexOptionsConflict {
+		err = s.client.Database(s.database).RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: name},
+			{Key: "index", Value: bson.D{
+				{Key: "keyPattern", Value: bson.D{{Key: "last_seen", Value: 1}}},
+				{Key: "expireAfterSeconds", Value: expireAfter},
+			}},
+		}).Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fingerprint index: %w", err)
+	}
+
+	return &FingerprintWindow{collection: collection, window: window}, nil
+}
+
+// Contains reports whether the fingerprint was committed within the window.
+// The TTL monitor removes expired fingerprints lazily, so they are checked
+// against the window as well.
+func (w *FingerprintWindow) Contains(ctx context.Context, fingerprint string) (bool, error) {
+	filter := bson.M{
+		"_id":       fingerprint,
+		"last_seen": bson.M{"$gt": time.Now().UTC().Add(-w.window)},
+	}
+
+	err := w.collection.FindOne(ctx, filter).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check fingerprint: %w", err)
+	}
+	return true, nil
+}
+
+// Commit records the fingerprints in the window, counting each time a
+// fingerprint is repeated
+func (w *FingerprintWindow) Commit(ctx context.Context, fingerprints []string) error {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		counts[fingerprint]++
+	}
+
+	now := time.Now().UTC()
+	writes := make([]mongo.WriteModel, 0, len(counts))
+	for fingerprint, count := range counts {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": fingerprint}).
+			SetUpdate(bson.M{
+				"$setOnInsert": bson.M{"first_seen": now},
+				"$set":         bson.M{"last_seen": now},
+				"$inc":         bson.M{"count": count},
+			}).
+			SetUpsert(true))
+	}
+
+	if _, err := w.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to record fingerprints: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// historyCollection holds the versions of upstream records
+const historyCollection = "post_versions"
+
+// historyRegistry decodes nested payload objects as maps instead of
+// primitive.D so versions can be compared field by field
+var historyRegistry = func() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	registry.RegisterTypeMapEntry(bsontype.EmbeddedDocument, reflect.TypeOf(map[string]interface{}{}))
+	return registry
+}()
+
+// versions returns the collection of record versions
+func (s *Storage) versions() *mongo.Collection {
+	return s.client.Database(s.database).Collection(historyCollection, options.Collection().SetRegistry(historyRegistry))
+}
+
+// GetCurrentVersions returns the current version of the given upstream
+// records of a source, leaving out the records never seen
+func (s *Storage) GetCurrentVersions(ctx context.Context, source string, postIDs []int) ([]models.PostVersion, error) {
+	query := bson.M{
+		"source":   source,
+		"post_id":  bson.M{"$in": postIDs},
+		"valid_to": nil,
+	}
+
+	cursor, err := s.versions().Find(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find current versions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var versions []models.PostVersion
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, fmt.Errorf("failed to decode current versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// SaveVersions inserts new versions, closing the versions they replace
+func (s *Storage) SaveVersions(ctx context.Context, versions []models.PostVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	var writes []mongo.WriteModel
+	for _, version := range versions {
+		if version.Version > 1 {
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"source": version.Source, "post_id": version.PostID, "version": version.Version - 1}).
+				SetUpdate(bson.M{"$set": bson.M{"valid_to": version.ValidFrom}}))
+		}
+		writes = append(writes, mongo.NewInsertOneModel().SetDocument(version))
+	}
+
+	if _, err := s.versions().BulkWrite(ctx, writes); err != nil {
+		return fmt.Errorf("failed to save versions: %w", err)
+	}
+
+	return nil
+}
+
+// GetHistory retrieves the versions of an upstream record, most recent first
+func (s *Storage) GetHistory(ctx interface{}, source string, postID int) ([]models.PostVersion, error) {
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := s.versions().Find(ctxValue, bson.M{"source": source, "post_id": postID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find versions: %w", err)
+	}
+	defer cursor.Close(ctxValue)
+
+	var versions []models.PostVersion
+	if err := cursor.All(ctxValue, &versions); err != nil {
+		return nil, fmt.Errorf("failed to decode versions: %w", err)
+	}
+
+	return versions, nil
+}
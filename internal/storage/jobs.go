@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// jobCollection holds the backfill jobs and their progress
+const jobCollection = "jobs"
+
+// CreateJob stores a new job and returns it with its ID
+func (s *Storage) CreateJob(ctx context.Context, job models.Job) (models.Job, error) {
+	collection := s.client.Database(s.database).Collection(jobCollection)
+
+	job.ID = primitive.NewObjectID()
+	if _, err := collection.InsertOne(ctx, job); err != nil {
+		return job, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return job, nil
+}
+
+// GetJob returns a job by its ID, or nil if there is none
+func (s *Storage) GetJob(ctx context.Context, id primitive.ObjectID) (*models.Job, error) {
+	collection := s.client.Database(s.database).Collection(jobCollection)
+
+	var job models.Job
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get job %s: %w", id.Hex(), err)
+	}
+
+	return &job, nil
+}
+
+// GetJobs returns the jobs matching the filter, most recent first
+func (s *Storage) GetJobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	collection := s.client.Database(s.database).Collection(jobCollection)
+
+	query := bson.M{}
+	if filter.Source != "" {
+		query["source"] = filter.Source
+	}
+	if filter.State != "" {
+		query["state"] = filter.State
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get jobs: %w", err)
+	}
+
+	jobs := []models.Job{}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to decode jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// ClaimJob hands the oldest pending job, or the oldest running job whose
+// owner let its lease expire, to owner until expiresAt. It returns nil when
+// there is no job to run. Replicas race on the update, so each job is
+// claimed by one of them.
+func (s *Storage) ClaimJob(ctx context.Context, owner string, now, expiresAt time.Time) (*models.Job, error) {
+	collection := s.client.Database(s.database).Collection(jobCollection)
+
+	query := bson.M{"$or": bson.A{
+		bson.M{"state": models.JobPending},
+		bson.M{"state": models.JobRunning, "lease_expires_at": bson.M{"$lte": now}},
+	}}
+	// A pipeline update keeps the start time of a resumed job
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"state":            models.JobRunning,
+		"owner":            owner,
+		"lease_expires_at": expiresAt,
+		"updated_at":       now,
+		"started_at":       bson.M{"$ifNull": bson.A{"$started_at", now}},
+	}}}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.Job
+	err := collection.FindOneAndUpdate(ctx, query, update, opts).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// CheckpointJob records the progress of a running job and extends the lease
+// of its owner. It returns the job as stored, carrying any cancellation
+// request, or nil if the owner no longer holds it.
+func (s *Storage) CheckpointJob(ctx context.Context, job models.Job) (*models.Job, error) {
+	collection := s.client.Database(s.database).Collection(jobCollection)
+
+	query := bson.M{"_id": job.ID, "owner": job.Owner, "state": models.JobRunning}
+	update := bson.M{"$set": bson.M{
+		"cursor":           job.Cursor,
+		"chunks_done":      job.ChunksDone,
+		"fetched":          job.Fetched,
+		"stored":           job.Stored,
+		"rejected":         job.Rejected,
+		"lease_expires_at": job.LeaseExpiresAt,
+		"updated_at":       job.UpdatedAt,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var stored models.Job
+	err := collection.FindOneAndUpdate(ctx, query, update, opts).Decode(&stored)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to checkpoint job %s: %w", job.ID.Hex(), err)
+	}
+
+	return &stored, nil
+}
+
+// FinishJob records the final state of a job if its owner still holds it
+func (s *Storage) FinishJob(ctx context.Context, job models.Job) error {
+	collection := s.client.Database(s.database).Collection(jobCollection)
+
+	query := bson.M{"_id": job.ID, "owner": job.Owner}
+	update := bson.M{
+		"$set": bson.M{
+			"state":       job.State,
+			"error":       job.Error,
+			"cursor":      job.Cursor,
+			"chunks_done": job.ChunksDone,
+			"fetched":     job.Fetched,
+			"stored":      job.Stored,
+			"rejected":    job.Rejected,
+			"updated_at":  job.UpdatedAt,
+			"finished_at": job.FinishedAt,
+		},
+		"$unset": bson.M{"owner": "", "lease_expires_at": ""},
+	}
+	if _, err := collection.UpdateOne(ctx, query, update); err != nil {
+		return fmt.Errorf("failed to finish job %s: %w", job.ID.Hex(), err)
+	}
+
+	return nil
+}
+
+// ReleaseJob hands a running job back to the queue if owner still holds
+// it, for any replica to resume from its last checkpoint
+func (s *Storage) ReleaseJob(ctx context.Context, id primitive.ObjectID, owner string, now time.Time) error {
+	collection := s.client.Database(s.database).Collection(jobCollection)
+
+	query := bson.M{"_id": id, "owner": owner, "state": models.JobRunning}
+	update := bson.M{
+		"$set":   bson.M{"state": models.JobPending, "updated_at": now},
+		"$unset": bson.M{"owner": "", "lease_expires_at": ""},
+	}
+	if _, err := collection.UpdateOne(ctx, query, update); err != nil {
+		return fmt.Errorf("failed to release job %s: %w", id.Hex(), err)
+	}
+
+	return nil
+}
+
+// CancelJob cancels a pending job right away and asks a running one to stop
+// at its next checkpoint. It returns the updated job, or nil if the job is
+// not pending or running.
+func (s *Storage) CancelJob(ctx context.Context, id primitive.ObjectID, now time.Time) (*models.Job, error) {
+	collection := s.client.Database(s.database).Collection(jobCollection)
+
+	query := bson.M{"_id": id, "state": bson.M{"$in": bson.A{models.JobPending, models.JobRunning}}}
+	pending := bson.M{"$eq": bson.A{"$state", models.JobPending}}
+	// Every expression of the stage reads the job as it was before the update
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"state":            bson.M{"$cond": bson.A{pending, models.JobCancelled, "$state"}},
+		"finished_at":      bson.M{"$cond": bson.A{pending, now, "$$REMOVE"}},
+		"cancel_requested": bson.M{"$not": bson.A{pending}},
+		"updated_at":       now,
+	}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var job models.Job
+	err := collection.FindOneAndUpdate(ctx, query, update, opts).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to cancel job %s: %w", id.Hex(), err)
+	}
+
+	return &job, nil
+}
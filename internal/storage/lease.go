@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// leaseCollection holds the leases, keyed by name
+const leaseCollection = "leases"
+
+// AcquireLease takes or renews a lease for its holder. The lease is taken
+// when it is free, expired as of its RenewedAt or already held by the same
+// holder; it reports whether the holder now holds the lease.
+func (s *Storage) AcquireLease(ctx context.Context, lease models.Lease) (bool, error) {
+	collection := s.client.Database(s.database).Collection(leaseCollection)
+
+	query := bson.M{
+		"_id": lease.Name,
+		"$or": bson.A{
+			bson.M{"holder": lease.Holder},
+			bson.M{"expires_at": bson.M{"$lte": lease.RenewedAt}},
+		},
+	}
+	// A lease held by another replica does not match the query, and the
+	// upsert then fails on the existing _id
+	_, err := collection.ReplaceOne(ctx, query, lease, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", lease.Name, err)
+	}
+
+	return true, nil
+}
+
+// ReleaseLease gives up a lease if the holder still holds it
+func (s *Storage) ReleaseLease(ctx context.Context, name, holder string) error {
+	collection := s.client.Database(s.database).Collection(leaseCollection)
+
+	if _, err := collection.DeleteOne(ctx, bson.M{"_id": name, "holder": holder}); err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// GetLease returns a lease, or nil if nobody took it yet
+func (s *Storage) GetLease(ctx context.Context, name string) (*models.Lease, error) {
+	collection := s.client.Database(s.database).Collection(leaseCollection)
+
+	var lease models.Lease
+	err := collection.FindOne(ctx, bson.M{"_id": name}).Decode(&lease)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get lease %s: %w", name, err)
+	}
+
+	return &lease, nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// memberCollection holds the heartbeats of the replicas sharing ingestion
+const memberCollection = "members"
+
+// Heartbeat registers a member or extends its membership
+func (s *Storage) Heartbeat(ctx context.Context, member models.Member) error {
+	collection := s.client.Database(s.database).Collection(memberCollection)
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": member.ID}, member, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat of %s: %w", member.ID, err)
+	}
+
+	return nil
+}
+
+// GetLiveMembers returns the members whose membership has not expired at
+// the given time, sorted by ID
+func (s *Storage) GetLiveMembers(ctx context.Context, now time.Time) ([]models.Member, error) {
+	collection := s.client.Database(s.database).Collection(memberCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"expires_at": bson.M{"$gt": now}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members: %w", err)
+	}
+
+	var members []models.Member
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, fmt.Errorf("failed to decode members: %w", err)
+	}
+
+	return members, nil
+}
+
+// RemoveMember deletes a member, handing its sources to the others
+func (s *Storage) RemoveMember(ctx context.Context, id string) error {
+	collection := s.client.Database(s.database).Collection(memberCollection)
+
+	if _, err := collection.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return fmt.Errorf("failed to remove member %s: %w", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetRawPosts retrieves up to limit posts of the default collection and of
+// the collections of the filter that were stored with their raw payload, by
+// ascending ID. Posts read from another collection than the default have
+// their Collection set.
+func (s *Storage) GetRawPosts(ctx context.Context, filter models.RawPostFilter, limit int) ([]models.EnrichedPost, error) {
+	query := bson.M{
+		"source":      filter.Source,
+		"raw_payload": bson.M{"$exists": true},
+	}
+
+	eventTime := bson.M{}
+	if !filter.From.IsZero() {
+		eventTime["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		eventTime["$lt"] = filter.To
+	}
+	if len(eventTime) > 0 {
+		query["event_time"] = eventTime
+	}
+
+	id := bson.M{}
+	if !filter.After.IsZero() {
+		id["$gt"] = filter.After
+	}
+	if !filter.Before.IsZero() {
+		id["$lt"] = filter.Before
+	}
+	if len(id) > 0 {
+		query["_id"] = id
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	// The first posts of each collection are merged, so the page holds the
+	// first posts overall
+	var posts []models.EnrichedPost
+	for _, name := range s.postCollections(filter.Collections) {
+		collection := s.client.Database(s.database).Collection(name)
+		cursor, err := collection.Find(ctx, query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find raw posts in %s: %w", name, err)
+		}
+
+		var found []models.EnrichedPost
+		err = cursor.All(ctx, &found)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode raw posts of %s: %w", name, err)
+		}
+
+		for i := range found {
+			if name != s.collection {
+				found[i].Collection = name
+			}
+		}
+		posts = append(posts, found...)
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		return bytes.Compare(posts[i].ID[:], posts[j].ID[:]) < 0
+	})
+	if limit > 0 && len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	return posts, nil
+}
+
+// ReplacePosts writes posts over the stored posts with the same ID,
+// inserting the ones not stored yet. A post routed to another collection
+// than before is moved: once written, it is removed from the default
+// collection and the given collections, except the one it is routed to.
+func (s *Storage) ReplacePosts(ctx context.Context, posts []models.EnrichedPost, collections []string) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	// Group replacements by destination collection for bulk writes
+	var order []string
+	writes := make(map[string][]mongo.WriteModel)
+	destinations := make(map[primitive.ObjectID]string, len(posts))
+	for _, post := range posts {
+		name := post.Collection
+		if name == "" {
+			name = s.collection
+		}
+		if _, ok := writes[name]; !ok {
+			order = append(order, name)
+		}
+		writes[name] = append(writes[name], mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": post.ID}).
+			SetReplacement(post).
+			SetUpsert(true))
+		destinations[post.ID] = name
+	}
+
+	for _, name := range order {
+		collection := s.client.Database(s.database).Collection(name)
+		if _, err := collection.BulkWrite(ctx, writes[name], options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to replace posts in %s: %w", name, err)
+		}
+	}
+
+	// Removing moved posts last leaves both copies if interrupted, which
+	// the next replacement resolves
+	for _, name := range s.postCollections(collections) {
+		var moved []primitive.ObjectID
+		for id, destination := range destinations {
+			if destination != name {
+				moved = append(moved, id)
+			}
+		}
+		if len(moved) == 0 {
+			continue
+		}
+		collection := s.client.Database(s.database).Collection(name)
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": moved}}); err != nil {
+			return fmt.Errorf("failed to remove moved posts from %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// DeletePosts deletes stored posts from the collection they were read from,
+// the default collection unless their Collection is set
+func (s *Storage) DeletePosts(ctx context.Context, posts []models.EnrichedPost) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	// Group IDs by collection for bulk deletes
+	var order []string
+	ids := make(map[string][]primitive.ObjectID)
+	for _, post := range posts {
+		name := post.Collection
+		if name == "" {
+			name = s.collection
+		}
+		if _, ok := ids[name]; !ok {
+			order = append(order, name)
+		}
+		ids[name] = append(ids[name], post.ID)
+	}
+
+	for _, name := range order {
+		collection := s.client.Database(s.database).Collection(name)
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids[name]}}); err != nil {
+			return fmt.Errorf("failed to delete posts from %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// postCollections returns the default collection followed by the given
+// collections rules route posts to, without repeats
+func (s *Storage) postCollections(routed []string) []string {
+	names := []string{s.collection}
+	seen := map[string]bool{s.collection: true}
+	for _, name := range routed {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rejectedCollection is the dead-letter collection of records that could
+// not be ingested
+const rejectedCollection = "rejected_posts"
+
+// StoreRejected stores records that failed validation or processing
+func (s *Storage) StoreRejected(ctx context.Context, records []models.RejectedRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	collection := s.client.Database(s.database).Collection(rejectedCollection)
+
+	documents := make([]interface{}, len(records))
+	for i, record := range records {
+		documents[i] = record
+	}
+
+	if _, err := collection.InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("failed to insert rejected records: %w", err)
+	}
+
+	return nil
+}
+
+// GetRejected retrieves the most recent rejected records, optionally for a
+// single source
+func (s *Storage) GetRejected(ctx interface{}, source string, limit int) ([]models.RejectedRecord, error) {
+	collection := s.client.Database(s.database).Collection(rejectedCollection)
+
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	query := bson.M{}
+	if source != "" {
+		query["source"] = source
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "rejected_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := collection.Find(ctxValue, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find rejected records: %w", err)
+	}
+	defer cursor.Close(ctxValue)
+
+	var records []models.RejectedRecord
+	if err := cursor.All(ctxValue, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode rejected records: %w", err)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// schemaCollection holds the schema baseline and latest profile of each source
+	schemaCollection = "source_schemas"
+	// driftCollection holds the schema drift events
+	driftCollection = "schema_drift"
+)
+
+// GetSourceSchema returns the schema state of a source, or nil if the
+// source has not been profiled yet
+func (s *Storage) GetSourceSchema(ctx context.Context, source string) (*models.SourceSchema, error) {
+	collection := s.client.Database(s.database).Collection(schemaCollection)
+
+	var schema models.SourceSchema
+	err := collection.FindOne(ctx, bson.M{"_id": source}).Decode(&schema)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load schema of source %s: %w", source, err)
+	}
+
+	return &schema, nil
+}
+
+// SaveSourceSchema stores the schema state of a source
+func (s *Storage) SaveSourceSchema(ctx context.Context, schema models.SourceSchema) error {
+	collection := s.client.Database(s.database).Collection(schemaCollection)
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": schema.Source}, schema, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save schema of source %s: %w", schema.Source, err)
+	}
+
+	return nil
+}
+
+// StoreDriftEvent records a schema drift event
+func (s *Storage) StoreDriftEvent(ctx context.Context, event models.DriftEvent) error {
+	collection := s.client.Database(s.database).Collection(driftCollection)
+
+	if _, err := collection.InsertOne(ctx, event); err != nil {
+		return fmt.Errorf("failed to insert drift event: %w", err)
+	}
+
+	return nil
+}
+
+// GetSchema returns the schema state of a source with its most recent drift
+// events, or nil if the source has not been profiled yet
+func (s *Storage) GetSchema(ctx interface{}, source string, limit int) (*models.SourceSchema, error) {
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	schema, err := s.GetSourceSchema(ctxValue, source)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+
+	collection := s.client.Database(s.database).Collection(driftCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "detected_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := collection.Find(ctxValue, bson.M{"source": source}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find drift events: %w", err)
+	}
+	defer cursor.Close(ctxValue)
+
+	if err := cursor.All(ctxValue, &schema.Drift); err != nil {
+		return nil, fmt.Errorf("failed to decode drift events: %w", err)
+	}
+
+	return schema, nil
+}
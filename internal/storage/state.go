@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// stateCollection holds opaque pipeline state such as dedup filters
+const stateCollection = "pipeline_state"
+
+// stateDocument is a named state blob
+type stateDocument struct {
+	Name      string    `bson:"_id"`
+	Data      []byte    `bson:"data"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// LoadState returns the state saved under name, or nil if there is none
+func (s *Storage) LoadState(ctx context.Context, name string) ([]byte, error) {
+	collection := s.client.Database(s.database).Collection(stateCollection)
+
+	var doc stateDocument
+	err := collection.FindOne(ctx, bson.M{"_id": name}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load state %s: %w", name, err)
+	}
+
+	return doc.Data, nil
+}
+
+// SaveState stores state under name, replacing any previous state
+func (s *Storage) SaveState(ctx context.Context, name string, data []byte) error {
+	collection := s.client.Database(s.database).Collection(stateCollection)
+
+	doc := stateDocument{
+		Name:      name,
+		Data:      data,
+		UpdatedAt: time.Now().UTC(),
+	}
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": name}, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save state %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -34,11 +34,39 @@ func New(uri, database, collection string) (*Storage, error) {
 		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
-	return &Storage{
+	storage := &Storage{
 		client:     client,
 		database:   database,
 		collection: collection,
-	}, nil
+	}
+
+	if err := storage.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// ensureIndexes creates the indexes used by the log and history queries
+func (s *Storage) ensureIndexes(ctx context.Context) error {
+	collection := s.client.Database(s.database).Collection(s.collection)
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "event_time", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	_, err = s.versions().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "source", Value: 1}, {Key: "post_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create history indexes: %w", err)
+	}
+
+	return nil
 }
 
 // Close closes the database connection
@@ -46,30 +74,40 @@ func (s *Storage) Close(ctx context.Context) error {
 	return s.client.Disconnect(ctx)
 }
 
-// StorePosts stores the enriched posts in the database
+// StorePosts stores the enriched posts in the database. Posts routed to
+// another collection are written there instead of the default collection.
 func (s *Storage) StorePosts(ctx context.Context, posts []models.EnrichedPost) error {
 	if len(posts) == 0 {
 		return nil
 	}
 
-	collection := s.client.Database(s.database).Collection(s.collection)
-
-	// Convert posts to interface slice for bulk write
-	documents := make([]interface{}, len(posts))
-	for i, post := range posts {
-		documents[i] = post
+	// Group posts by destination collection for bulk writes
+	var order []string
+	documents := make(map[string][]interface{})
+	for _, post := range posts {
+		name := post.Collection
+		if name == "" {
+			name = s.collection
+		}
+		if _, ok := documents[name]; !ok {
+			order = append(order, name)
+		}
+		documents[name] = append(documents[name], post)
 	}
 
-	_, err := collection.InsertMany(ctx, documents)
-	if err != nil {
-		return fmt.Errorf("failed to insert posts: %w", err)
+	for _, name := range order {
+		collection := s.client.Database(s.database).Collection(name)
+		_, err := collection.InsertMany(ctx, documents[name])
+		if err != nil {
+			return fmt.Errorf("failed to insert posts into %s: %w", name, err)
+		}
 	}
 
 	return nil
 }
 
-// GetPosts retrieves all posts from the database
-func (s *Storage) GetPosts(ctx interface{}) ([]models.EnrichedPost, error) {
+// GetPosts retrieves the posts matching the filter from the database
+func (s *Storage) GetPosts(ctx interface{}, filter models.LogFilter) ([]models.EnrichedPost, error) {
 	collection := s.client.Database(s.database).Collection(s.collection)
 
 	// Convert to context.Context if needed
@@ -78,7 +116,39 @@ func (s *Storage) GetPosts(ctx interface{}) ([]models.EnrichedPost, error) {
 		ctxValue = context.Background()
 	}
 
-	cursor, err := collection.Find(ctxValue, bson.M{})
+	query := bson.M{}
+	eventTime := bson.M{}
+	if !filter.From.IsZero() {
+		eventTime["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		eventTime["$lt"] = filter.To
+	}
+	if len(eventTime) > 0 {
+		query["event_time"] = eventTime
+	}
+
+	severityNumber := bson.M{}
+	if filter.MinSeverity > 0 {
+		severityNumber["$gte"] = filter.MinSeverity
+	}
+	if filter.MaxSeverity > 0 {
+		severityNumber["$lte"] = filter.MaxSeverity
+	}
+	if len(severityNumber) > 0 {
+		query["severity_number"] = severityNumber
+	}
+
+	opts := options.Find()
+	if filter.SortField != "" {
+		order := 1
+		if filter.SortDesc {
+			order = -1
+		}
+		opts.SetSort(bson.D{{Key: filter.SortField, Value: order}})
+	}
+
+	cursor, err := collection.Find(ctxValue, query, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find posts: %w", err)
 	}
@@ -2,10 +2,12 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -85,7 +87,7 @@ func TestStorePosts(t *testing.T) {
 	}
 
 	// Retrieve posts
-	retrievedPosts, err := storage.GetPosts(ctx)
+	retrievedPosts, err := storage.GetPosts(ctx, models.LogFilter{})
 	if err != nil {
 		t.Fatalf("Failed to retrieve posts: %v", err)
 	}
@@ -160,3 +162,765 @@ func TestGetPostByIDNotFound(t *testing.T) {
 		t.Error("Expected error for non-existent post, got nil")
 	}
 }
+
+func TestGetPostsFilterByEventTime(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	// Create posts with different event times
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var posts []models.EnrichedPost
+	for i := 0; i < 3; i++ {
+		posts = append(posts, models.EnrichedPost{
+			PostID:     i + 1,
+			Title:      "Test Title",
+			EventTime:  base.Add(time.Duration(i) * time.Hour),
+			IngestedAt: time.Now().UTC(),
+			Source:     "test_source",
+		})
+	}
+
+	ctx := context.Background()
+	if err := storage.StorePosts(ctx, posts); err != nil {
+		t.Fatalf("Failed to store posts: %v", err)
+	}
+
+	// Retrieve posts from the second hour onwards, newest first
+	retrievedPosts, err := storage.GetPosts(ctx, models.LogFilter{
+		From:      base.Add(time.Hour),
+		SortField: "event_time",
+		SortDesc:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to retrieve posts: %v", err)
+	}
+
+	// Verify results
+	if len(retrievedPosts) != 2 {
+		t.Fatalf("Expected 2 posts, got %d", len(retrievedPosts))
+	}
+
+	if retrievedPosts[0].PostID != 3 || retrievedPosts[1].PostID != 2 {
+		t.Errorf("Expected posts 3 and 2 in that order, got %d and %d", retrievedPosts[0].PostID, retrievedPosts[1].PostID)
+	}
+}
+
+func TestFingerprintWindow(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	window, err := storage.FingerprintWindow(ctx, "test_fingerprints", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create fingerprint window: %v", err)
+	}
+
+	seen, err := window.Contains(ctx, "abc")
+	if err != nil {
+		t.Fatalf("Failed to check fingerprint: %v", err)
+	}
+	if seen {
+		t.Error("Expected first fingerprint not to be seen")
+	}
+
+	// A fingerprint is only seen once committed
+	seen, _ = window.Contains(ctx, "abc")
+	if seen {
+		t.Error("Expected uncommitted fingerprint not to be seen")
+	}
+	if err := window.Commit(ctx, []string{"abc", "abc"}); err != nil {
+		t.Fatalf("Failed to commit fingerprints: %v", err)
+	}
+
+	seen, err = window.Contains(ctx, "abc")
+	if err != nil {
+		t.Fatalf("Failed to check fingerprint: %v", err)
+	}
+	if !seen {
+		t.Error("Expected committed fingerprint to be seen")
+	}
+
+	// A window changed since the index was created updates its expiry
+	if _, err := storage.FingerprintWindow(ctx, "test_fingerprints", 2*time.Hour); err != nil {
+		t.Fatalf("Failed to change the fingerprint window: %v", err)
+	}
+	cursor, err := storage.client.Database(storage.database).Collection("test_fingerprints").Indexes().List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list indexes: %v", err)
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		t.Fatalf("Failed to decode indexes: %v", err)
+	}
+	expiry := map[string]interface{}{}
+	for _, index := range indexes {
+		expiry[fmt.Sprint(index["name"])] = index["expireAfterSeconds"]
+	}
+	if fmt.Sprint(expiry["last_seen_1"]) != "7200" {
+		t.Errorf("Expected the index to expire after 7200s, got %v", expiry)
+	}
+}
+
+func TestState(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	data, err := storage.LoadState(ctx, "missing")
+	if err != nil || data != nil {
+		t.Fatalf("Expected no state and no error, got %v and %v", data, err)
+	}
+
+	if err := storage.SaveState(ctx, "test", []byte("state")); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	data, err = storage.LoadState(ctx, "test")
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if string(data) != "state" {
+		t.Errorf("Expected state 'state', got '%s'", data)
+	}
+}
+
+func TestStorePostsRouted(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	// Route one of two posts to another collection
+	posts := []models.EnrichedPost{
+		{PostID: 1, Title: "default", IngestedAt: time.Now().UTC()},
+		{PostID: 2, Title: "routed", IngestedAt: time.Now().UTC(), Collection: "routed_posts"},
+	}
+
+	ctx := context.Background()
+	if err := storage.StorePosts(ctx, posts); err != nil {
+		t.Fatalf("Failed to store posts: %v", err)
+	}
+
+	// Verify results
+	defaultCount, err := storage.client.Database(storage.database).Collection(storage.collection).CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("Failed to count posts: %v", err)
+	}
+	routedCount, err := storage.client.Database(storage.database).Collection("routed_posts").CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("Failed to count posts: %v", err)
+	}
+
+	if defaultCount != 1 || routedCount != 1 {
+		t.Errorf("Expected 1 post in each collection, got %d and %d", defaultCount, routedCount)
+	}
+}
+
+func TestRejected(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	now := time.Now().UTC()
+	records := []models.RejectedRecord{
+		{Source: "a", Reason: models.RejectedSchema, Payload: map[string]interface{}{"id": int64(1)}, Errors: []string{"/: missing properties: 'title'"}, RejectedAt: now},
+		{Source: "b", Reason: models.RejectedSchema, Payload: map[string]interface{}{"id": int64(2)}, Errors: []string{"/id: expected integer"}, RejectedAt: now.Add(time.Second)},
+	}
+
+	ctx := context.Background()
+	if err := storage.StoreRejected(ctx, records); err != nil {
+		t.Fatalf("Failed to store rejected records: %v", err)
+	}
+
+	all, err := storage.GetRejected(ctx, "", 10)
+	if err != nil {
+		t.Fatalf("Failed to get rejected records: %v", err)
+	}
+	if len(all) != 2 || all[0].Source != "b" {
+		t.Errorf("Expected 2 records, newest first, got %+v", all)
+	}
+
+	filtered, err := storage.GetRejected(ctx, "a", 10)
+	if err != nil {
+		t.Fatalf("Failed to get rejected records: %v", err)
+	}
+	if len(filtered) != 1 || len(filtered[0].Errors) != 1 {
+		t.Errorf("Expected 1 record for source a with its errors, got %+v", filtered)
+	}
+}
+
+func TestSourceSchema(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	schema, err := storage.GetSchema(ctx, "posts", 10)
+	if err != nil || schema != nil {
+		t.Fatalf("Expected no schema before profiling, got %+v, %v", schema, err)
+	}
+
+	profile := models.SchemaProfile{
+		Records: 1,
+		Fields:  []models.FieldProfile{{Path: "title", Types: []string{"string"}}},
+	}
+	if err := storage.SaveSourceSchema(ctx, models.SourceSchema{Source: "posts", Baseline: profile, Latest: profile}); err != nil {
+		t.Fatalf("Failed to save schema: %v", err)
+	}
+	event := models.DriftEvent{
+		Source:     "posts",
+		DetectedAt: time.Now().UTC(),
+		Changes:    []models.SchemaChange{{Path: "body", Kind: models.FieldRemoved}},
+	}
+	if err := storage.StoreDriftEvent(ctx, event); err != nil {
+		t.Fatalf("Failed to store drift event: %v", err)
+	}
+
+	schema, err = storage.GetSchema(ctx, "posts", 10)
+	if err != nil {
+		t.Fatalf("Failed to get schema: %v", err)
+	}
+	if schema == nil || len(schema.Baseline.Fields) != 1 || len(schema.Drift) != 1 {
+		t.Errorf("Expected the saved profile and 1 drift event, got %+v", schema)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	first := time.Now().UTC().Truncate(time.Millisecond)
+	second := first.Add(time.Minute)
+
+	// Counts accumulate over saves
+	saves := [][]models.LogTemplate{
+		{
+			{ID: "a", Source: "posts", Template: "user 1 logged in", Count: 2, FirstSeen: first, LastSeen: first},
+			{ID: "b", Source: "posts", Template: "disk full", Count: 1, FirstSeen: first, LastSeen: first},
+		},
+		{
+			{ID: "a", Source: "posts", Template: "user <*> logged in", Count: 3, FirstSeen: second, LastSeen: second},
+		},
+	}
+	for _, templates := range saves {
+		if err := storage.SaveTemplates(ctx, templates); err != nil {
+			t.Fatalf("Failed to save templates: %v", err)
+		}
+	}
+
+	templates, err := storage.GetTemplates(ctx, "posts", 10)
+	if err != nil {
+		t.Fatalf("Failed to get templates: %v", err)
+	}
+	if len(templates) != 2 || templates[0].ID != "a" {
+		t.Fatalf("Expected 2 templates, most frequent first, got %+v", templates)
+	}
+	a := templates[0]
+	if a.Count != 5 || a.Template != "user <*> logged in" || !a.FirstSeen.Equal(first) || !a.LastSeen.Equal(second) {
+		t.Errorf("Expected merged template a, got %+v", a)
+	}
+
+	loaded, err := storage.LoadTemplates(ctx, "posts")
+	if err != nil || len(loaded) != 2 {
+		t.Errorf("Expected 2 templates to load, got %d, %v", len(loaded), err)
+	}
+}
+
+func TestRawPosts(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	base := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	posts := []models.EnrichedPost{
+		{PostID: 1, Source: "a", EventTime: base, RawPayload: []byte("one")},
+		{PostID: 2, Source: "a", EventTime: base.Add(time.Hour), RawPayload: []byte("two")},
+		{PostID: 3, Source: "a", EventTime: base.Add(2 * time.Hour)},
+		{PostID: 4, Source: "b", EventTime: base, RawPayload: []byte("four")},
+		{PostID: 5, Source: "a", EventTime: base, RawPayload: []byte("five"), Collection: "test_routed"},
+	}
+	if err := storage.StorePosts(ctx, posts); err != nil {
+		t.Fatalf("Failed to store posts: %v", err)
+	}
+
+	raw, err := storage.GetRawPosts(ctx, models.RawPostFilter{Source: "a"}, 10)
+	if err != nil {
+		t.Fatalf("Failed to get raw posts: %v", err)
+	}
+	if len(raw) != 2 || raw[0].PostID != 1 || string(raw[1].RawPayload) != "two" {
+		t.Errorf("Expected posts 1 and 2 with their payload, got %+v", raw)
+	}
+
+	// Routed collections are read along with the default one
+	routed, err := storage.GetRawPosts(ctx, models.RawPostFilter{Source: "a", Collections: []string{"test_routed"}}, 10)
+	if err != nil {
+		t.Fatalf("Failed to get raw posts: %v", err)
+	}
+	if len(routed) != 3 || routed[2].PostID != 5 || routed[2].Collection != "test_routed" {
+		t.Fatalf("Expected posts 1, 2 and the routed post 5, got %+v", routed)
+	}
+	if err := storage.DeletePosts(ctx, routed[2:]); err != nil {
+		t.Fatalf("Failed to delete routed post: %v", err)
+	}
+	if count, _ := storage.client.Database(storage.database).Collection("test_routed").CountDocuments(ctx, bson.M{}); count != 0 {
+		t.Errorf("Expected the routed post to be deleted, got %d left", count)
+	}
+
+	// Paging by ID within a time range
+	raw, err = storage.GetRawPosts(ctx, models.RawPostFilter{Source: "a", To: base.Add(2 * time.Hour), After: raw[0].ID}, 10)
+	if err != nil {
+		t.Fatalf("Failed to get raw posts: %v", err)
+	}
+	if len(raw) != 1 || raw[0].PostID != 2 {
+		t.Fatalf("Expected post 2, got %+v", raw)
+	}
+
+	if err := storage.DeletePosts(ctx, raw); err != nil {
+		t.Fatalf("Failed to delete posts: %v", err)
+	}
+	remaining, err := storage.GetPosts(ctx, models.LogFilter{})
+	if err != nil {
+		t.Fatalf("Failed to get posts: %v", err)
+	}
+	if len(remaining) != 3 {
+		t.Errorf("Expected 3 remaining posts, got %d", len(remaining))
+	}
+}
+
+func TestReplacePosts(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	posts := []models.EnrichedPost{
+		{PostID: 1, Source: "a", Title: "old"},
+		{PostID: 2, Source: "a", Title: "old", Collection: "test_routed"},
+	}
+	if err := storage.StorePosts(ctx, posts); err != nil {
+		t.Fatalf("Failed to store posts: %v", err)
+	}
+	stored, err := storage.GetPosts(ctx, models.LogFilter{})
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("Failed to get stored post: %v, %+v", err, stored)
+	}
+	var routed models.EnrichedPost
+	err = storage.client.Database(storage.database).Collection("test_routed").FindOne(ctx, bson.M{}).Decode(&routed)
+	if err != nil {
+		t.Fatalf("Failed to get routed post: %v", err)
+	}
+
+	// The first post is rewritten in place, the routed one moves back to the
+	// default collection, and a new one is inserted
+	replacements := []models.EnrichedPost{
+		{ID: stored[0].ID, PostID: 1, Source: "a", Title: "new"},
+		{ID: routed.ID, PostID: 2, Source: "a", Title: "new"},
+		{ID: primitive.NewObjectID(), PostID: 3, Source: "a", Title: "new"},
+	}
+	for i := 0; i < 2; i++ {
+		// Replacing twice gives the same result
+		if err := storage.ReplacePosts(ctx, replacements, []string{"test_routed"}); err != nil {
+			t.Fatalf("Failed to replace posts: %v", err)
+		}
+	}
+
+	posts, err = storage.GetPosts(ctx, models.LogFilter{})
+	if err != nil {
+		t.Fatalf("Failed to get posts: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Fatalf("Expected 3 posts, got %+v", posts)
+	}
+	for _, post := range posts {
+		if post.Title != "new" {
+			t.Errorf("Expected the replaced posts, got %+v", post)
+		}
+	}
+	if post, err := storage.GetPostByID(ctx, routed.ID.Hex()); err != nil || post.PostID != 2 {
+		t.Errorf("Expected the moved post under its ID, got %+v, %v", post, err)
+	}
+	if count, _ := storage.client.Database(storage.database).Collection("test_routed").CountDocuments(ctx, bson.M{}); count != 0 {
+		t.Errorf("Expected the moved post to be removed from the routed collection, got %d left", count)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	first := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	err := storage.SaveVersions(ctx, []models.PostVersion{
+		{Source: "a", PostID: 1, Version: 1, Hash: "h1", Payload: map[string]interface{}{"user": map[string]interface{}{"name": "x"}}, ValidFrom: first},
+		{Source: "a", PostID: 2, Version: 1, Hash: "h2", ValidFrom: first},
+	})
+	if err != nil {
+		t.Fatalf("Failed to save versions: %v", err)
+	}
+	err = storage.SaveVersions(ctx, []models.PostVersion{
+		{Source: "a", PostID: 1, Version: 2, Hash: "h3", ValidFrom: second},
+	})
+	if err != nil {
+		t.Fatalf("Failed to save versions: %v", err)
+	}
+
+	current, err := storage.GetCurrentVersions(ctx, "a", []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("Failed to get current versions: %v", err)
+	}
+	if len(current) != 2 {
+		t.Errorf("Expected 2 current versions, got %d", len(current))
+	}
+
+	versions, err := storage.GetHistory(ctx, "a", 1)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[0].ValidTo != nil {
+		t.Fatalf("Expected the open version 2 first, got %+v", versions)
+	}
+	if versions[1].ValidTo == nil || !versions[1].ValidTo.Equal(second) {
+		t.Errorf("Expected version 1 to be closed at %v, got %v", second, versions[1].ValidTo)
+	}
+	if _, ok := versions[1].Payload["user"].(map[string]interface{}); !ok {
+		t.Errorf("Expected nested payload objects to decode as maps, got %T", versions[1].Payload["user"])
+	}
+}
+
+func TestTombstones(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	posts := []models.EnrichedPost{
+		{PostID: 1, Source: "a"},
+		{PostID: 2, Source: "a"},
+		{PostID: 2, Source: "b"},
+		{PostID: 3, Source: "a", Collection: "test_routed"},
+	}
+	if err := storage.StorePosts(ctx, posts); err != nil {
+		t.Fatalf("Failed to store posts: %v", err)
+	}
+	routed := []string{"test_routed"}
+
+	deletedAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := storage.MarkDeleted(ctx, "a", routed, []int{2, 3}, deletedAt); err != nil {
+		t.Fatalf("Failed to mark posts deleted: %v", err)
+	}
+	// Marking again keeps the first tombstone
+	if err := storage.MarkDeleted(ctx, "a", routed, []int{2, 3}, deletedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to mark posts deleted: %v", err)
+	}
+
+	stored, err := storage.GetPosts(ctx, models.LogFilter{})
+	if err != nil {
+		t.Fatalf("Failed to get posts: %v", err)
+	}
+	marked := 0
+	for _, post := range stored {
+		if post.DeletedAt != nil {
+			marked++
+			if post.Source != "a" || post.PostID != 2 || !post.DeletedAt.Equal(deletedAt) {
+				t.Errorf("Expected only post 2 of source a to be tombstoned at %v, got %+v", deletedAt, post)
+			}
+		}
+	}
+	if marked != 1 {
+		t.Errorf("Expected 1 tombstoned post, got %d", marked)
+	}
+
+	// Posts routed to another collection are tombstoned too
+	var routedPost models.EnrichedPost
+	err = storage.client.Database(storage.database).Collection("test_routed").FindOne(ctx, bson.M{"postId": 3}).Decode(&routedPost)
+	if err != nil || routedPost.DeletedAt == nil || !routedPost.DeletedAt.Equal(deletedAt) {
+		t.Errorf("Expected the routed post to be tombstoned at %v, got %+v and %v", deletedAt, routedPost, err)
+	}
+
+	purged, err := storage.PurgeDeleted(ctx, "a", routed, deletedAt.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to purge posts: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("Expected 2 purged posts, got %d", purged)
+	}
+}
+
+func TestLease(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	lease := func(holder string, at time.Time) models.Lease {
+		return models.Lease{Name: "ingestion", Holder: holder, AcquiredAt: at, RenewedAt: at, ExpiresAt: at.Add(30 * time.Second)}
+	}
+
+	if ok, err := storage.AcquireLease(ctx, lease("a", now)); err != nil || !ok {
+		t.Fatalf("Expected a to acquire the free lease, got %v and %v", ok, err)
+	}
+	if ok, err := storage.AcquireLease(ctx, lease("b", now.Add(10*time.Second))); err != nil || ok {
+		t.Fatalf("Expected b not to acquire the held lease, got %v and %v", ok, err)
+	}
+	if ok, err := storage.AcquireLease(ctx, lease("a", now.Add(10*time.Second))); err != nil || !ok {
+		t.Fatalf("Expected a to renew its lease, got %v and %v", ok, err)
+	}
+
+	// The renewed lease expires 40s after the first acquisition
+	if ok, _ := storage.AcquireLease(ctx, lease("b", now.Add(35*time.Second))); ok {
+		t.Errorf("Expected b not to acquire the renewed lease")
+	}
+	if ok, err := storage.AcquireLease(ctx, lease("b", now.Add(40*time.Second))); err != nil || !ok {
+		t.Fatalf("Expected b to acquire the expired lease, got %v and %v", ok, err)
+	}
+
+	current, err := storage.GetLease(ctx, "ingestion")
+	if err != nil || current == nil || current.Holder != "b" {
+		t.Fatalf("Expected b to hold the lease, got %+v and %v", current, err)
+	}
+
+	// Only the holder releases the lease
+	if err := storage.ReleaseLease(ctx, "ingestion", "a"); err != nil {
+		t.Fatalf("Failed to release lease: %v", err)
+	}
+	if current, _ := storage.GetLease(ctx, "ingestion"); current == nil {
+		t.Errorf("Expected the lease of b to be kept")
+	}
+	if err := storage.ReleaseLease(ctx, "ingestion", "b"); err != nil {
+		t.Fatalf("Failed to release lease: %v", err)
+	}
+	if current, _ := storage.GetLease(ctx, "ingestion"); current != nil {
+		t.Errorf("Expected the lease to be released, got %+v", current)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for i, id := range []string{"b", "a", "c"} {
+		member := models.Member{
+			ID:          id,
+			HeartbeatAt: now,
+			ExpiresAt:   now.Add(time.Duration(i*10) * time.Second),
+			Sources:     []string{"source_" + id},
+		}
+		if err := storage.Heartbeat(ctx, member); err != nil {
+			t.Fatalf("Failed to record heartbeat: %v", err)
+		}
+	}
+
+	// b expired as it was registered
+	members, err := storage.GetLiveMembers(ctx, now)
+	if err != nil {
+		t.Fatalf("Failed to get members: %v", err)
+	}
+	if len(members) != 2 || members[0].ID != "a" || members[1].ID != "c" || members[0].Sources[0] != "source_a" {
+		t.Errorf("Expected members a and c, got %+v", members)
+	}
+
+	if err := storage.RemoveMember(ctx, "a"); err != nil {
+		t.Fatalf("Failed to remove member: %v", err)
+	}
+	members, err = storage.GetLiveMembers(ctx, now)
+	if err != nil || len(members) != 1 || members[0].ID != "c" {
+		t.Errorf("Expected member c, got %+v and %v", members, err)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	retryAt := now.Add(time.Minute)
+	alert := models.Alert{ID: "stale/a", Rule: "stale", Source: "a", Message: "stale", FiredAt: now,
+		Pending: []string{"webhook", "email"}, Attempts: 1, RetryAt: &retryAt}
+
+	if fired, err := storage.FireAlert(ctx, alert); err != nil || !fired {
+		t.Fatalf("Expected the alert to fire, got %v and %v", fired, err)
+	}
+	if fired, err := storage.FireAlert(ctx, alert); err != nil || fired {
+		t.Fatalf("Expected the firing alert not to fire again, got %v and %v", fired, err)
+	}
+
+	// Retries are due at retry_at and claimed by one replica
+	if alerts, err := storage.GetUndeliveredAlerts(ctx, now); err != nil || len(alerts) != 0 {
+		t.Fatalf("Expected no retry due yet, got %+v and %v", alerts, err)
+	}
+	alerts, err := storage.GetUndeliveredAlerts(ctx, retryAt)
+	if err != nil || len(alerts) != 1 || alerts[0].Attempts != 1 || len(alerts[0].Pending) != 2 {
+		t.Fatalf("Expected the alert to be due, got %+v and %v", alerts, err)
+	}
+	if claimed, err := storage.ClaimDelivery(ctx, alerts[0], retryAt.Add(2*time.Minute)); err != nil || !claimed {
+		t.Fatalf("Expected the delivery to be claimed, got %v and %v", claimed, err)
+	}
+	if claimed, err := storage.ClaimDelivery(ctx, alerts[0], retryAt.Add(2*time.Minute)); err != nil || claimed {
+		t.Fatalf("Expected the delivery not to be claimed twice, got %v and %v", claimed, err)
+	}
+	attempt := alerts[0]
+	attempt.Attempts = 2
+	if err := storage.RecordDelivery(ctx, attempt, []string{"email"}, "email: timeout"); err != nil {
+		t.Fatalf("Failed to record delivery: %v", err)
+	}
+	if alerts, err := storage.GetUndeliveredAlerts(ctx, retryAt.Add(2*time.Minute)); err != nil || len(alerts) != 1 || len(alerts[0].Pending) != 1 || alerts[0].DeliveryError != "email: timeout" {
+		t.Fatalf("Expected the email to be pending, got %+v and %v", alerts, err)
+	}
+	if err := storage.RecordDelivery(ctx, attempt, nil, ""); err != nil {
+		t.Fatalf("Failed to record delivery: %v", err)
+	}
+	if alerts, err := storage.GetUndeliveredAlerts(ctx, retryAt.Add(time.Hour)); err != nil || len(alerts) != 0 {
+		t.Fatalf("Expected the alert to be delivered, got %+v and %v", alerts, err)
+	}
+
+	resolved, err := storage.ResolveAlert(ctx, "stale/a", now.Add(time.Minute), []string{"webhook"}, now.Add(2*time.Minute))
+	if err != nil || resolved == nil || resolved.State != models.AlertResolved || resolved.Message != "stale" || resolved.Attempts != 1 || len(resolved.Pending) != 1 {
+		t.Fatalf("Expected the alert to resolve, got %+v and %v", resolved, err)
+	}
+	if resolved, err := storage.ResolveAlert(ctx, "stale/a", now.Add(time.Minute), nil, now); err != nil || resolved != nil {
+		t.Fatalf("Expected the resolved alert not to resolve again, got %+v and %v", resolved, err)
+	}
+
+	// A resolved alert fires again
+	if fired, err := storage.FireAlert(ctx, alert); err != nil || !fired {
+		t.Fatalf("Expected the resolved alert to fire again, got %v and %v", fired, err)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	storage, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job, err := storage.CreateJob(ctx, models.Job{
+		Type:   models.JobBackfill,
+		Source: "a",
+		From:   now.Add(-3 * time.Hour),
+		To:     now,
+		Chunk:  "1h",
+		State:  models.JobPending,
+		Cursor: now.Add(-3 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+
+	claimed, err := storage.ClaimJob(ctx, "r1", now, now.Add(time.Minute))
+	if err != nil || claimed == nil || claimed.ID != job.ID || claimed.Owner != "r1" || claimed.StartedAt == nil {
+		t.Fatalf("Expected r1 to claim the job, got %+v and %v", claimed, err)
+	}
+	if other, err := storage.ClaimJob(ctx, "r2", now, now.Add(time.Minute)); err != nil || other != nil {
+		t.Fatalf("Expected no job for r2 while r1 holds it, got %+v and %v", other, err)
+	}
+
+	// A cancellation reaches the owner at its next checkpoint
+	if cancelled, err := storage.CancelJob(ctx, job.ID, now); err != nil || cancelled == nil || !cancelled.CancelRequested || cancelled.State != models.JobRunning {
+		t.Fatalf("Expected the running job flagged, got %+v and %v", cancelled, err)
+	}
+	claimed.Cursor = claimed.Cursor.Add(time.Hour)
+	claimed.ChunksDone = 1
+	stored, err := storage.CheckpointJob(ctx, *claimed)
+	if err != nil || stored == nil || !stored.CancelRequested || !stored.Cursor.Equal(claimed.Cursor) {
+		t.Fatalf("Expected the checkpoint to carry the cancellation, got %+v and %v", stored, err)
+	}
+
+	// An expired lease hands the job to another replica, and the previous
+	// owner can no longer checkpoint it
+	resumed, err := storage.ClaimJob(ctx, "r2", now.Add(2*time.Minute), now.Add(3*time.Minute))
+	if err != nil || resumed == nil || resumed.ChunksDone != 1 || !resumed.StartedAt.Equal(*claimed.StartedAt) {
+		t.Fatalf("Expected r2 to resume the job, got %+v and %v", resumed, err)
+	}
+	if stored, err := storage.CheckpointJob(ctx, *claimed); err != nil || stored != nil {
+		t.Fatalf("Expected r1 to have lost the job, got %+v and %v", stored, err)
+	}
+
+	resumed.State = models.JobCancelled
+	resumed.FinishedAt = &now
+	if err := storage.FinishJob(ctx, *resumed); err != nil {
+		t.Fatalf("Failed to finish job: %v", err)
+	}
+	if cancelled, err := storage.CancelJob(ctx, job.ID, now); err != nil || cancelled != nil {
+		t.Fatalf("Expected a finished job not to be cancelled, got %+v and %v", cancelled, err)
+	}
+
+	jobs, err := storage.GetJobs(ctx, models.JobFilter{Source: "a", State: models.JobCancelled})
+	if err != nil || len(jobs) != 1 || jobs[0].Owner != "" {
+		t.Errorf("Expected the cancelled job without owner, got %+v and %v", jobs, err)
+	}
+}
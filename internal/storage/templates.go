@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// templateCollection holds the mined message templates
+const templateCollection = "log_templates"
+
+// LoadTemplates returns the templates mined for a source
+func (s *Storage) LoadTemplates(ctx context.Context, source string) ([]models.LogTemplate, error) {
+	collection := s.client.Database(s.database).Collection(templateCollection)
+
+	cursor, err := collection.Find(ctx, bson.M{"source": source})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find templates: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var templates []models.LogTemplate
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, fmt.Errorf("failed to decode templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+// SaveTemplates adds the counts of the given templates to the stored ones,
+// updating their template text and first and last seen times
+func (s *Storage) SaveTemplates(ctx context.Context, templates []models.LogTemplate) error {
+	if len(templates) == 0 {
+		return nil
+	}
+
+	collection := s.client.Database(s.database).Collection(templateCollection)
+
+	writes := make([]mongo.WriteModel, len(templates))
+	for i, template := range templates {
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": template.ID}).
+			SetUpdate(bson.M{
+				"$set": bson.M{"source": template.Source, "template": template.Template},
+				"$inc": bson.M{"count": template.Count},
+				"$min": bson.M{"first_seen": template.FirstSeen},
+				"$max": bson.M{"last_seen": template.LastSeen},
+			}).
+			SetUpsert(true)
+	}
+
+	if _, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to save templates: %w", err)
+	}
+
+	return nil
+}
+
+// GetTemplates retrieves the most frequent templates, optionally for a
+// single source
+func (s *Storage) GetTemplates(ctx interface{}, source string, limit int) ([]models.LogTemplate, error) {
+	collection := s.client.Database(s.database).Collection(templateCollection)
+
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	query := bson.M{}
+	if source != "" {
+		query["source"] = source
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := collection.Find(ctxValue, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find templates: %w", err)
+	}
+	defer cursor.Close(ctxValue)
+
+	var templates []models.LogTemplate
+	if err := cursor.All(ctxValue, &templates); err != nil {
+		return nil, fmt.Errorf("failed to decode templates: %w", err)
+	}
+
+	return templates, nil
+}
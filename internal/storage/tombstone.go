@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MarkDeleted sets the tombstone on the posts of the given upstream records
+// that are not marked yet, in the default collection and the given ones
+// rules route posts to
+func (s *Storage) MarkDeleted(ctx context.Context, source string, collections []string, postIDs []int, at time.Time) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	query := bson.M{
+		"source":     source,
+		"postId":     bson.M{"$in": postIDs},
+		"deleted_at": bson.M{"$exists": false},
+	}
+	for _, name := range s.postCollections(collections) {
+		collection := s.client.Database(s.database).Collection(name)
+		if _, err := collection.UpdateMany(ctx, query, bson.M{"$set": bson.M{"deleted_at": at}}); err != nil {
+			return fmt.Errorf("failed to mark posts deleted in %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// PurgeDeleted removes the posts of a source tombstoned before the given
+// time, from the default collection and the given ones rules route posts
+// to, and returns how many were removed
+func (s *Storage) PurgeDeleted(ctx context.Context, source string, collections []string, before time.Time) (int, error) {
+	purged := 0
+	for _, name := range s.postCollections(collections) {
+		collection := s.client.Database(s.database).Collection(name)
+		result, err := collection.DeleteMany(ctx, bson.M{"source": source, "deleted_at": bson.M{"$lt": before}})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge deleted posts from %s: %w", name, err)
+		}
+		purged += int(result.DeletedCount)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,136 @@
+// Package tombstone detects the records that disappeared from snapshot
+// sources, which return every live record on each fetch, and marks the
+// stored copies as deleted
+package tombstone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Store keeps the upstream IDs of the previous run and the stored posts
+type Store interface {
+	LoadState(ctx context.Context, name string) ([]byte, error)
+	SaveState(ctx context.Context, name string, data []byte) error
+	// MarkDeleted sets the tombstone on the posts of the given upstream
+	// records that are not marked yet, in the default collection and the
+	// given routed ones
+	MarkDeleted(ctx context.Context, source string, collections []string, postIDs []int, at time.Time) error
+	// PurgeDeleted removes the posts of a source tombstoned before the
+	// given time, in the default collection and the given routed ones, and
+	// returns how many were removed
+	PurgeDeleted(ctx context.Context, source string, collections []string, before time.Time) (int, error)
+}
+
+// Result counts the records tombstoned and purged by a run
+type Result struct {
+	Deleted int
+	Purged  int
+}
+
+// Marker compares the upstream IDs of each run of a snapshot source with
+// the previous run. IDs that disappeared are tombstoned, and tombstoned
+// posts are purged once the grace period is over.
+type Marker struct {
+	store Store
+	// gracePeriod is how long tombstoned posts are kept, forever when zero
+	gracePeriod time.Duration
+	// collections are the collections rules route posts to, besides the
+	// default one
+	collections []string
+	now         func() time.Time
+}
+
+// NewMarker creates a new Marker instance tombstoning the posts of the
+// default collection and of the given collections rules route posts to
+func NewMarker(store Store, gracePeriod time.Duration, collections []string) *Marker {
+	return &Marker{
+		store:       store,
+		gracePeriod: gracePeriod,
+		collections: collections,
+		now:         time.Now,
+	}
+}
+
+// Observe tombstones the records of the previous run that are missing from
+// the posts of this run. Empty runs are ignored, as an empty snapshot is
+// more likely an upstream failure than every record being deleted. Posts
+// without an upstream ID are not tracked.
+func (m *Marker) Observe(ctx context.Context, source string, posts []models.Post) (Result, error) {
+	var result Result
+	now := m.now().UTC()
+
+	current := make(map[int]bool, len(posts))
+	for _, post := range posts {
+		if _, ok := post.Fields["id"]; ok {
+			current[post.ID] = true
+		}
+	}
+
+	if len(current) > 0 {
+		deleted, err := m.tombstone(ctx, source, current, now)
+		if err != nil {
+			return result, err
+		}
+		result.Deleted = deleted
+	}
+
+	if m.gracePeriod > 0 {
+		purged, err := m.store.PurgeDeleted(ctx, source, m.collections, now.Add(-m.gracePeriod))
+		if err != nil {
+			return result, err
+		}
+		result.Purged = purged
+	}
+
+	return result, nil
+}
+
+// tombstone marks the IDs of the previous run missing from the current one
+// and saves the current IDs for the next run
+func (m *Marker) tombstone(ctx context.Context, source string, current map[int]bool, now time.Time) (int, error) {
+	name := "snapshot_" + source
+
+	data, err := m.store.LoadState(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	var previous []int
+	if data != nil {
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return 0, fmt.Errorf("failed to decode snapshot of source %s: %w", source, err)
+		}
+	}
+
+	var missing []int
+	for _, id := range previous {
+		if !current[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		if err := m.store.MarkDeleted(ctx, source, m.collections, missing, now); err != nil {
+			return 0, err
+		}
+	}
+
+	ids := make([]int, 0, len(current))
+	for id := range current {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	data, err = json.Marshal(ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode snapshot of source %s: %w", source, err)
+	}
+	if err := m.store.SaveState(ctx, name, data); err != nil {
+		return 0, err
+	}
+
+	return len(missing), nil
+}
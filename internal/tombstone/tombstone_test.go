@@ -0,0 +1,121 @@
+package tombstone
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryStore keeps the state and tombstones in memory
+type memoryStore struct {
+	state      map[string][]byte
+	tombstones map[int]time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{state: make(map[string][]byte), tombstones: make(map[int]time.Time)}
+}
+
+func (s *memoryStore) LoadState(ctx context.Context, name string) ([]byte, error) {
+	return s.state[name], nil
+}
+
+func (s *memoryStore) SaveState(ctx context.Context, name string, data []byte) error {
+	s.state[name] = data
+	return nil
+}
+
+func (s *memoryStore) MarkDeleted(ctx context.Context, source string, collections []string, postIDs []int, at time.Time) error {
+	for _, id := range postIDs {
+		if _, ok := s.tombstones[id]; !ok {
+			s.tombstones[id] = at
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) PurgeDeleted(ctx context.Context, source string, collections []string, before time.Time) (int, error) {
+	purged := 0
+	for id, at := range s.tombstones {
+		if at.Before(before) {
+			delete(s.tombstones, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func decodePosts(t *testing.T, payloads ...string) []models.Post {
+	posts := make([]models.Post, len(payloads))
+	for i, payload := range payloads {
+		if err := json.Unmarshal([]byte(payload), &posts[i]); err != nil {
+			t.Fatalf("Failed to decode post: %v", err)
+		}
+	}
+	return posts
+}
+
+func TestObserve(t *testing.T) {
+	store := newMemoryStore()
+	marker := NewMarker(store, 0, nil)
+	ctx := context.Background()
+
+	// The first run has nothing to compare with
+	result, err := marker.Observe(ctx, "test", decodePosts(t, `{"id":1}`, `{"id":2}`, `{"id":3}`))
+	if err != nil {
+		t.Fatalf("Observe returned an error: %v", err)
+	}
+	if result.Deleted != 0 {
+		t.Errorf("Expected no deletions on the first run, got %d", result.Deleted)
+	}
+
+	result, err = marker.Observe(ctx, "test", decodePosts(t, `{"id":1}`, `{"id":4}`, `{"title":"no id"}`))
+	if err != nil {
+		t.Fatalf("Observe returned an error: %v", err)
+	}
+	if result.Deleted != 2 || len(store.tombstones) != 2 {
+		t.Errorf("Expected records 2 and 3 to be tombstoned, got %+v and %v", result, store.tombstones)
+	}
+
+	var ids []int
+	if err := json.Unmarshal(store.state["snapshot_test"], &ids); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 4}) {
+		t.Errorf("Expected snapshot [1 4], got %v", ids)
+	}
+
+	// An empty run keeps the snapshot
+	result, err = marker.Observe(ctx, "test", nil)
+	if err != nil {
+		t.Fatalf("Observe returned an error: %v", err)
+	}
+	if result.Deleted != 0 || len(store.tombstones) != 2 {
+		t.Errorf("Expected an empty run to tombstone nothing, got %+v", result)
+	}
+}
+
+func TestObservePurge(t *testing.T) {
+	store := newMemoryStore()
+	marker := NewMarker(store, 24*time.Hour, nil)
+	now := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	marker.now = func() time.Time { return now }
+
+	store.tombstones[1] = now.Add(-48 * time.Hour)
+	store.tombstones[2] = now.Add(-time.Hour)
+
+	result, err := marker.Observe(context.Background(), "test", decodePosts(t, `{"id":3}`))
+	if err != nil {
+		t.Fatalf("Observe returned an error: %v", err)
+	}
+	if result.Purged != 1 {
+		t.Errorf("Expected 1 purged post, got %d", result.Purged)
+	}
+	if _, ok := store.tombstones[2]; !ok {
+		t.Errorf("Expected the tombstone within the grace period to be kept")
+	}
+}
@@ -0,0 +1,91 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// minBaselineRuns is the number of successful runs a source needs before
+// its counts are scored
+const minBaselineRuns = 5
+
+// madScale turns the median absolute deviation into an estimate of the
+// standard deviation, so scores read like z-scores
+const madScale = 1.4826
+
+// WithAnomalyDetection keeps the counts of the last window successful runs
+// of each source as its baseline and flags the runs whose score exceeds
+// threshold. Detection is disabled when window is zero.
+func WithAnomalyDetection(window int, threshold float64) Option {
+	return func(t *Tracker) {
+		t.anomalyWindow = window
+		t.anomalyThreshold = threshold
+	}
+}
+
+// scoreCount scores the records fetched by a successful run against the
+// baseline of its source
+func (t *Tracker) scoreCount(ctx context.Context, run models.IngestStatus) (*models.CountAnomaly, error) {
+	if !run.Success || run.Source == "" || t.anomalyWindow <= 0 {
+		return nil, nil
+	}
+	collection := t.client.Database(t.database).Collection(sourceStateCollection)
+
+	var state models.SourceStatus
+	opts := options.FindOne().SetProjection(bson.M{"recent_counts": 1})
+	err := collection.FindOne(ctx, bson.M{"_id": run.Source}, opts).Decode(&state)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to get baseline of source %s: %w", run.Source, err)
+	}
+
+	return detectAnomaly(state.RecentCounts, run.Fetched, t.anomalyThreshold), nil
+}
+
+// detectAnomaly scores a count by its distance to the median of the
+// baseline, in scaled median absolute deviations. The MAD of a source
+// returning the same count every run is zero, so the scale is floored at 5%
+// of the median, and at one record.
+func detectAnomaly(baseline []int, count int, threshold float64) *models.CountAnomaly {
+	if len(baseline) < minBaselineRuns {
+		return nil
+	}
+
+	values := make([]float64, len(baseline))
+	for i, value := range baseline {
+		values[i] = float64(value)
+	}
+	m := median(values)
+
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - m)
+	}
+	mad := median(deviations)
+
+	scale := math.Max(madScale*mad, math.Max(0.05*m, 1))
+	score := (float64(count) - m) / scale
+	return &models.CountAnomaly{
+		Count:     count,
+		Median:    m,
+		MAD:       mad,
+		Score:     math.Round(score*100) / 100,
+		Anomalous: math.Abs(score) > threshold,
+	}
+}
+
+// median returns the median of values, sorting them
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,162 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// runQuery builds the query matching the runs selected by the filter
+func runQuery(filter models.RunFilter) bson.M {
+	query := bson.M{}
+	if filter.Source != "" {
+		query["source"] = filter.Source
+	}
+	if filter.Success != nil {
+		query["success"] = *filter.Success
+	}
+	if filter.Anomalous != nil {
+		if *filter.Anomalous {
+			query["anomaly.anomalous"] = true
+		} else {
+			query["anomaly.anomalous"] = bson.M{"$ne": true}
+		}
+	}
+
+	startedAt := bson.M{}
+	if !filter.From.IsZero() {
+		startedAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		startedAt["$lt"] = filter.To
+	}
+	if len(startedAt) > 0 {
+		query["started_at"] = startedAt
+	}
+
+	return query
+}
+
+// GetRuns retrieves the runs matching the filter, most recent first
+func (t *Tracker) GetRuns(ctx interface{}, filter models.RunFilter) ([]models.IngestStatus, error) {
+	collection := t.client.Database(t.database).Collection(t.collection)
+
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}, {Key: "_id", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		opts.SetSkip(int64(filter.Offset))
+	}
+
+	cursor, err := collection.Find(ctxValue, runQuery(filter), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find runs: %w", err)
+	}
+	defer cursor.Close(ctxValue)
+
+	runs := []models.IngestStatus{}
+	if err := cursor.All(ctxValue, &runs); err != nil {
+		return nil, fmt.Errorf("failed to decode runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// GetRun retrieves a run by its ID, returning ErrRunNotFound if there is
+// no such run
+func (t *Tracker) GetRun(ctx interface{}, id string) (models.IngestStatus, error) {
+	collection := t.client.Database(t.database).Collection(t.collection)
+
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.IngestStatus{}, fmt.Errorf("%w: invalid ID format: %v", ErrRunNotFound, err)
+	}
+
+	var run models.IngestStatus
+	err = collection.FindOne(ctxValue, bson.M{"_id": objectID}).Decode(&run)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.IngestStatus{}, fmt.Errorf("%w: %s", ErrRunNotFound, id)
+		}
+		return models.IngestStatus{}, fmt.Errorf("failed to find run: %w", err)
+	}
+
+	return run, nil
+}
+
+// GetRunStats computes the success rate, mean and 95th percentile duration
+// and mean records stored of the finished runs matching the filter
+func (t *Tracker) GetRunStats(ctx interface{}, filter models.RunFilter) (models.RunStats, error) {
+	collection := t.client.Database(t.database).Collection(t.collection)
+
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	query := runQuery(filter)
+	query["state"] = bson.M{"$in": bson.A{models.RunSucceeded, models.RunFailed}}
+
+	// The 95th percentile is taken by nearest rank from the sorted durations
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: query}},
+		{{Key: "$sort", Value: bson.D{{Key: "duration_ms", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "runs", Value: bson.M{"$sum": 1}},
+			{Key: "succeeded", Value: bson.M{"$sum": bson.M{"$cond": bson.A{"$success", 1, 0}}}},
+			{Key: "mean_duration_ms", Value: bson.M{"$avg": "$duration_ms"}},
+			{Key: "mean_records", Value: bson.M{"$avg": "$count"}},
+			{Key: "durations", Value: bson.M{"$push": "$duration_ms"}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "runs", Value: 1},
+			{Key: "succeeded", Value: 1},
+			{Key: "mean_duration_ms", Value: 1},
+			{Key: "mean_records", Value: 1},
+			{Key: "success_rate", Value: bson.M{"$divide": bson.A{"$succeeded", "$runs"}}},
+			{Key: "p95_duration_ms", Value: bson.M{"$arrayElemAt": bson.A{
+				"$durations",
+				bson.M{"$subtract": bson.A{bson.M{"$ceil": bson.M{"$multiply": bson.A{0.95, "$runs"}}}, 1}},
+			}}},
+		}}},
+	}
+
+	stats := models.RunStats{Source: filter.Source, From: filter.From, To: filter.To}
+
+	cursor, err := collection.Aggregate(ctxValue, pipeline)
+	if err != nil {
+		return stats, fmt.Errorf("failed to aggregate runs: %w", err)
+	}
+	defer cursor.Close(ctxValue)
+
+	if cursor.Next(ctxValue) {
+		if err := cursor.Decode(&stats); err != nil {
+			return stats, fmt.Errorf("failed to decode run stats: %w", err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return stats, fmt.Errorf("failed to aggregate runs: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,70 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// skippedRunCollection holds the scheduled runs that did not start. They
+// are kept apart from the runs, so they do not weigh on run statistics.
+const skippedRunCollection = "skipped_runs"
+
+// RecordSkippedRun records a skipped run and counts it in the state of its
+// source
+func (t *Tracker) RecordSkippedRun(ctx context.Context, skipped models.SkippedRun) error {
+	collection := t.client.Database(t.database).Collection(skippedRunCollection)
+
+	if _, err := collection.InsertOne(ctx, skipped); err != nil {
+		return fmt.Errorf("failed to record skipped run: %w", err)
+	}
+
+	states := t.client.Database(t.database).Collection(sourceStateCollection)
+	update := bson.M{
+		"$set": bson.M{"last_skipped_at": skipped.SkippedAt},
+		"$inc": bson.M{"skipped_runs": 1},
+	}
+	_, err := states.UpdateOne(ctx, bson.M{"_id": skipped.Source}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to update state of source %s: %w", skipped.Source, err)
+	}
+
+	return nil
+}
+
+// GetSkippedRuns retrieves the most recent skipped runs, of a source when
+// one is given
+func (t *Tracker) GetSkippedRuns(ctx interface{}, source string, limit int) ([]models.SkippedRun, error) {
+	collection := t.client.Database(t.database).Collection(skippedRunCollection)
+
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	query := bson.M{}
+	if source != "" {
+		query["source"] = source
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "skipped_at", Value: -1}, {Key: "_id", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := collection.Find(ctxValue, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find skipped runs: %w", err)
+	}
+	defer cursor.Close(ctxValue)
+
+	skipped := []models.SkippedRun{}
+	if err := cursor.All(ctxValue, &skipped); err != nil {
+		return nil, fmt.Errorf("failed to decode skipped runs: %w", err)
+	}
+
+	return skipped, nil
+}
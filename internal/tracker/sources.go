@@ -0,0 +1,154 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sourceStateCollection holds the state of each source, keyed by name
+const sourceStateCollection = "source_state"
+
+// updateSourceState folds a finished run into the state of its source
+func (t *Tracker) updateSourceState(ctx context.Context, run models.IngestStatus) error {
+	if run.Source == "" {
+		return nil
+	}
+	collection := t.client.Database(t.database).Collection(sourceStateCollection)
+
+	set := bson.M{
+		"last_run_id": run.ID,
+		"last_run_at": run.FinishedAt,
+	}
+	update := bson.M{"$set": set}
+	if run.Success {
+		set["last_success_at"] = run.FinishedAt
+		set["consecutive_failures"] = 0
+		set["last_anomaly"] = run.Anomaly
+		// An anomalous count stays out of the baseline, or a sustained drop
+		// would become the norm and stop being flagged
+		if t.anomalyWindow > 0 && (run.Anomaly == nil || !run.Anomaly.Anomalous) {
+			update["$push"] = bson.M{"recent_counts": bson.M{"$each": bson.A{run.Fetched}, "$slice": -t.anomalyWindow}}
+		}
+	} else {
+		set["last_failure_at"] = run.FinishedAt
+		set["last_error"] = run.Error
+		update["$inc"] = bson.M{"consecutive_failures": 1}
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": run.Source}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to update state of source %s: %w", run.Source, err)
+	}
+
+	return nil
+}
+
+// ScheduleRun records the schedule of a source and when its next run is due
+func (t *Tracker) ScheduleRun(ctx context.Context, source, schedule string, next time.Time) error {
+	collection := t.client.Database(t.database).Collection(sourceStateCollection)
+
+	update := bson.M{"$set": bson.M{"schedule": schedule, "next_run_at": next}}
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": source}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to schedule next run of source %s: %w", source, err)
+	}
+
+	return nil
+}
+
+// GetSourceState retrieves the state of a source, or nil if it has none
+func (t *Tracker) GetSourceState(ctx context.Context, source string) (*models.SourceStatus, error) {
+	collection := t.client.Database(t.database).Collection(sourceStateCollection)
+
+	var state models.SourceStatus
+	err := collection.FindOne(ctx, bson.M{"_id": source}).Decode(&state)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get state of source %s: %w", source, err)
+	}
+
+	return &state, nil
+}
+
+// GetStatusRollup retrieves the state of every source, degraded when any
+// source breaches its SLO
+func (t *Tracker) GetStatusRollup(ctx interface{}) (models.StatusRollup, error) {
+	collection := t.client.Database(t.database).Collection(sourceStateCollection)
+
+	// Convert to context.Context if needed
+	ctxValue, ok := ctx.(context.Context)
+	if !ok {
+		ctxValue = context.Background()
+	}
+
+	cursor, err := collection.Find(ctxValue, bson.M{})
+	if err != nil {
+		return models.StatusRollup{}, fmt.Errorf("failed to get source states: %w", err)
+	}
+	var states []models.SourceStatus
+	if err := cursor.All(ctxValue, &states); err != nil {
+		return models.StatusRollup{}, fmt.Errorf("failed to decode source states: %w", err)
+	}
+
+	return rollup(states, t.slos, t.started, t.now().UTC()), nil
+}
+
+// rollup computes the freshness of each source against its SLO. Registered
+// sources without a state yet are included, as sources that never ran.
+func rollup(states []models.SourceStatus, slos map[string]time.Duration, started, now time.Time) models.StatusRollup {
+	result := models.StatusRollup{
+		Status:    models.StatusOK,
+		CheckedAt: now,
+		Sources:   make([]models.SourceStatus, 0, len(states)),
+	}
+
+	seen := make(map[string]bool, len(states))
+	for _, state := range states {
+		seen[state.Source] = true
+	}
+	for source := range slos {
+		if !seen[source] {
+			states = append(states, models.SourceStatus{Source: source})
+		}
+	}
+
+	for _, state := range states {
+		state = freshness(state, slos[state.Source], started, now)
+		if state.Breached {
+			result.Status = models.StatusDegraded
+		}
+		result.Sources = append(result.Sources, state)
+	}
+
+	sort.Slice(result.Sources, func(i, j int) bool { return result.Sources[i].Source < result.Sources[j].Source })
+	return result
+}
+
+// freshness sets the freshness lag of a source and whether it breaches its
+// SLO. Sources without an SLO never breach.
+func freshness(state models.SourceStatus, maxStaleness time.Duration, started, now time.Time) models.SourceStatus {
+	since := started
+	if state.LastSuccessAt != nil {
+		since = *state.LastSuccessAt
+	}
+	lag := now.Sub(since)
+	if lag < 0 {
+		lag = 0
+	}
+
+	state.FreshnessLagSeconds = lag.Seconds()
+	if maxStaleness > 0 {
+		state.MaxStalenessSeconds = maxStaleness.Seconds()
+		state.Breached = lag > maxStaleness
+	}
+	return state
+}
@@ -2,24 +2,54 @@ package tracker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/tiwariayush700/log-ingestion-service/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrRunNotFound is returned when looking up an unknown run
+var ErrRunNotFound = errors.New("run not found")
+
 // Tracker monitors ingestion progress
 type Tracker struct {
 	client     *mongo.Client
 	database   string
 	collection string
+
+	// slos holds the maximum staleness of each configured source
+	slos map[string]time.Duration
+	// started is when tracking started, the reference for the freshness of
+	// sources that never succeeded
+	started time.Time
+	now     func() time.Time
+
+	// anomalyWindow is the number of counts kept as the baseline of each
+	// source, anomalyThreshold the score beyond which a count is anomalous
+	anomalyWindow    int
+	anomalyThreshold float64
+}
+
+// Option configures a Tracker
+type Option func(*Tracker)
+
+// WithSLO registers a source with the maximum time allowed since its last
+// successful run, no limit when zero. Registered sources are part of the
+// status rollup before their first run.
+func WithSLO(source string, maxStaleness time.Duration) Option {
+	return func(t *Tracker) {
+		t.slos[source] = maxStaleness
+	}
 }
 
 // New creates a new Tracker instance
-func New(uri, database string) (*Tracker, error) {
+func New(uri, database string, opts ...Option) (*Tracker, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -33,11 +63,40 @@ func New(uri, database string) (*Tracker, error) {
 		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
-	return &Tracker{
+	tracker := &Tracker{
 		client:     client,
 		database:   database,
 		collection: "ingest_status",
-	}, nil
+		slos:       make(map[string]time.Duration),
+		started:    time.Now().UTC(),
+		now:        time.Now,
+
+		anomalyWindow:    20,
+		anomalyThreshold: 3.5,
+	}
+	for _, opt := range opts {
+		opt(tracker)
+	}
+
+	if err := tracker.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	return tracker, nil
+}
+
+// ensureIndexes creates the indexes used by the run queries
+func (t *Tracker) ensureIndexes(ctx context.Context) error {
+	collection := t.client.Database(t.database).Collection(t.collection)
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "source", Value: 1}, {Key: "started_at", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	return nil
 }
 
 // Close closes the database connection
@@ -47,41 +106,95 @@ func (t *Tracker) Close(ctx context.Context) error {
 
 // RecordSuccess records a successful ingestion
 func (t *Tracker) RecordSuccess(ctx context.Context, count int) error {
+	return t.RecordStatus(ctx, models.IngestStatus{
+		Success: true,
+		Count:   count,
+	})
+}
+
+// RecordFailure records a failed ingestion
+func (t *Tracker) RecordFailure(ctx context.Context, err error) error {
+	return t.RecordStatus(ctx, models.IngestStatus{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
+
+// RecordStatus records an ingestion status, stamping it with the current
+// time if no timestamp is set
+func (t *Tracker) RecordStatus(ctx context.Context, status models.IngestStatus) error {
 	collection := t.client.Database(t.database).Collection(t.collection)
 
-	status := models.IngestStatus{
-		Timestamp: time.Now().UTC(),
-		Success:   true,
-		Count:     count,
+	if status.Timestamp.IsZero() {
+		status.Timestamp = time.Now().UTC()
 	}
 
 	_, err := collection.InsertOne(ctx, status)
 	if err != nil {
-		return fmt.Errorf("failed to record success: %w", err)
+		if status.Success {
+			return fmt.Errorf("failed to record success: %w", err)
+		}
+		return fmt.Errorf("failed to record failure: %w", err)
 	}
 
 	return nil
 }
 
-// RecordFailure records a failed ingestion
-func (t *Tracker) RecordFailure(ctx context.Context, err error) error {
+// StartRun records the start of a run of a source. The returned run is
+// filled in by the caller and finalized with FinishRun, even when recording
+// the start failed.
+func (t *Tracker) StartRun(ctx context.Context, source string) (models.IngestStatus, error) {
 	collection := t.client.Database(t.database).Collection(t.collection)
 
-	status := models.IngestStatus{
-		Timestamp: time.Now().UTC(),
-		Success:   false,
-		Error:     err.Error(),
+	now := time.Now().UTC()
+	run := models.IngestStatus{
+		ID:        primitive.NewObjectID(),
+		Timestamp: now,
+		Source:    source,
+		State:     models.RunRunning,
+		StartedAt: now,
 	}
 
-	_, err = collection.InsertOne(ctx, status)
+	if _, err := collection.InsertOne(ctx, run); err != nil {
+		return run, fmt.Errorf("failed to record run start: %w", err)
+	}
+
+	return run, nil
+}
+
+// FinishRun finalizes a run started with StartRun, setting its state from
+// its success, its end time and duration, and scoring its count against
+// the baseline of its source
+func (t *Tracker) FinishRun(ctx context.Context, run models.IngestStatus) error {
+	collection := t.client.Database(t.database).Collection(t.collection)
+
+	now := time.Now().UTC()
+	run.Timestamp = now
+	run.FinishedAt = &now
+	run.DurationMs = float64(now.Sub(run.StartedAt).Microseconds()) / 1000
+	run.State = models.RunFailed
+	if run.Success {
+		run.State = models.RunSucceeded
+	}
+
+	// The baseline is read before the run joins it. The run and the state of
+	// its source are recorded even when the baseline cannot be read, the run
+	// is then left unscored.
+	anomaly, err := t.scoreCount(ctx, run)
 	if err != nil {
-		return fmt.Errorf("failed to record failure: %w", err)
+		log.Printf("Error scoring run %s of %s: %v", run.ID.Hex(), run.Source, err)
 	}
+	run.Anomaly = anomaly
 
-	return nil
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": run.ID}, run, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to record run end: %w", err)
+	}
+
+	return t.updateSourceState(ctx, run)
 }
 
-// GetLatestStatus retrieves the latest ingestion status
+// GetLatestStatus retrieves the status of the latest finished run
 func (t *Tracker) GetLatestStatus(ctx interface{}) (models.IngestStatus, error) {
 	collection := t.client.Database(t.database).Collection(t.collection)
 
@@ -93,7 +206,8 @@ func (t *Tracker) GetLatestStatus(ctx interface{}) (models.IngestStatus, error)
 
 	opts := options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}})
 	var status models.IngestStatus
-	err := collection.FindOne(ctxValue, bson.M{}, opts).Decode(&status)
+	query := bson.M{"state": bson.M{"$ne": models.RunRunning}}
+	err := collection.FindOne(ctxValue, query, opts).Decode(&status)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return models.IngestStatus{}, fmt.Errorf("no ingestion status found")
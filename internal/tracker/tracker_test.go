@@ -3,9 +3,11 @@ package tracker
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -28,6 +30,12 @@ func setupTestTracker(t *testing.T) (*Tracker, func()) {
 		client:     client,
 		database:   dbName,
 		collection: "ingest_status",
+		slos:       make(map[string]time.Duration),
+		started:    time.Now().UTC(),
+		now:        time.Now,
+
+		anomalyWindow:    20,
+		anomalyThreshold: 3.5,
 	}
 
 	// Return a cleanup function
@@ -121,6 +129,43 @@ func TestRecordFailure(t *testing.T) {
 	}
 }
 
+func TestRecordStatus(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	tracker, cleanup := setupTestTracker(t)
+	defer cleanup()
+
+	// Record a successful ingestion with dedup counts
+	ctx := context.Background()
+	err := tracker.RecordStatus(ctx, models.IngestStatus{
+		Success:    true,
+		Count:      8,
+		Dropped:    2,
+		Duplicates: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to record status: %v", err)
+	}
+
+	// Retrieve the status
+	status, err := tracker.GetLatestStatus(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get latest status: %v", err)
+	}
+
+	// Verify results
+	if status.Count != 8 || status.Dropped != 2 || status.Duplicates != 2 {
+		t.Errorf("Expected counts 8/2/2, got %d/%d/%d", status.Count, status.Dropped, status.Duplicates)
+	}
+
+	if status.Timestamp.IsZero() {
+		t.Error("Expected timestamp to be set")
+	}
+}
+
 func TestGetLatestStatusNoRecords(t *testing.T) {
 	// Skip if no MongoDB connection
 	if testing.Short() {
@@ -139,3 +184,359 @@ func TestGetLatestStatusNoRecords(t *testing.T) {
 		t.Error("Expected error for no records, got nil")
 	}
 }
+
+func TestRun(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	tracker, cleanup := setupTestTracker(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	if err := tracker.RecordSuccess(ctx, 3); err != nil {
+		t.Fatalf("Failed to record success: %v", err)
+	}
+
+	run, err := tracker.StartRun(ctx, "test_source")
+	if err != nil {
+		t.Fatalf("Failed to start run: %v", err)
+	}
+	if run.ID.IsZero() || run.State != models.RunRunning {
+		t.Fatalf("Expected a running run with an ID, got %+v", run)
+	}
+
+	// A running run is not the latest status
+	status, err := tracker.GetLatestStatus(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get latest status: %v", err)
+	}
+	if status.Count != 3 {
+		t.Errorf("Expected the finished run with count 3, got %+v", status)
+	}
+
+	run.Success = true
+	run.Fetched = 10
+	run.Count = 8
+	run.Attempts = 2
+	run.Timings.FetchMs = 12.5
+	if err := tracker.FinishRun(ctx, run); err != nil {
+		t.Fatalf("Failed to finish run: %v", err)
+	}
+
+	status, err = tracker.GetLatestStatus(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get latest status: %v", err)
+	}
+	if status.ID != run.ID || status.State != models.RunSucceeded || status.FinishedAt == nil {
+		t.Errorf("Expected the finalized run %s, got %+v", run.ID.Hex(), status)
+	}
+	if status.Fetched != 10 || status.Count != 8 || status.Attempts != 2 || status.Timings.FetchMs != 12.5 {
+		t.Errorf("Expected the run counts and timings to be kept, got %+v", status)
+	}
+}
+
+func TestRunQueries(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	tracker, cleanup := setupTestTracker(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	base := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	collection := tracker.client.Database(tracker.database).Collection(tracker.collection)
+	for i := 0; i < 20; i++ {
+		run := models.IngestStatus{
+			ID:         primitive.NewObjectID(),
+			Source:     "a",
+			State:      models.RunSucceeded,
+			Success:    i%4 != 0,
+			StartedAt:  base.Add(time.Duration(i) * time.Minute),
+			DurationMs: float64(i + 1),
+			Count:      10,
+		}
+		if !run.Success {
+			run.State = models.RunFailed
+		}
+		if _, err := collection.InsertOne(ctx, run); err != nil {
+			t.Fatalf("Failed to insert run: %v", err)
+		}
+	}
+	if _, err := tracker.StartRun(ctx, "a"); err != nil {
+		t.Fatalf("Failed to start run: %v", err)
+	}
+
+	failed := false
+	runs, err := tracker.GetRuns(ctx, models.RunFilter{Source: "a", Success: &failed, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("Failed to get runs: %v", err)
+	}
+	if len(runs) != 2 || !runs[0].StartedAt.Equal(base.Add(12*time.Minute)) {
+		t.Errorf("Expected the second and third most recent failed runs, got %+v", runs)
+	}
+
+	run, err := tracker.GetRun(ctx, runs[0].ID.Hex())
+	if err != nil || run.ID != runs[0].ID {
+		t.Errorf("Expected run %s, got %+v (%v)", runs[0].ID.Hex(), run, err)
+	}
+	if _, err := tracker.GetRun(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("Expected ErrRunNotFound, got %v", err)
+	}
+
+	// The running run is left out of the statistics
+	stats, err := tracker.GetRunStats(ctx, models.RunFilter{Source: "a", From: base})
+	if err != nil {
+		t.Fatalf("Failed to get run stats: %v", err)
+	}
+	if stats.Runs != 20 || stats.Succeeded != 15 || stats.SuccessRate != 0.75 {
+		t.Errorf("Expected 15 of 20 runs to succeed, got %+v", stats)
+	}
+	if stats.MeanDurationMs != 10.5 || stats.P95DurationMs != 19 || stats.MeanRecords != 10 {
+		t.Errorf("Expected mean 10.5ms, p95 19ms and 10 records per run, got %+v", stats)
+	}
+}
+
+func TestSourceState(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	tracker, cleanup := setupTestTracker(t)
+	defer cleanup()
+	tracker.slos["a"] = time.Hour
+	tracker.slos["never_ran"] = time.Minute
+	tracker.started = time.Now().UTC().Add(-2 * time.Minute)
+
+	ctx := context.Background()
+	for _, success := range []bool{true, false, false} {
+		run, err := tracker.StartRun(ctx, "a")
+		if err != nil {
+			t.Fatalf("Failed to start run: %v", err)
+		}
+		run.Success = success
+		if !success {
+			run.Error = "upstream unavailable"
+		}
+		if err := tracker.FinishRun(ctx, run); err != nil {
+			t.Fatalf("Failed to finish run: %v", err)
+		}
+	}
+
+	rollup, err := tracker.GetStatusRollup(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get status rollup: %v", err)
+	}
+	if rollup.Status != models.StatusDegraded || len(rollup.Sources) != 2 {
+		t.Fatalf("Expected a degraded rollup of 2 sources, got %+v", rollup)
+	}
+
+	a := rollup.Sources[0]
+	if a.ConsecutiveFailures != 2 || a.LastError != "upstream unavailable" || a.LastSuccessAt == nil || a.LastFailureAt == nil {
+		t.Errorf("Expected 2 failures after a success, got %+v", a)
+	}
+	if a.Breached {
+		t.Errorf("Expected source a to be within its SLO, got %+v", a)
+	}
+	if !rollup.Sources[1].Breached {
+		t.Errorf("Expected the source that never ran to breach its SLO, got %+v", rollup.Sources[1])
+	}
+}
+
+func TestScheduleRun(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	tracker, cleanup := setupTestTracker(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	if state, err := tracker.GetSourceState(ctx, "a"); err != nil || state != nil {
+		t.Fatalf("Expected no state before the first schedule, got %+v and %v", state, err)
+	}
+
+	next := time.Now().UTC().Add(time.Minute).Truncate(time.Millisecond)
+	if err := tracker.ScheduleRun(ctx, "a", "@every 5m", next); err != nil {
+		t.Fatalf("Failed to schedule run: %v", err)
+	}
+	run, err := tracker.StartRun(ctx, "a")
+	if err != nil {
+		t.Fatalf("Failed to start run: %v", err)
+	}
+	run.Success = true
+	if err := tracker.FinishRun(ctx, run); err != nil {
+		t.Fatalf("Failed to finish run: %v", err)
+	}
+
+	// Finishing a run keeps the schedule
+	state, err := tracker.GetSourceState(ctx, "a")
+	if err != nil || state == nil {
+		t.Fatalf("Failed to get source state: %+v and %v", state, err)
+	}
+	if state.Schedule != "@every 5m" || state.NextRunAt == nil || !state.NextRunAt.Equal(next) || state.LastRunAt == nil {
+		t.Errorf("Expected the schedule and the last run, got %+v", state)
+	}
+}
+
+func TestSkippedRuns(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	tracker, cleanup := setupTestTracker(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for i, source := range []string{"a", "a", "b"} {
+		skipped := models.SkippedRun{
+			Source:    source,
+			DueAt:     now.Add(time.Duration(i) * time.Minute),
+			SkippedAt: now.Add(time.Duration(i) * time.Minute),
+			Policy:    "skip",
+			Reason:    "previous run still running",
+		}
+		if err := tracker.RecordSkippedRun(ctx, skipped); err != nil {
+			t.Fatalf("Failed to record skipped run: %v", err)
+		}
+	}
+
+	skipped, err := tracker.GetSkippedRuns(ctx, "a", 10)
+	if err != nil {
+		t.Fatalf("Failed to get skipped runs: %v", err)
+	}
+	if len(skipped) != 2 || !skipped[0].DueAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected the 2 skipped runs of a, most recent first, got %+v", skipped)
+	}
+
+	state, err := tracker.GetSourceState(ctx, "a")
+	if err != nil || state == nil || state.SkippedRuns != 2 || !state.LastSkippedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected 2 skipped runs in the state of a, got %+v and %v", state, err)
+	}
+}
+
+func TestRollup(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	started := now.Add(-time.Hour)
+	recent := now.Add(-5 * time.Minute)
+	stale := now.Add(-30 * time.Minute)
+
+	slos := map[string]time.Duration{"fresh": 10 * time.Minute, "new": 2 * time.Hour}
+	states := []models.SourceStatus{
+		{Source: "fresh", LastSuccessAt: &recent},
+		{Source: "retired", LastSuccessAt: &stale},
+	}
+
+	result := rollup(states, slos, started, now)
+	if result.Status != models.StatusOK || len(result.Sources) != 3 {
+		t.Fatalf("Expected an ok rollup of 3 sources, got %+v", result)
+	}
+	if fresh := result.Sources[0]; fresh.FreshnessLagSeconds != 300 || fresh.MaxStalenessSeconds != 600 {
+		t.Errorf("Expected a lag of 300s against 600s, got %+v", fresh)
+	}
+	if never := result.Sources[1]; never.Source != "new" || never.FreshnessLagSeconds != 3600 || never.Breached {
+		t.Errorf("Expected the new source to lag since tracking started, got %+v", never)
+	}
+	if retired := result.Sources[2]; retired.Breached || retired.MaxStalenessSeconds != 0 {
+		t.Errorf("Expected a source without SLO not to breach, got %+v", retired)
+	}
+
+	slos["fresh"] = time.Minute
+	if result := rollup(states, slos, started, now); result.Status != models.StatusDegraded || !result.Sources[0].Breached {
+		t.Errorf("Expected a degraded rollup, got %+v", result)
+	}
+}
+
+func TestCountAnomaly(t *testing.T) {
+	// Skip if no MongoDB connection
+	if testing.Short() {
+		t.Skip("Skipping MongoDB test in short mode")
+	}
+
+	tracker, cleanup := setupTestTracker(t)
+	defer cleanup()
+	tracker.anomalyWindow = 6
+
+	ctx := context.Background()
+	var last models.IngestStatus
+	for _, fetched := range []int{100, 98, 102, 101, 99, 100, 3} {
+		run, err := tracker.StartRun(ctx, "a")
+		if err != nil {
+			t.Fatalf("Failed to start run: %v", err)
+		}
+		run.Success = true
+		run.Fetched = fetched
+		if err := tracker.FinishRun(ctx, run); err != nil {
+			t.Fatalf("Failed to finish run: %v", err)
+		}
+		last = run
+	}
+
+	anomalous := true
+	runs, err := tracker.GetRuns(ctx, models.RunFilter{Source: "a", Anomalous: &anomalous})
+	if err != nil {
+		t.Fatalf("Failed to get runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].ID != last.ID || runs[0].Anomaly.Median != 100 {
+		t.Fatalf("Expected the last run to be anomalous against a median of 100, got %+v", runs)
+	}
+
+	rollup, err := tracker.GetStatusRollup(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get status rollup: %v", err)
+	}
+	state := rollup.Sources[0]
+	if state.LastAnomaly == nil || !state.LastAnomaly.Anomalous {
+		t.Errorf("Expected the anomaly in the source state, got %+v", state)
+	}
+	// The anomalous count is left out of the baseline
+	if want := []int{100, 98, 102, 101, 99, 100}; !reflect.DeepEqual(state.RecentCounts, want) {
+		t.Errorf("Expected the baseline %v to be unchanged, got %v", want, state.RecentCounts)
+	}
+
+	// A repeated drop is still flagged
+	run, err := tracker.StartRun(ctx, "a")
+	if err != nil {
+		t.Fatalf("Failed to start run: %v", err)
+	}
+	run.Success = true
+	run.Fetched = 3
+	if err := tracker.FinishRun(ctx, run); err != nil {
+		t.Fatalf("Failed to finish run: %v", err)
+	}
+	if runs, err := tracker.GetRuns(ctx, models.RunFilter{Source: "a", Anomalous: &anomalous}); err != nil || len(runs) != 2 {
+		t.Errorf("Expected the repeated drop to be anomalous, got %+v and %v", runs, err)
+	}
+}
+
+func TestDetectAnomaly(t *testing.T) {
+	baseline := []int{100, 90, 110, 95, 105, 100, 102}
+
+	if anomaly := detectAnomaly(baseline[:4], 3, 3.5); anomaly != nil {
+		t.Errorf("Expected no score before %d runs, got %+v", minBaselineRuns, anomaly)
+	}
+
+	anomaly := detectAnomaly(baseline, 3, 3.5)
+	if anomaly == nil || !anomaly.Anomalous || anomaly.Median != 100 || anomaly.MAD != 5 || anomaly.Score != -13.09 {
+		t.Errorf("Expected 3 records to be anomalous, got %+v", anomaly)
+	}
+	if anomaly := detectAnomaly(baseline, 112, 3.5); anomaly.Anomalous {
+		t.Errorf("Expected 112 records to be within the baseline, got %+v", anomaly)
+	}
+
+	// A source returning the same count every run tolerates small changes
+	constant := []int{100, 100, 100, 100, 100}
+	if anomaly := detectAnomaly(constant, 96, 3.5); anomaly.Anomalous || anomaly.Score != -0.8 {
+		t.Errorf("Expected 96 records to be within the constant baseline, got %+v", anomaly)
+	}
+	if anomaly := detectAnomaly(constant, 50, 3.5); !anomaly.Anomalous {
+		t.Errorf("Expected 50 records to be anomalous, got %+v", anomaly)
+	}
+}
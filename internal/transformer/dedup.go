@@ -0,0 +1,88 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/dedup"
+)
+
+// DedupMode selects what happens to a duplicate record
+type DedupMode string
+
+// Supported dedup modes
+const (
+	DedupDrop DedupMode = "drop"
+	DedupMark DedupMode = "mark"
+)
+
+// DedupStage detects records whose fingerprint was already seen within the
+// dedup window. It must run after the FingerprintStage.
+type DedupStage struct {
+	window dedup.Window
+	mode   DedupMode
+}
+
+// NewDedupStage creates a new DedupStage instance
+func NewDedupStage(window dedup.Window, mode DedupMode) (*DedupStage, error) {
+	if mode != DedupDrop && mode != DedupMark {
+		return nil, fmt.Errorf("unknown dedup mode: %s", mode)
+	}
+
+	return &DedupStage{
+		window: window,
+		mode:   mode,
+	}, nil
+}
+
+// Name returns the name of the stage
+func (s *DedupStage) Name() string {
+	return "dedup"
+}
+
+// Process marks or drops the record if its fingerprint was already seen,
+// in a committed batch or earlier in its own. The fingerprint is kept with
+// the record, so it is only committed if the record is stored.
+func (s *DedupStage) Process(ctx context.Context, record *Record) error {
+	fingerprint := record.Enriched.Fingerprint
+	if fingerprint == "" {
+		return nil
+	}
+
+	record.Keep(s.Name(), fingerprint)
+	seen := record.batch != nil && record.batch.Claim(s.Name(), fingerprint)
+	if !seen {
+		var err error
+		if seen, err = s.window.Contains(ctx, fingerprint); err != nil {
+			return fmt.Errorf("failed to check fingerprint: %w", err)
+		}
+	}
+	if !seen {
+		return nil
+	}
+
+	record.Enriched.Duplicate = true
+	if s.mode == DedupDrop {
+		record.Drop()
+	}
+	return nil
+}
+
+// Commit records the fingerprints of the stored records of a batch in the
+// window
+func (s *DedupStage) Commit(ctx context.Context, batch *Batch) error {
+	kept := batch.Kept(s.Name())
+	fingerprints := make([]string, len(kept))
+	for i, value := range kept {
+		fingerprints[i] = value.(string)
+	}
+	return s.window.Commit(ctx, fingerprints)
+}
+
+// Reload refreshes the window if it keeps a copy of its state in memory
+func (s *DedupStage) Reload(ctx context.Context) error {
+	if reloader, ok := s.window.(Reloader); ok {
+		return reloader.Reload(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package transformer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryWindow is an in-memory dedup window
+type memoryWindow map[string]bool
+
+func (m memoryWindow) Contains(ctx context.Context, key string) (bool, error) {
+	return m[key], nil
+}
+
+func (m memoryWindow) Commit(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		m[key] = true
+	}
+	return nil
+}
+
+func TestFingerprintStage(t *testing.T) {
+	stage, err := NewFingerprintStage([]string{"title", "body"}, []string{NormalizeLowercase, NormalizeCollapseWhitespace})
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	fingerprint := func(title, body string) string {
+		record := &Record{Enriched: models.EnrichedPost{Title: title, Body: body}}
+		if err := stage.Process(context.Background(), record); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return record.Enriched.Fingerprint
+	}
+
+	if fingerprint("Hello  World", "Body") != fingerprint("hello world", " body ") {
+		t.Error("Expected normalized content to have the same fingerprint")
+	}
+
+	if fingerprint("ab", "c") == fingerprint("a", "bc") {
+		t.Error("Expected field boundaries to change the fingerprint")
+	}
+
+	if fingerprint("hello", "world") == fingerprint("hello", "there") {
+		t.Error("Expected different content to have different fingerprints")
+	}
+
+	if _, err := NewFingerprintStage(nil, []string{"uppercase"}); err == nil {
+		t.Error("Expected error for unknown normalization rule, got nil")
+	}
+}
+
+func TestTransformDedup(t *testing.T) {
+	fingerprint, _ := NewFingerprintStage(nil, nil)
+
+	posts := []models.Post{
+		{ID: 1, Title: "same", Body: "content"},
+		{ID: 2, Title: "same", Body: "content"},
+		{ID: 3, Title: "other", Body: "content"},
+	}
+
+	tests := []struct {
+		mode          DedupMode
+		wantPosts     int
+		wantDropped   int
+		wantDuplicate bool
+	}{
+		{DedupDrop, 2, 1, false},
+		{DedupMark, 3, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			dedupStage, err := NewDedupStage(memoryWindow{}, tt.mode)
+			if err != nil {
+				t.Fatalf("Failed to create stage: %v", err)
+			}
+			transformer := New("test_source", WithStages(fingerprint, dedupStage))
+
+			result, err := transformer.Transform(context.Background(), posts)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if len(result.Posts) != tt.wantPosts {
+				t.Errorf("Expected %d posts, got %d", tt.wantPosts, len(result.Posts))
+			}
+
+			if result.Dropped != tt.wantDropped {
+				t.Errorf("Expected %d dropped, got %d", tt.wantDropped, result.Dropped)
+			}
+
+			if result.Duplicates != 1 {
+				t.Errorf("Expected 1 duplicate, got %d", result.Duplicates)
+			}
+
+			if tt.wantDuplicate && !result.Posts[1].Duplicate {
+				t.Error("Expected second post to be marked as duplicate")
+			}
+		})
+	}
+}
+
+func TestTransformDedupCommit(t *testing.T) {
+	fingerprint, _ := NewFingerprintStage(nil, nil)
+	dedupStage, _ := NewDedupStage(memoryWindow{}, DedupDrop)
+	transformer := New("test_source", WithStages(fingerprint, dedupStage))
+	posts := []models.Post{{ID: 1, Title: "same", Body: "content"}}
+
+	// A batch that was not stored is not remembered when retried
+	if _, err := transformer.Transform(context.Background(), posts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	result, err := transformer.Transform(context.Background(), posts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Posts) != 1 {
+		t.Fatalf("Expected the retried post to go through, got %d posts", len(result.Posts))
+	}
+
+	// Once committed, the post is a duplicate
+	if err := transformer.Commit(context.Background(), result); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	result, _ = transformer.Transform(context.Background(), posts)
+	if len(result.Posts) != 0 || result.Duplicates != 1 {
+		t.Errorf("Expected the committed post to be dropped as a duplicate, got %d posts", len(result.Posts))
+	}
+}
+
+func TestTransformDedupCommitDropped(t *testing.T) {
+	fingerprint, _ := NewFingerprintStage(nil, nil)
+	dedupStage, _ := NewDedupStage(memoryWindow{}, DedupDrop)
+	// Sampling after dedup drops the first post
+	sampleOut := stageFunc(func(record *Record) {
+		if record.Raw.ID == 1 {
+			record.Drop()
+		}
+	})
+	transformer := New("test_source", WithStages(fingerprint, dedupStage, sampleOut))
+
+	result, err := transformer.Transform(context.Background(), []models.Post{
+		{ID: 1, Title: "dropped", Body: "content"},
+		{ID: 2, Title: "stored", Body: "content"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := transformer.Commit(context.Background(), result); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	// The content of the dropped post was never stored, so it is not a
+	// duplicate when it comes back
+	result, _ = transformer.Transform(context.Background(), []models.Post{
+		{ID: 3, Title: "dropped", Body: "content"},
+		{ID: 4, Title: "stored", Body: "content"},
+	})
+	if len(result.Posts) != 1 || result.Posts[0].PostID != 3 || result.Duplicates != 1 {
+		t.Errorf("Expected the stored content alone to be a duplicate, got %+v", result.Posts)
+	}
+}
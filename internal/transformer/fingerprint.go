@@ -0,0 +1,90 @@
+package transformer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultFingerprintFields are the fields hashed when no fields are configured
+var DefaultFingerprintFields = []string{"title", "body"}
+
+// Normalization rules applied to field values before hashing
+const (
+	NormalizeLowercase          = "lowercase"
+	NormalizeTrim               = "trim"
+	NormalizeCollapseWhitespace = "collapse_whitespace"
+	NormalizeStripDigits        = "strip_digits"
+)
+
+var (
+	whitespaceRun = regexp.MustCompile(`\s+`)
+	digitRun      = regexp.MustCompile(`[0-9]+`)
+)
+
+// FingerprintStage computes a content hash over a set of record fields
+type FingerprintStage struct {
+	fields []string
+	rules  []string
+}
+
+// NewFingerprintStage creates a new FingerprintStage instance
+func NewFingerprintStage(fields, rules []string) (*FingerprintStage, error) {
+	if len(fields) == 0 {
+		fields = DefaultFingerprintFields
+	}
+
+	for _, rule := range rules {
+		switch rule {
+		case NormalizeLowercase, NormalizeTrim, NormalizeCollapseWhitespace, NormalizeStripDigits:
+		default:
+			return nil, fmt.Errorf("unknown normalization rule: %s", rule)
+		}
+	}
+
+	return &FingerprintStage{
+		fields: fields,
+		rules:  rules,
+	}, nil
+}
+
+// Name returns the name of the stage
+func (s *FingerprintStage) Name() string {
+	return "fingerprint"
+}
+
+// Process sets the fingerprint of the record
+func (s *FingerprintStage) Process(ctx context.Context, record *Record) error {
+	h := sha256.New()
+	for _, name := range s.fields {
+		value := ""
+		if v, ok := record.Field(name); ok && v != nil {
+			value = s.normalize(fmt.Sprint(v))
+		}
+		// Separate fields so that ("ab", "c") and ("a", "bc") hash differently
+		fmt.Fprintf(h, "%s=%d:%s\x00", name, len(value), value)
+	}
+
+	record.Enriched.Fingerprint = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// normalize applies the configured rules in order
+func (s *FingerprintStage) normalize(value string) string {
+	for _, rule := range s.rules {
+		switch rule {
+		case NormalizeLowercase:
+			value = strings.ToLower(value)
+		case NormalizeTrim:
+			value = strings.TrimSpace(value)
+		case NormalizeCollapseWhitespace:
+			value = strings.TrimSpace(whitespaceRun.ReplaceAllString(value, " "))
+		case NormalizeStripDigits:
+			value = digitRun.ReplaceAllString(value, "")
+		}
+	}
+	return value
+}
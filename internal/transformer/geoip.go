@@ -0,0 +1,174 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// geoRecord holds the fields read from GeoLite2/GeoIP2 City, Country and
+// ASN databases. Fields missing from a database are left empty.
+type geoRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	ASN          uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// geoDatabase is a single .mmdb file, reopened when it changes on disk
+type geoDatabase struct {
+	path    string
+	watcher *fileWatcher
+
+	mu     sync.RWMutex
+	reader *maxminddb.Reader
+}
+
+// GeoIPStage resolves the IP address held in a record field into country,
+// city and ASN attributes using local MaxMind databases. Databases that do
+// not exist are skipped, so the stage is a no-op until a file appears.
+type GeoIPStage struct {
+	field     string
+	databases []*geoDatabase
+}
+
+// NewGeoIPStage creates a new GeoIPStage reading the IP address from field.
+// A City or Country database and an ASN database can be combined.
+func NewGeoIPStage(field string, paths []string) *GeoIPStage {
+	s := &GeoIPStage{field: field}
+	for _, path := range paths {
+		db := &geoDatabase{
+			path:    path,
+			watcher: newFileWatcher(path, 10*time.Second),
+		}
+		db.reload()
+		s.databases = append(s.databases, db)
+	}
+	return s
+}
+
+// Name returns the name of the stage
+func (s *GeoIPStage) Name() string {
+	return "geoip"
+}
+
+// Process sets the geo attributes of the record
+func (s *GeoIPStage) Process(ctx context.Context, record *Record) error {
+	value, ok := record.Field(s.field)
+	if !ok || value == nil {
+		return nil
+	}
+
+	ip := parseIP(fmt.Sprint(value))
+	if ip == nil {
+		record.Count("geoip_invalid")
+		return nil
+	}
+
+	var geo models.Geo
+	found := false
+	for _, db := range s.databases {
+		db.reload()
+		ok, err := db.lookup(ip, &geo)
+		if err != nil {
+			// e.g. an IPv6 address against an IPv4-only database
+			record.Count("geoip_error")
+			continue
+		}
+		found = found || ok
+	}
+
+	if !found {
+		record.Count("geoip_missed")
+		return nil
+	}
+
+	record.Count("geoip_matched")
+	record.Enriched.Geo = &geo
+	return nil
+}
+
+// reload reopens the database if the file changed
+func (db *geoDatabase) reload() {
+	changed, err := db.watcher.changed()
+	if err != nil || !changed {
+		return
+	}
+
+	reader, err := maxminddb.Open(db.path)
+	if err != nil {
+		log.Printf("Failed to open GeoIP database %s: %v", db.path, err)
+		return
+	}
+
+	db.mu.Lock()
+	previous := db.reader
+	db.reader = reader
+	db.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	log.Printf("Loaded GeoIP database %s (%s)", db.path, reader.Metadata.DatabaseType)
+}
+
+// lookup merges the database record for ip into geo
+func (db *geoDatabase) lookup(ip net.IP, geo *models.Geo) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.reader == nil {
+		return false, nil
+	}
+
+	var rec geoRecord
+	_, ok, err := db.reader.LookupNetwork(ip, &rec)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if rec.Country.ISOCode != "" {
+		geo.CountryCode = rec.Country.ISOCode
+		geo.Country = rec.Country.Names["en"]
+	}
+	if name := rec.City.Names["en"]; name != "" {
+		geo.City = name
+	}
+	if rec.Location.Latitude != 0 || rec.Location.Longitude != 0 {
+		geo.Latitude = rec.Location.Latitude
+		geo.Longitude = rec.Location.Longitude
+	}
+	if rec.ASN != 0 {
+		geo.ASN = rec.ASN
+		geo.ASOrganization = rec.Organization
+	}
+	return true, nil
+}
+
+// parseIP accepts bare addresses as well as host:port and bracketed IPv6
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
@@ -0,0 +1,183 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// mmdbWriter encodes values in the MaxMind DB data section format
+type mmdbWriter struct {
+	bytes.Buffer
+}
+
+func (w *mmdbWriter) str(s string) {
+	w.WriteByte(2<<5 | byte(len(s)))
+	w.WriteString(s)
+}
+
+func (w *mmdbWriter) mapHeader(n int) {
+	w.WriteByte(7<<5 | byte(n))
+}
+
+func (w *mmdbWriter) uint16(v uint16) {
+	w.WriteByte(5<<5 | 2)
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *mmdbWriter) uint32(v uint32) {
+	w.WriteByte(6<<5 | 4)
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *mmdbWriter) double(v float64) {
+	w.WriteByte(3<<5 | 8)
+	binary.Write(w, binary.BigEndian, math.Float64bits(v))
+}
+
+// writeTestMMDB writes an IPv4 database with a single node: addresses in
+// 128.0.0.0/1 resolve to Sydney, everything else is not found
+func writeTestMMDB(t *testing.T, path string) {
+	t.Helper()
+	const nodeCount = 1
+
+	var db mmdbWriter
+
+	// Search tree: left record is "not found", right record points at the
+	// start of the data section
+	db.Write([]byte{0, 0, nodeCount, 0, 0, nodeCount + 16})
+	db.Write(make([]byte, 16))
+
+	// Data section
+	db.mapHeader(4)
+	db.str("country")
+	db.mapHeader(2)
+	db.str("iso_code")
+	db.str("AU")
+	db.str("names")
+	db.mapHeader(1)
+	db.str("en")
+	db.str("Australia")
+	db.str("city")
+	db.mapHeader(1)
+	db.str("names")
+	db.mapHeader(1)
+	db.str("en")
+	db.str("Sydney")
+	db.str("location")
+	db.mapHeader(2)
+	db.str("latitude")
+	db.double(-33.86)
+	db.str("longitude")
+	db.double(151.2)
+	db.str("autonomous_system_number")
+	db.uint32(64496)
+
+	// Metadata
+	db.WriteString("\xAB\xCD\xEFMaxMind.com")
+	db.mapHeader(5)
+	db.str("node_count")
+	db.uint32(nodeCount)
+	db.str("record_size")
+	db.uint16(24)
+	db.str("ip_version")
+	db.uint16(4)
+	db.str("database_type")
+	db.str("Test-City")
+	db.str("binary_format_major_version")
+	db.uint16(2)
+
+	if err := os.WriteFile(path, db.Bytes(), 0o644); err != nil {
+		t.Fatalf("Failed to write test database: %v", err)
+	}
+}
+
+func TestGeoIPStage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	writeTestMMDB(t, path)
+
+	stage := NewGeoIPStage("client_ip", []string{path})
+
+	tests := []struct {
+		ip          string
+		wantGeo     *models.Geo
+		wantCounter string
+	}{
+		{
+			ip: "203.0.113.7",
+			wantGeo: &models.Geo{
+				CountryCode: "AU",
+				Country:     "Australia",
+				City:        "Sydney",
+				Latitude:    -33.86,
+				Longitude:   151.2,
+				ASN:         64496,
+			},
+			wantCounter: "geoip_matched",
+		},
+		{ip: "203.0.113.7:443", wantGeo: &models.Geo{CountryCode: "AU"}, wantCounter: "geoip_matched"},
+		{ip: "10.0.0.1", wantCounter: "geoip_missed"},
+		{ip: "not-an-ip", wantCounter: "geoip_invalid"},
+	}
+
+	for _, tt := range tests {
+		record := &Record{Raw: models.Post{Fields: map[string]interface{}{"client_ip": tt.ip}}}
+		if err := stage.Process(context.Background(), record); err != nil {
+			t.Fatalf("Expected no error for %s, got %v", tt.ip, err)
+		}
+
+		if record.counters[tt.wantCounter] != 1 {
+			t.Errorf("Expected %s to be counted for %s, got %v", tt.wantCounter, tt.ip, record.counters)
+		}
+
+		geo := record.Enriched.Geo
+		if tt.wantGeo == nil {
+			if geo != nil {
+				t.Errorf("Expected no geo for %s, got %+v", tt.ip, geo)
+			}
+			continue
+		}
+
+		if geo == nil {
+			t.Fatalf("Expected geo for %s, got nil", tt.ip)
+		}
+		if geo.CountryCode != tt.wantGeo.CountryCode {
+			t.Errorf("Expected country code %s for %s, got %s", tt.wantGeo.CountryCode, tt.ip, geo.CountryCode)
+		}
+		if tt.wantGeo.City != "" && *geo != *tt.wantGeo {
+			t.Errorf("Expected %+v for %s, got %+v", tt.wantGeo, tt.ip, geo)
+		}
+	}
+}
+
+func TestGeoIPStageMissingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	stage := NewGeoIPStage("client_ip", []string{path})
+
+	// Without a database the stage leaves records untouched
+	record := &Record{Raw: models.Post{Fields: map[string]interface{}{"client_ip": "203.0.113.7"}}}
+	if err := stage.Process(context.Background(), record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if record.Enriched.Geo != nil {
+		t.Errorf("Expected no geo without a database, got %+v", record.Enriched.Geo)
+	}
+
+	// The database is picked up once it appears
+	writeTestMMDB(t, path)
+	stage.databases[0].watcher.interval = 0
+
+	record = &Record{Raw: models.Post{Fields: map[string]interface{}{"client_ip": "203.0.113.7"}}}
+	if err := stage.Process(context.Background(), record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if record.Enriched.Geo == nil || record.Enriched.Geo.City != "Sydney" {
+		t.Errorf("Expected Sydney after the database appeared, got %+v", record.Enriched.Geo)
+	}
+}
@@ -0,0 +1,297 @@
+package transformer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Behaviours when a record has no row in a lookup table
+const (
+	LookupMissEmpty   = "empty"
+	LookupMissDefault = "default"
+	LookupMissDrop    = "drop"
+)
+
+// LookupConfig describes a reference table used to enrich records
+type LookupConfig struct {
+	// Name identifies the table in counters and logs
+	Name string `json:"name"`
+	// Path is a CSV file with a header row, or a JSON file holding either an
+	// array of objects or an object keyed by the lookup key
+	Path string `json:"path"`
+	// Field is the record field holding the lookup key, e.g. "userId"
+	Field string `json:"field"`
+	// KeyColumn is the table column matched against Field, defaulting to Field
+	KeyColumn string `json:"key_column"`
+	// Columns are the table columns attached to the record, all of them when empty
+	Columns []string `json:"columns"`
+	// OnMiss is one of "empty", "default" or "drop"
+	OnMiss string `json:"on_miss"`
+	// Defaults are attached on a miss when OnMiss is "default"
+	Defaults map[string]interface{} `json:"defaults"`
+}
+
+// ReadLookupConfigs reads a JSON array of lookup table configurations
+func ReadLookupConfigs(path string) ([]LookupConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lookup config: %w", err)
+	}
+
+	var configs []LookupConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse lookup config: %w", err)
+	}
+
+	return configs, nil
+}
+
+// LookupStage attaches the columns of a reference table row to each record,
+// reloading the table whenever its file changes
+type LookupStage struct {
+	config  LookupConfig
+	watcher *fileWatcher
+
+	mu   sync.RWMutex
+	rows map[string]map[string]interface{}
+}
+
+// NewLookupStage creates a new LookupStage instance and loads its table
+func NewLookupStage(config LookupConfig) (*LookupStage, error) {
+	if config.Name == "" || config.Path == "" || config.Field == "" {
+		return nil, fmt.Errorf("lookup table needs a name, path and field")
+	}
+	if config.KeyColumn == "" {
+		config.KeyColumn = config.Field
+	}
+	switch config.OnMiss {
+	case "":
+		config.OnMiss = LookupMissEmpty
+	case LookupMissEmpty, LookupMissDefault, LookupMissDrop:
+	default:
+		return nil, fmt.Errorf("unknown on_miss behaviour for lookup %s: %s", config.Name, config.OnMiss)
+	}
+
+	s := &LookupStage{
+		config:  config,
+		watcher: newFileWatcher(config.Path, time.Second),
+	}
+
+	if _, err := s.watcher.changed(); err != nil {
+		return nil, fmt.Errorf("failed to stat lookup table %s: %w", config.Name, err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Name returns the name of the stage
+func (s *LookupStage) Name() string {
+	return "lookup_" + s.config.Name
+}
+
+// Process attaches the matching row to the record
+func (s *LookupStage) Process(ctx context.Context, record *Record) error {
+	s.reload()
+
+	var row map[string]interface{}
+	if value, ok := record.Field(s.config.Field); ok && value != nil {
+		s.mu.RLock()
+		row = s.rows[lookupKey(value)]
+		s.mu.RUnlock()
+	}
+
+	if row == nil {
+		record.Count(s.Name() + "_missed")
+		switch s.config.OnMiss {
+		case LookupMissDefault:
+			setAttributes(record, s.config.Defaults)
+		case LookupMissDrop:
+			record.Drop()
+		}
+		return nil
+	}
+
+	record.Count(s.Name() + "_matched")
+	setAttributes(record, row)
+	return nil
+}
+
+// reload reloads the table if its file changed, keeping the current rows
+// if the new file cannot be read
+func (s *LookupStage) reload() {
+	changed, err := s.watcher.changed()
+	if err != nil {
+		log.Printf("Lookup table %s unavailable, keeping previous rows: %v", s.config.Name, err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	if err := s.load(); err != nil {
+		log.Printf("Failed to reload lookup table %s, keeping previous rows: %v", s.config.Name, err)
+		return
+	}
+	log.Printf("Reloaded lookup table %s", s.config.Name)
+}
+
+// load reads the table from disk and indexes it by key
+func (s *LookupStage) load() error {
+	file, err := os.Open(s.config.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open lookup table %s: %w", s.config.Name, err)
+	}
+	defer file.Close()
+
+	var records []map[string]interface{}
+	if strings.EqualFold(filepath.Ext(s.config.Path), ".csv") {
+		records, err = readCSVTable(file)
+	} else {
+		records, err = readJSONTable(file, s.config.KeyColumn)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read lookup table %s: %w", s.config.Name, err)
+	}
+
+	rows := make(map[string]map[string]interface{}, len(records))
+	for _, record := range records {
+		key, ok := record[s.config.KeyColumn]
+		if !ok || key == nil {
+			continue
+		}
+		rows[lookupKey(key)] = s.project(record)
+	}
+
+	s.mu.Lock()
+	s.rows = rows
+	s.mu.Unlock()
+	return nil
+}
+
+// project keeps the configured columns of a table row
+func (s *LookupStage) project(record map[string]interface{}) map[string]interface{} {
+	row := make(map[string]interface{})
+	if len(s.config.Columns) == 0 {
+		for column, value := range record {
+			if column != s.config.KeyColumn {
+				row[column] = value
+			}
+		}
+		return row
+	}
+
+	for _, column := range s.config.Columns {
+		if value, ok := record[column]; ok {
+			row[column] = value
+		}
+	}
+	return row
+}
+
+// readCSVTable reads a CSV file whose first row holds the column names
+func readCSVTable(r io.Reader) ([]map[string]interface{}, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for {
+		line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if i < len(line) {
+				record[column] = line[i]
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// readJSONTable reads an array of objects, or an object mapping keys to rows
+func readJSONTable(r io.Reader, keyColumn string) ([]map[string]interface{}, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(raw, &records); err == nil {
+		return records, nil
+	}
+
+	var keyed map[string]map[string]interface{}
+	if err := json.Unmarshal(raw, &keyed); err != nil {
+		return nil, fmt.Errorf("expected an array of objects or an object of objects: %w", err)
+	}
+
+	for key, record := range keyed {
+		if record == nil {
+			record = make(map[string]interface{})
+		}
+		record[keyColumn] = key
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// lookupKey turns a field value into the string used to index tables, so
+// that 7, 7.0, "7" and json.Number("7") all match the same row
+func lookupKey(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+		if f, err := v.Float64(); err == nil {
+			return lookupKey(f)
+		}
+		return v.String()
+	case float64:
+		if v == float64(int64(v)) {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// setAttributes copies values onto the record attributes
+func setAttributes(record *Record, values map[string]interface{}) {
+	if len(values) == 0 {
+		return
+	}
+	if record.Enriched.Attributes == nil {
+		record.Enriched.Attributes = make(map[string]interface{}, len(values))
+	}
+	for name, value := range values {
+		record.Enriched.Attributes[name] = value
+	}
+}
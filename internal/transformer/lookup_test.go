@@ -0,0 +1,173 @@
+package transformer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestLookupStageCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owners.csv")
+	writeFile(t, path, "userId,team,owner,tier\n1,payments,alice,gold\n2,search,bob,silver\n")
+
+	stage, err := NewLookupStage(LookupConfig{
+		Name:    "owners",
+		Path:    path,
+		Field:   "userId",
+		Columns: []string{"team", "tier"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	record := &Record{Enriched: models.EnrichedPost{UserID: 1}}
+	if err := stage.Process(context.Background(), record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	attributes := record.Enriched.Attributes
+	if attributes["team"] != "payments" || attributes["tier"] != "gold" {
+		t.Errorf("Expected payments/gold, got %v", attributes)
+	}
+
+	if _, ok := attributes["owner"]; ok {
+		t.Error("Expected unlisted column 'owner' not to be attached")
+	}
+
+	if record.counters["lookup_owners_matched"] != 1 {
+		t.Errorf("Expected a match to be counted, got %v", record.counters)
+	}
+}
+
+func TestLookupStageJSONRawField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.json")
+	writeFile(t, path, `{"checkout": {"owner": "carol", "tier": 1}}`)
+
+	stage, err := NewLookupStage(LookupConfig{
+		Name:      "services",
+		Path:      path,
+		Field:     "service",
+		KeyColumn: "name",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	record := &Record{Raw: models.Post{Fields: map[string]interface{}{"service": "checkout"}}}
+	if err := stage.Process(context.Background(), record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if record.Enriched.Attributes["owner"] != "carol" {
+		t.Errorf("Expected owner 'carol', got %v", record.Enriched.Attributes["owner"])
+	}
+}
+
+func TestLookupStageOnMiss(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owners.json")
+	writeFile(t, path, `[{"userId": 1, "team": "payments"}]`)
+
+	tests := []struct {
+		onMiss   string
+		wantTeam interface{}
+		wantDrop bool
+	}{
+		{LookupMissEmpty, nil, false},
+		{LookupMissDefault, "unowned", false},
+		{LookupMissDrop, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.onMiss, func(t *testing.T) {
+			stage, err := NewLookupStage(LookupConfig{
+				Name:     "owners",
+				Path:     path,
+				Field:    "userId",
+				OnMiss:   tt.onMiss,
+				Defaults: map[string]interface{}{"team": "unowned"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create stage: %v", err)
+			}
+
+			// Integer fields match numeric keys decoded from the JSON table
+			record := &Record{Enriched: models.EnrichedPost{UserID: 1}}
+			stage.Process(context.Background(), record)
+			if record.Enriched.Attributes["team"] != "payments" {
+				t.Fatalf("Expected a match for userId 1, got %v", record.Enriched.Attributes)
+			}
+
+			record = &Record{Enriched: models.EnrichedPost{UserID: 9}}
+			if err := stage.Process(context.Background(), record); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if got := record.Enriched.Attributes["team"]; got != tt.wantTeam {
+				t.Errorf("Expected team %v, got %v", tt.wantTeam, got)
+			}
+
+			if record.Dropped() != tt.wantDrop {
+				t.Errorf("Expected dropped %v, got %v", tt.wantDrop, record.Dropped())
+			}
+
+			if record.counters["lookup_owners_missed"] != 1 {
+				t.Errorf("Expected a miss to be counted, got %v", record.counters)
+			}
+		})
+	}
+}
+
+func TestLookupStageReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owners.csv")
+	writeFile(t, path, "userId,team\n1,payments\n")
+
+	stage, err := NewLookupStage(LookupConfig{Name: "owners", Path: path, Field: "userId"})
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	stage.watcher.interval = 0
+
+	writeFile(t, path, "userId,team\n1,platform\n")
+	// Make sure the modification time moves even on coarse filesystems
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+
+	record := &Record{Enriched: models.EnrichedPost{UserID: 1}}
+	stage.Process(context.Background(), record)
+	if record.Enriched.Attributes["team"] != "platform" {
+		t.Errorf("Expected reloaded team 'platform', got %v", record.Enriched.Attributes["team"])
+	}
+
+	// A broken file keeps the previous rows
+	writeFile(t, path, "")
+	os.Chtimes(path, future.Add(time.Minute), future.Add(time.Minute))
+
+	record = &Record{Enriched: models.EnrichedPost{UserID: 1}}
+	stage.Process(context.Background(), record)
+	if record.Enriched.Attributes["team"] != "platform" {
+		t.Errorf("Expected previous team 'platform' to be kept, got %v", record.Enriched.Attributes["team"])
+	}
+}
+
+func TestNewLookupStageInvalid(t *testing.T) {
+	if _, err := NewLookupStage(LookupConfig{Name: "missing", Path: "/does/not/exist.csv", Field: "userId"}); err == nil {
+		t.Error("Expected error for missing file, got nil")
+	}
+
+	path := filepath.Join(t.TempDir(), "owners.csv")
+	writeFile(t, path, "userId,team\n")
+	if _, err := NewLookupStage(LookupConfig{Name: "owners", Path: path, Field: "userId", OnMiss: "explode"}); err == nil {
+		t.Error("Expected error for unknown on_miss, got nil")
+	}
+}
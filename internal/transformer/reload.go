@@ -0,0 +1,53 @@
+package transformer
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// fileWatcher reports when a file has changed on disk. It polls the
+// modification time at most once per interval so it can be called from the
+// per-record path.
+type fileWatcher struct {
+	mu        sync.Mutex
+	path      string
+	interval  time.Duration
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// newFileWatcher creates a watcher for path
+func newFileWatcher(path string, interval time.Duration) *fileWatcher {
+	return &fileWatcher{
+		path:     path,
+		interval: interval,
+	}
+}
+
+// changed reports whether the file was modified since the last call that
+// returned true. A missing file is reported as an error.
+func (w *fileWatcher) changed() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	if !w.checkedAt.IsZero() && now.Sub(w.checkedAt) < w.interval {
+		return false, nil
+	}
+	w.checkedAt = now
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false, nil
+	}
+
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	return true, nil
+}
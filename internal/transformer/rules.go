@@ -0,0 +1,141 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/expr"
+)
+
+// Rule actions
+const (
+	RuleDrop  = "drop"
+	RuleTag   = "tag"
+	RuleRoute = "route"
+)
+
+// RuleConfig describes a filtering or routing rule
+type RuleConfig struct {
+	// Name identifies the rule in counters
+	Name string `json:"name"`
+	// When is the condition, see package expr for the syntax
+	When string `json:"when"`
+	// Action is one of "drop", "tag" or "route"
+	Action string `json:"action"`
+	// Tags are added to matching records by the "tag" action
+	Tags []string `json:"tags"`
+	// Collection receives matching records for the "route" action
+	Collection string `json:"collection"`
+}
+
+// ReadRuleConfigs reads a JSON array of rule configurations
+func ReadRuleConfigs(path string) ([]RuleConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules: %w", err)
+	}
+
+	var configs []RuleConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	return configs, nil
+}
+
+// rule is a compiled RuleConfig
+type rule struct {
+	config RuleConfig
+	when   *expr.Expression
+}
+
+// RulesStage evaluates rules in order against each record. A matching drop
+// rule discards the record and stops evaluation, tag rules accumulate tags
+// and the first matching route rule picks the destination collection.
+type RulesStage struct {
+	rules []rule
+}
+
+// NewRulesStage compiles the rules into a new RulesStage instance
+func NewRulesStage(configs []RuleConfig) (*RulesStage, error) {
+	s := &RulesStage{}
+	for i, config := range configs {
+		if config.Name == "" {
+			config.Name = fmt.Sprintf("rule%d", i+1)
+		}
+
+		switch config.Action {
+		case RuleDrop:
+		case RuleTag:
+			if len(config.Tags) == 0 {
+				return nil, fmt.Errorf("rule %s: tag action needs tags", config.Name)
+			}
+		case RuleRoute:
+			if config.Collection == "" {
+				return nil, fmt.Errorf("rule %s: route action needs a collection", config.Name)
+			}
+		default:
+			return nil, fmt.Errorf("rule %s: unknown action %q", config.Name, config.Action)
+		}
+
+		when, err := expr.Compile(config.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", config.Name, err)
+		}
+
+		s.rules = append(s.rules, rule{config: config, when: when})
+	}
+	return s, nil
+}
+
+// Name returns the name of the stage
+func (s *RulesStage) Name() string {
+	return "rules"
+}
+
+// Process applies the matching rules to the record. A rule that fails to
+// evaluate is counted and treated as not matching.
+func (s *RulesStage) Process(ctx context.Context, record *Record) error {
+	for _, r := range s.rules {
+		matched, err := r.when.Match(record.Field)
+		if err != nil {
+			record.Count("rule_" + r.config.Name + "_error")
+			continue
+		}
+		if !matched {
+			continue
+		}
+		record.Count("rule_" + r.config.Name + "_matched")
+
+		switch r.config.Action {
+		case RuleDrop:
+			record.Drop()
+			return nil
+		case RuleTag:
+			addTags(record, r.config.Tags)
+		case RuleRoute:
+			if record.Enriched.Collection == "" {
+				record.Enriched.Collection = r.config.Collection
+			}
+		}
+	}
+	return nil
+}
+
+// addTags adds tags to the record, skipping the ones it already has
+func addTags(record *Record, tags []string) {
+	for _, tag := range tags {
+		present := false
+		for _, existing := range record.Enriched.Tags {
+			if existing == tag {
+				present = true
+				break
+			}
+		}
+		if !present {
+			record.Enriched.Tags = append(record.Enriched.Tags, tag)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package transformer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+func TestRulesStage(t *testing.T) {
+	stage, err := NewRulesStage([]RuleConfig{
+		{Name: "healthchecks", When: `title contains "healthcheck"`, Action: RuleDrop},
+		{Name: "errors", When: `severity in ["error", "fatal"]`, Action: RuleTag, Tags: []string{"alert"}},
+		{Name: "payments", When: `attributes.team == "payments"`, Action: RuleRoute, Collection: "payments_logs"},
+		{Name: "fallback", When: `true`, Action: RuleRoute, Collection: "other_logs"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	transformer := New("test_source", WithStages(stage))
+	posts := []models.Post{
+		{ID: 1, Title: "GET /healthcheck"},
+		{ID: 2, Title: "charge failed", Fields: map[string]interface{}{"severity": "error"}},
+		{ID: 3, Title: "hello"},
+	}
+
+	// Attach a team to the second post the way a lookup stage would
+	teams := stageFunc(func(record *Record) {
+		if record.Enriched.PostID == 2 {
+			setAttributes(record, map[string]interface{}{"team": "payments"})
+		}
+	})
+	transformer.stages = append([]Stage{teams}, transformer.stages...)
+
+	result, err := transformer.Transform(context.Background(), posts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.Dropped != 1 || len(result.Posts) != 2 {
+		t.Fatalf("Expected 1 dropped and 2 kept, got %d and %d", result.Dropped, len(result.Posts))
+	}
+
+	charge := result.Posts[0]
+	if len(charge.Tags) != 1 || charge.Tags[0] != "alert" {
+		t.Errorf("Expected tags [alert], got %v", charge.Tags)
+	}
+	if charge.Collection != "payments_logs" {
+		t.Errorf("Expected collection 'payments_logs', got '%s'", charge.Collection)
+	}
+
+	if result.Posts[1].Collection != "other_logs" {
+		t.Errorf("Expected collection 'other_logs', got '%s'", result.Posts[1].Collection)
+	}
+
+	if result.Counters["rule_healthchecks_matched"] != 1 || result.Counters["rule_fallback_matched"] != 2 {
+		t.Errorf("Unexpected rule counters: %v", result.Counters)
+	}
+}
+
+func TestNewRulesStageInvalid(t *testing.T) {
+	for _, config := range []RuleConfig{
+		{When: `title ==`, Action: RuleDrop},
+		{When: `true`, Action: "explode"},
+		{When: `true`, Action: RuleTag},
+		{When: `true`, Action: RuleRoute},
+	} {
+		if _, err := NewRulesStage([]RuleConfig{config}); err == nil {
+			t.Errorf("Expected error for %+v, got nil", config)
+		}
+	}
+}
+
+// stageFunc adapts a function to the Stage interface
+type stageFunc func(record *Record)
+
+func (f stageFunc) Name() string {
+	return "func"
+}
+
+func (f stageFunc) Process(ctx context.Context, record *Record) error {
+	f(record)
+	return nil
+}
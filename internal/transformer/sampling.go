@@ -0,0 +1,167 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// SamplingConfig configures the SamplingStage
+type SamplingConfig struct {
+	// Rate is the fraction of records kept, between 0 and 1
+	Rate float64
+	// KeyField makes the rate deterministic: records are kept or dropped
+	// together based on a hash of this field. Random sampling when empty.
+	KeyField string
+	// CapField and Cap limit each value of CapField to about Cap records per
+	// CapWindow. Keys over the cap are sampled at a rate derived from their
+	// volume so that counts can still be re-weighted.
+	CapField  string
+	Cap       int
+	CapWindow time.Duration
+	// Random drives random sampling, seeded from the clock when nil
+	Random rand.Source
+}
+
+// SamplingStage drops a share of the records and stores the probability
+// each kept record had of being kept in its sample rate, so aggregations
+// can weight records by 1/sample_rate
+type SamplingStage struct {
+	config SamplingConfig
+
+	mu          sync.Mutex
+	random      *rand.Rand
+	windowStart time.Time
+	current     map[string]int
+	previous    map[string]int
+	now         func() time.Time
+}
+
+// NewSamplingStage creates a new SamplingStage instance
+func NewSamplingStage(config SamplingConfig) (*SamplingStage, error) {
+	if config.Rate <= 0 || config.Rate > 1 {
+		return nil, fmt.Errorf("sample rate must be in (0, 1], got %v", config.Rate)
+	}
+	if config.CapField != "" {
+		if config.Cap < 1 {
+			return nil, fmt.Errorf("sample cap must be at least 1, got %d", config.Cap)
+		}
+		if config.CapWindow <= 0 {
+			return nil, fmt.Errorf("sample cap window must be positive, got %v", config.CapWindow)
+		}
+	}
+
+	random := config.Random
+	if random == nil {
+		random = rand.NewSource(time.Now().UnixNano())
+	}
+
+	return &SamplingStage{
+		config:   config,
+		random:   rand.New(random),
+		current:  make(map[string]int),
+		previous: make(map[string]int),
+		now:      time.Now,
+	}, nil
+}
+
+// Name returns the name of the stage
+func (s *SamplingStage) Name() string {
+	return "sampling"
+}
+
+// Process keeps or drops the record
+func (s *SamplingStage) Process(ctx context.Context, record *Record) error {
+	rate := s.config.Rate
+	if rate < 1 && !s.keep(record, s.config.KeyField, rate) {
+		record.Count("sampled_out")
+		record.Drop()
+		return nil
+	}
+
+	if s.config.CapField != "" {
+		capRate := s.capRate(record)
+		if capRate < 1 && !s.keep(record, "", capRate) {
+			record.Count("sampled_out_capped")
+			record.Drop()
+			return nil
+		}
+		rate *= capRate
+	}
+
+	record.Enriched.SampleRate = roundRate(rate)
+	return nil
+}
+
+// keep decides whether a record survives sampling at rate, using a hash of
+// keyField when set so the decision is the same for every record of a key
+func (s *SamplingStage) keep(record *Record, keyField string, rate float64) bool {
+	if keyField != "" {
+		if value, ok := record.Field(keyField); ok && value != nil {
+			return hashFraction(lookupKey(value)) < rate
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.random.Float64() < rate
+}
+
+// capRate returns the rate for the record's cap key, based on the volume of
+// the key in the previous and current windows
+func (s *SamplingStage) capRate(record *Record) float64 {
+	key := ""
+	if value, ok := record.Field(s.config.CapField); ok && value != nil {
+		key = lookupKey(value)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if elapsed := now.Sub(s.windowStart); elapsed >= s.config.CapWindow {
+		if elapsed >= 2*s.config.CapWindow {
+			s.previous = make(map[string]int)
+		} else {
+			s.previous = s.current
+		}
+		s.current = make(map[string]int)
+		s.windowStart = now
+	}
+
+	s.current[key]++
+	volume := s.current[key]
+	if s.previous[key] > volume {
+		volume = s.previous[key]
+	}
+
+	if volume <= s.config.Cap {
+		return 1
+	}
+	return float64(s.config.Cap) / float64(volume)
+}
+
+// hashFraction maps a key onto [0, 1)
+func hashFraction(key string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	// FNV leaves the high bits of short keys poorly mixed, so finish with
+	// the splitmix64 finalizer
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11) / float64(uint64(1)<<53)
+}
+
+// roundRate avoids storing float noise such as 0.30000000000000004
+func roundRate(rate float64) float64 {
+	return math.Round(rate*1e9) / 1e9
+}
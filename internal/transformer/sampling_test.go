@@ -0,0 +1,116 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+func TestSamplingStageRatio(t *testing.T) {
+	stage, err := NewSamplingStage(SamplingConfig{Rate: 0.25, Random: rand.NewSource(1)})
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		record := &Record{Enriched: models.EnrichedPost{PostID: i}}
+		stage.Process(context.Background(), record)
+		if !record.Dropped() {
+			kept++
+			if record.Enriched.SampleRate != 0.25 {
+				t.Fatalf("Expected sample rate 0.25, got %v", record.Enriched.SampleRate)
+			}
+		}
+	}
+
+	if kept < 2200 || kept > 2800 {
+		t.Errorf("Expected about 2500 records kept, got %d", kept)
+	}
+}
+
+func TestSamplingStageDeterministicKey(t *testing.T) {
+	stage, err := NewSamplingStage(SamplingConfig{Rate: 0.5, KeyField: "userId"})
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+
+	keptUsers := 0
+	for user := 0; user < 200; user++ {
+		var decisions []bool
+		for i := 0; i < 5; i++ {
+			record := &Record{Enriched: models.EnrichedPost{UserID: user, PostID: i}}
+			stage.Process(context.Background(), record)
+			decisions = append(decisions, record.Dropped())
+		}
+
+		// All records of a user share the same decision
+		for _, dropped := range decisions {
+			if dropped != decisions[0] {
+				t.Fatalf("Expected the same decision for every record of user %d, got %v", user, decisions)
+			}
+		}
+		if !decisions[0] {
+			keptUsers++
+		}
+	}
+
+	if keptUsers < 70 || keptUsers > 130 {
+		t.Errorf("Expected about half of the users kept, got %d of 200", keptUsers)
+	}
+}
+
+func TestSamplingStageCap(t *testing.T) {
+	stage, err := NewSamplingStage(SamplingConfig{Rate: 1, CapField: "userId", Cap: 10, CapWindow: time.Minute, Random: rand.NewSource(1)})
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stage.now = func() time.Time { return now }
+
+	process := func(user, n int) (kept int, weighted float64) {
+		for i := 0; i < n; i++ {
+			record := &Record{Enriched: models.EnrichedPost{UserID: user, PostID: i}}
+			stage.Process(context.Background(), record)
+			if !record.Dropped() {
+				kept++
+				weighted += 1 / record.Enriched.SampleRate
+			}
+		}
+		return kept, weighted
+	}
+
+	// A quiet key is never sampled
+	if kept, _ := process(1, 5); kept != 5 {
+		t.Errorf("Expected all 5 records of a quiet key to be kept, got %d", kept)
+	}
+
+	// A noisy key is capped, and the weights still add up to its volume
+	process(2, 1000)
+	now = now.Add(time.Minute)
+	kept, weighted := process(2, 1000)
+	if kept > 40 {
+		t.Errorf("Expected about 10 records of a noisy key to be kept, got %d", kept)
+	}
+	if math.Abs(weighted-1000) > 500 {
+		t.Errorf("Expected re-weighted count near 1000, got %v", weighted)
+	}
+}
+
+func TestNewSamplingStageInvalid(t *testing.T) {
+	for _, config := range []SamplingConfig{
+		{Rate: 0},
+		{Rate: 1.5},
+		{Rate: 1, CapField: "userId", Cap: 0, CapWindow: time.Minute},
+		{Rate: 1, CapField: "userId", Cap: 10},
+	} {
+		if _, err := NewSamplingStage(config); err == nil {
+			t.Errorf("Expected error for %s, got nil", fmt.Sprintf("%+v", config))
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"github.com/tiwariayush700/log-ingestion-service/internal/severity"
+)
+
+// scriptWrapper adapts the process function of a script to a JSON in, JSON
+// out call so records never share state with the runtime. The result is
+// normalized to a list: undefined keeps the (mutated) record, null or false
+// drops it, an object replaces it and an array splits it.
+const scriptWrapper = `(function (process) {
+	return function (input) {
+		var record = JSON.parse(input);
+		var out = process(record);
+		if (out === undefined) out = record;
+		if (out === null || out === false) out = [];
+		if (!Array.isArray(out)) out = [out];
+		return JSON.stringify(out);
+	};
+})`
+
+// maxScriptCallStack bounds the recursion depth of scripts
+const maxScriptCallStack = 1024
+
+// ScriptStage runs a JavaScript function on every record. The script must
+// define process(record), which can mutate, replace, split or drop the
+// record. Scripts run in a sandbox without I/O, each call is interrupted
+// after a timeout, and the file is reloaded when it changes on disk.
+type ScriptStage struct {
+	path    string
+	timeout time.Duration
+	watcher *fileWatcher
+
+	mu      sync.RWMutex
+	version *scriptVersion
+}
+
+// scriptVersion is a compiled version of the script. Runtimes are not safe
+// for concurrent use, so each call takes one from a pool of runtimes
+// running the program.
+type scriptVersion struct {
+	name     string
+	program  *goja.Program
+	timeout  time.Duration
+	runtimes sync.Pool
+}
+
+// scriptRuntime is a runtime with the script loaded
+type scriptRuntime struct {
+	vm  *goja.Runtime
+	run goja.Callable
+}
+
+// NewScriptStage creates a new ScriptStage instance and compiles the script
+// at path
+func NewScriptStage(path string, timeout time.Duration) (*ScriptStage, error) {
+	if timeout <= 0 {
+		return nil, fmt.Errorf("script timeout must be positive, got %v", timeout)
+	}
+
+	s := &ScriptStage{
+		path:    path,
+		timeout: timeout,
+		watcher: newFileWatcher(path, time.Second),
+	}
+
+	if _, err := s.watcher.changed(); err != nil {
+		return nil, fmt.Errorf("failed to stat script %s: %w", path, err)
+	}
+	version, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.version = version
+
+	return s, nil
+}
+
+// Name returns the name of the stage
+func (s *ScriptStage) Name() string {
+	return "script"
+}
+
+// Process runs the script on the record. Script errors and timeouts reject
+// the record instead of failing the batch.
+func (s *ScriptStage) Process(ctx context.Context, record *Record) error {
+	s.reload()
+
+	input, err := json.Marshal(scriptRecord(record))
+	if err != nil {
+		return fmt.Errorf("failed to encode record for script: %w", err)
+	}
+
+	s.mu.RLock()
+	version := s.version
+	s.mu.RUnlock()
+
+	output, err := version.call(ctx, string(input))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		record.Count("script_error")
+		record.Reject(models.RejectedScript, err.Error())
+		return nil
+	}
+
+	outputs, err := decodeScriptOutput(output)
+	if err != nil {
+		record.Count("script_error")
+		record.Reject(models.RejectedScript, err.Error())
+		return nil
+	}
+
+	if len(outputs) == 0 {
+		record.Count("script_dropped")
+		record.Drop()
+		return nil
+	}
+
+	// Validate every output before changing the record
+	children := make([]*Record, len(outputs)-1)
+	for i, output := range outputs[1:] {
+		child := &Record{Raw: record.Raw, Enriched: record.Enriched}
+		if err := applyScriptRecord(child, output); err != nil {
+			record.Count("script_error")
+			record.Reject(models.RejectedScript, err.Error())
+			return nil
+		}
+		children[i] = child
+	}
+	if err := applyScriptRecord(record, outputs[0]); err != nil {
+		record.Count("script_error")
+		record.Reject(models.RejectedScript, err.Error())
+		return nil
+	}
+
+	for _, child := range children {
+		record.Count("script_split")
+		record.Emit(child)
+	}
+	return nil
+}
+
+// reload recompiles the script if its file changed, keeping the current
+// program if the new one does not compile
+func (s *ScriptStage) reload() {
+	changed, err := s.watcher.changed()
+	if err != nil {
+		log.Printf("Script %s unavailable, keeping previous version: %v", s.path, err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	version, err := s.load()
+	if err != nil {
+		log.Printf("Failed to reload script %s, keeping previous version: %v", s.path, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.version = version
+	s.mu.Unlock()
+	log.Printf("Reloaded script %s", s.path)
+}
+
+// load reads and compiles the script, checking that it runs and defines
+// the process function
+func (s *ScriptStage) load() (*scriptVersion, error) {
+	source, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script %s: %w", s.path, err)
+	}
+
+	program, err := goja.Compile(s.path, string(source), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile script %s: %w", s.path, err)
+	}
+
+	version := &scriptVersion{name: s.path, program: program, timeout: s.timeout}
+	runtime, err := version.newRuntime()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load script %s: %w", s.path, err)
+	}
+	version.runtimes.Put(runtime)
+
+	return version, nil
+}
+
+// newRuntime runs the program in a new runtime and binds its process
+// function
+func (v *scriptVersion) newRuntime() (*scriptRuntime, error) {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(maxScriptCallStack)
+	vm.Set("log", func(call goja.FunctionCall) goja.Value {
+		args := make([]interface{}, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			args[i] = arg.String()
+		}
+		log.Println(append([]interface{}{"[script " + v.name + "]"}, args...)...)
+		return goja.Undefined()
+	})
+
+	// Top-level code is bounded by the same timeout as calls
+	stop := watchdog(context.Background(), vm, v.timeout)
+	_, err := vm.RunProgram(v.program)
+	stop()
+	if err != nil {
+		return nil, scriptError(err)
+	}
+
+	process := vm.Get("process")
+	if _, ok := goja.AssertFunction(process); !ok {
+		return nil, fmt.Errorf("script must define a process(record) function")
+	}
+
+	wrapper, err := vm.RunString(scriptWrapper)
+	if err != nil {
+		return nil, err
+	}
+	wrap, _ := goja.AssertFunction(wrapper)
+	run, err := wrap(goja.Undefined(), process)
+	if err != nil {
+		return nil, err
+	}
+
+	call, ok := goja.AssertFunction(run)
+	if !ok {
+		return nil, fmt.Errorf("failed to bind process function")
+	}
+	return &scriptRuntime{vm: vm, run: call}, nil
+}
+
+// call runs the script on a JSON encoded record in a pooled runtime,
+// interrupting it after the timeout or when ctx is cancelled
+func (v *scriptVersion) call(ctx context.Context, input string) (string, error) {
+	runtime, ok := v.runtimes.Get().(*scriptRuntime)
+	if !ok {
+		var err error
+		if runtime, err = v.newRuntime(); err != nil {
+			return "", err
+		}
+	}
+	defer v.runtimes.Put(runtime)
+
+	stop := watchdog(ctx, runtime.vm, v.timeout)
+	output, err := runtime.run(goja.Undefined(), runtime.vm.ToValue(input))
+	stop()
+
+	if err != nil {
+		return "", scriptError(err)
+	}
+	return output.String(), nil
+}
+
+// scriptError unwraps the reason of interrupted scripts
+func scriptError(err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return fmt.Errorf("%v", interrupted.Value())
+	}
+	return err
+}
+
+// watchdog interrupts vm after the timeout or when ctx is cancelled. The
+// returned function stops it and clears any interrupt it raised, which may
+// happen right after the script returned, so it cannot hit the next call.
+func watchdog(ctx context.Context, vm *goja.Runtime, timeout time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			vm.Interrupt(fmt.Errorf("script timed out after %v", timeout))
+		case <-ctx.Done():
+			vm.Interrupt(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		vm.ClearInterrupt()
+	}
+}
+
+// scriptRecord is the record passed to scripts
+func scriptRecord(record *Record) map[string]interface{} {
+	return map[string]interface{}{
+		"userId":     record.Enriched.UserID,
+		"id":         record.Enriched.PostID,
+		"title":      record.Enriched.Title,
+		"body":       record.Enriched.Body,
+		"source":     record.Enriched.Source,
+		"event_time": record.Enriched.EventTime.Format(time.RFC3339Nano),
+		"severity":   record.Enriched.Severity,
+		"attributes": record.Enriched.Attributes,
+		"tags":       record.Enriched.Tags,
+		"fields":     record.Raw.Fields,
+	}
+}
+
+// decodeScriptOutput decodes the records returned by a script
+func decodeScriptOutput(output string) ([]map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(output)))
+	decoder.UseNumber()
+
+	var items []interface{}
+	if err := decoder.Decode(&items); err != nil {
+		return nil, fmt.Errorf("invalid script result: %w", err)
+	}
+
+	records := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("script returned a %T instead of a record", item)
+		}
+		records[i] = object
+	}
+	return records, nil
+}
+
+// applyScriptRecord replaces the fields of record with the ones returned by
+// a script. The source and event time are kept when the script removed them.
+func applyScriptRecord(record *Record, values map[string]interface{}) error {
+	var err error
+	if record.Enriched.UserID, err = scriptInt(values, "userId"); err != nil {
+		return err
+	}
+	if record.Enriched.PostID, err = scriptInt(values, "id"); err != nil {
+		return err
+	}
+	if record.Enriched.Title, err = scriptString(values, "title"); err != nil {
+		return err
+	}
+	if record.Enriched.Body, err = scriptString(values, "body"); err != nil {
+		return err
+	}
+
+	if source, err := scriptString(values, "source"); err != nil {
+		return err
+	} else if source != "" {
+		record.Enriched.Source = source
+	}
+
+	if value, err := scriptString(values, "event_time"); err != nil {
+		return err
+	} else if value != "" {
+		eventTime, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("script returned an invalid event_time: %w", err)
+		}
+		record.Enriched.EventTime = eventTime.UTC()
+	}
+
+	level, err := scriptString(values, "severity")
+	if err != nil {
+		return err
+	}
+	if level != record.Enriched.Severity {
+		record.Enriched.Severity, record.Enriched.SeverityNumber = "", 0
+		if level != "" {
+			parsed, ok := severity.Parse(level)
+			if !ok {
+				return fmt.Errorf("script returned an unknown severity %q", level)
+			}
+			record.Enriched.Severity = parsed.String()
+			record.Enriched.SeverityNumber = int(parsed)
+		}
+	}
+
+	attributes, ok := values["attributes"].(map[string]interface{})
+	if !ok && values["attributes"] != nil {
+		return fmt.Errorf("script returned attributes of type %T", values["attributes"])
+	}
+	// Numbers are decoded as json.Number and stored as native numbers
+	record.Enriched.Attributes = models.Document(attributes)
+
+	record.Enriched.Tags = nil
+	switch tags := values["tags"].(type) {
+	case nil:
+	case []interface{}:
+		for _, tag := range tags {
+			s, ok := tag.(string)
+			if !ok {
+				return fmt.Errorf("script returned a tag of type %T", tag)
+			}
+			record.Enriched.Tags = append(record.Enriched.Tags, s)
+		}
+	default:
+		return fmt.Errorf("script returned tags of type %T", values["tags"])
+	}
+
+	fields, ok := values["fields"].(map[string]interface{})
+	if !ok && values["fields"] != nil {
+		return fmt.Errorf("script returned fields of type %T", values["fields"])
+	}
+	record.Raw.Fields = fields
+	return nil
+}
+
+// scriptInt reads an integer returned by a script, zero when missing
+func scriptInt(values map[string]interface{}, name string) (int, error) {
+	switch v := values[name].(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("script returned a non-integer %s: %s", name, v)
+		}
+		return int(n), nil
+	default:
+		return 0, fmt.Errorf("script returned %s of type %T", name, v)
+	}
+}
+
+// scriptString reads a string returned by a script, empty when missing
+func scriptString(values map[string]interface{}, name string) (string, error) {
+	switch v := values[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("script returned %s of type %T", name, v)
+	}
+}
@@ -0,0 +1,189 @@
+package transformer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+func newTestScriptStage(t *testing.T, source string) (*ScriptStage, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "transform.js")
+	writeFile(t, path, source)
+
+	stage, err := NewScriptStage(path, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	return stage, path
+}
+
+func TestScriptStageMutate(t *testing.T) {
+	stage, _ := newTestScriptStage(t, `
+		function process(record) {
+			record.title = record.title.toUpperCase();
+			record.severity = record.fields.level;
+			record.attributes = {region: record.fields.region, retries: 3, ratio: 0.5};
+			record.tags = ["scripted"];
+		}
+	`)
+
+	record := &Record{
+		Raw:      models.Post{Fields: map[string]interface{}{"level": "warning", "region": "eu"}},
+		Enriched: models.EnrichedPost{PostID: 1, Title: "hello", Source: "test"},
+	}
+	if err := stage.Process(context.Background(), record); err != nil {
+		t.Fatalf("Failed to process record: %v", err)
+	}
+
+	if record.Enriched.Title != "HELLO" || record.Enriched.PostID != 1 || record.Enriched.Source != "test" {
+		t.Errorf("Expected title HELLO and unchanged id and source, got %+v", record.Enriched)
+	}
+	if record.Enriched.Severity != "warn" || record.Enriched.SeverityNumber != 13 {
+		t.Errorf("Expected severity warn (13), got %s (%d)", record.Enriched.Severity, record.Enriched.SeverityNumber)
+	}
+	if record.Enriched.Attributes["region"] != "eu" || len(record.Enriched.Tags) != 1 {
+		t.Errorf("Expected region attribute and scripted tag, got %+v", record.Enriched)
+	}
+	if record.Enriched.Attributes["retries"] != int64(3) || record.Enriched.Attributes["ratio"] != 0.5 {
+		t.Errorf("Expected numeric attributes, got %#v", record.Enriched.Attributes)
+	}
+}
+
+func TestScriptStageSplitAndDrop(t *testing.T) {
+	stage, _ := newTestScriptStage(t, `
+		function process(record) {
+			if (record.title === "noise") return null;
+			return record.body.split("\n").map(function (line, i) {
+				return Object.assign({}, record, {id: record.id * 10 + i, body: line});
+			});
+		}
+	`)
+
+	// Records emitted by the script go through the later stages
+	var seen []int
+	after := stageFunc(func(record *Record) {
+		seen = append(seen, record.Enriched.PostID)
+	})
+	transform := New("test", WithStages(stage, after))
+
+	result, err := transform.Transform(context.Background(), []models.Post{
+		{ID: 1, Title: "multi", Body: "a\nb\nc"},
+		{ID: 2, Title: "noise", Body: "x"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to transform: %v", err)
+	}
+
+	if len(result.Posts) != 3 || result.Dropped != 1 {
+		t.Fatalf("Expected 3 posts and 1 dropped, got %d and %d", len(result.Posts), result.Dropped)
+	}
+	for i, post := range result.Posts {
+		if post.PostID != 10+i || post.Body != string(rune('a'+i)) {
+			t.Errorf("Expected post %d with body %c, got %d with %q", 10+i, 'a'+i, post.PostID, post.Body)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected 3 records to reach the next stage, got %v", seen)
+	}
+	if result.Counters["script_split"] != 2 || result.Counters["script_dropped"] != 1 {
+		t.Errorf("Expected 2 splits and 1 drop, got %v", result.Counters)
+	}
+}
+
+func TestScriptStageErrors(t *testing.T) {
+	stage, _ := newTestScriptStage(t, `
+		function process(record) {
+			if (record.id === 1) throw new Error("bad record");
+			if (record.id === 2) while (true) {}
+			if (record.id === 3) return {userId: "not a number"};
+		}
+	`)
+	transform := New("test", WithStages(stage))
+
+	result, err := transform.Transform(context.Background(), []models.Post{
+		{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4},
+	})
+	if err != nil {
+		t.Fatalf("Expected script errors not to fail the batch, got %v", err)
+	}
+
+	if len(result.Posts) != 1 || result.Posts[0].PostID != 4 {
+		t.Errorf("Expected only post 4 to be ingested, got %+v", result.Posts)
+	}
+	if len(result.Rejected) != 3 || result.Dropped != 0 {
+		t.Fatalf("Expected 3 rejected and none dropped, got %d and %d", len(result.Rejected), result.Dropped)
+	}
+
+	expected := []string{"bad record", "timed out", "userId"}
+	for i, rejected := range result.Rejected {
+		if rejected.Reason != models.RejectedScript || rejected.Source != "test" {
+			t.Errorf("Expected reason script and source test, got %s and %s", rejected.Reason, rejected.Source)
+		}
+		if len(rejected.Errors) != 1 || !strings.Contains(rejected.Errors[0], expected[i]) {
+			t.Errorf("Expected error containing %q, got %v", expected[i], rejected.Errors)
+		}
+	}
+}
+
+func TestScriptStageCancelled(t *testing.T) {
+	stage, _ := newTestScriptStage(t, `function process(record) { while (true) {} }`)
+	stage.timeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := stage.Process(ctx, &Record{}); err == nil {
+		t.Error("Expected cancellation to fail the record, got nil")
+	}
+}
+
+func TestScriptStageReload(t *testing.T) {
+	stage, path := newTestScriptStage(t, `function process(record) { record.title = "v1"; }`)
+	stage.watcher.interval = 0
+
+	writeFile(t, path, `function process(record) { record.title = "v2"; }`)
+	// Make sure the modification time moves even on coarse filesystems
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+
+	record := &Record{}
+	stage.Process(context.Background(), record)
+	if record.Enriched.Title != "v2" {
+		t.Errorf("Expected reloaded script to set v2, got %s", record.Enriched.Title)
+	}
+
+	// A broken script keeps the previous version
+	writeFile(t, path, `function process(record) {`)
+	os.Chtimes(path, future.Add(time.Minute), future.Add(time.Minute))
+
+	record = &Record{}
+	stage.Process(context.Background(), record)
+	if record.Enriched.Title != "v2" {
+		t.Errorf("Expected previous script to be kept, got %s", record.Enriched.Title)
+	}
+}
+
+func TestNewScriptStageInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, source := range map[string]string{
+		"syntax.js":  `function process(record) {`,
+		"missing.js": `function transform(record) {}`,
+		"loop.js":    `while (true) {}`,
+	} {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, source)
+		if _, err := NewScriptStage(path, 50*time.Millisecond); err == nil {
+			t.Errorf("Expected error for %s, got nil", name)
+		}
+	}
+
+	if _, err := NewScriptStage(filepath.Join(dir, "absent.js"), time.Second); err == nil {
+		t.Error("Expected error for a missing script, got nil")
+	}
+}
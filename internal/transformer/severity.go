@@ -0,0 +1,101 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/severity"
+)
+
+// DefaultSeverityFields are the payload fields checked for a level when no
+// fields are configured
+var DefaultSeverityFields = []string{"level", "severity", "lvl", "log_level", "loglevel"}
+
+// severityInText matches level=xxx pairs and upper case level keywords
+var severityInText = regexp.MustCompile(`(?i:\blevel\s*[=:]\s*"?([a-z]+))|\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|ERR|CRIT|CRITICAL|ALERT|EMERG|FATAL|PANIC)\b`)
+
+// SeverityStage detects the level of a record and normalizes it
+type SeverityStage struct {
+	fields       []string
+	scheme       severity.Scheme
+	defaultLevel severity.Level
+}
+
+// NewSeverityStage creates a new SeverityStage instance. Numeric levels are
+// interpreted with scheme and records without a detectable level get
+// defaultLevel, which may be severity.Unspecified.
+func NewSeverityStage(fields []string, scheme severity.Scheme, defaultLevel severity.Level) *SeverityStage {
+	if len(fields) == 0 {
+		fields = DefaultSeverityFields
+	}
+	if scheme == "" {
+		scheme = severity.SchemeSyslog
+	}
+
+	return &SeverityStage{
+		fields:       fields,
+		scheme:       scheme,
+		defaultLevel: defaultLevel,
+	}
+}
+
+// Name returns the name of the stage
+func (s *SeverityStage) Name() string {
+	return "severity"
+}
+
+// Process sets the severity of the record
+func (s *SeverityStage) Process(ctx context.Context, record *Record) error {
+	level := s.detect(record)
+	if level == severity.Unspecified {
+		level = s.defaultLevel
+	}
+
+	record.Enriched.Severity = level.String()
+	record.Enriched.SeverityNumber = int(level)
+	return nil
+}
+
+// detect looks for a level in the configured fields, then in the title and body
+func (s *SeverityStage) detect(record *Record) severity.Level {
+	for _, name := range s.fields {
+		value, ok := lookupField(record.Raw.Fields, name)
+		if !ok {
+			continue
+		}
+		if level, ok := s.parse(value); ok {
+			return level
+		}
+	}
+
+	for _, text := range []string{record.Enriched.Title, record.Enriched.Body} {
+		match := severityInText.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		name := match[1]
+		if name == "" {
+			name = match[2]
+		}
+		if level, ok := severity.Parse(name); ok {
+			return level
+		}
+	}
+
+	return severity.Unspecified
+}
+
+// parse converts a single field value into a level
+func (s *SeverityStage) parse(value interface{}) (severity.Level, bool) {
+	switch v := value.(type) {
+	case string:
+		return severity.ParseValue(v, s.scheme)
+	case json.Number:
+		return severity.ParseValue(v.String(), s.scheme)
+	case float64, int, int64:
+		return severity.ParseValue(fmt.Sprint(v), s.scheme)
+	}
+	return severity.Unspecified, false
+}
@@ -0,0 +1,86 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+	"github.com/tiwariayush700/log-ingestion-service/internal/severity"
+)
+
+func TestSeverityStage(t *testing.T) {
+	stage := NewSeverityStage(nil, severity.SchemeSyslog, severity.Unspecified)
+
+	tests := []struct {
+		name   string
+		record Record
+		want   severity.Level
+	}{
+		{
+			name:   "abbreviated name",
+			record: Record{Raw: models.Post{Fields: map[string]interface{}{"level": "ERR"}}},
+			want:   severity.Error,
+		},
+		{
+			name:   "lowercase name",
+			record: Record{Raw: models.Post{Fields: map[string]interface{}{"severity": "error"}}},
+			want:   severity.Error,
+		},
+		{
+			name:   "syslog number",
+			record: Record{Raw: models.Post{Fields: map[string]interface{}{"level": json.Number("3")}}},
+			want:   severity.Error,
+		},
+		{
+			name:   "fatal",
+			record: Record{Raw: models.Post{Fields: map[string]interface{}{"lvl": "fatal"}}},
+			want:   severity.Fatal,
+		},
+		{
+			name:   "keyword in body",
+			record: Record{Enriched: models.EnrichedPost{Body: "2024-01-01 WARN disk almost full"}},
+			want:   severity.Warn,
+		},
+		{
+			name:   "key value pair in body",
+			record: Record{Enriched: models.EnrichedPost{Body: `ts=1 level=debug msg="cache miss"`}},
+			want:   severity.Debug,
+		},
+		{
+			name:   "no level",
+			record: Record{Enriched: models.EnrichedPost{Body: "an error in lowercase prose is not a level"}},
+			want:   severity.Unspecified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := tt.record
+			if err := stage.Process(context.Background(), &record); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if record.Enriched.SeverityNumber != int(tt.want) {
+				t.Errorf("Expected severity number %d, got %d", tt.want, record.Enriched.SeverityNumber)
+			}
+
+			if record.Enriched.Severity != tt.want.String() {
+				t.Errorf("Expected severity '%s', got '%s'", tt.want, record.Enriched.Severity)
+			}
+		})
+	}
+}
+
+func TestSeverityStageDefault(t *testing.T) {
+	stage := NewSeverityStage(nil, severity.SchemeSyslog, severity.Info)
+
+	record := Record{Enriched: models.EnrichedPost{Body: "nothing to see"}}
+	if err := stage.Process(context.Background(), &record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if record.Enriched.Severity != "info" {
+		t.Errorf("Expected default severity 'info', got '%s'", record.Enriched.Severity)
+	}
+}
@@ -0,0 +1,209 @@
+package transformer
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Stage is a single step of the transform pipeline, applied to every record
+// after the base fields have been mapped
+type Stage interface {
+	Name() string
+	Process(ctx context.Context, record *Record) error
+}
+
+// Flusher is implemented by stages that buffer state and need to persist it
+// once the records of a batch are stored
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Committer is implemented by stages that remember the records of a batch,
+// such as dedup, from the values they kept for the records that were output. The batch is committed once its records are stored, so
+// records lost before then, to a failed write or an interrupted run, are
+// not remembered when they go through again.
+type Committer interface {
+	Commit(ctx context.Context, batch *Batch) error
+}
+
+// Reloader is implemented by stages that keep a copy of persisted state,
+// so it can be refreshed when the source was run by another replica
+type Reloader interface {
+	Reload(ctx context.Context) error
+}
+
+// Batch holds the keys stages claim for the records transformed together,
+// and the values stages keep for the records that leave the pipeline, until
+// the batch is committed
+type Batch struct {
+	mu sync.Mutex
+	// keys counts the claims of each key, by stage
+	keys map[string]map[string]int
+	// kept holds the values kept for the output records, by stage
+	kept map[string][]interface{}
+}
+
+func newBatch() *Batch {
+	return &Batch{
+		keys: make(map[string]map[string]int),
+		kept: make(map[string][]interface{}),
+	}
+}
+
+// Claim adds a key to the batch for a stage and reports whether the batch
+// already held it
+func (b *Batch) Claim(stage, key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keys, ok := b.keys[stage]
+	if !ok {
+		keys = make(map[string]int)
+		b.keys[stage] = keys
+	}
+	keys[key]++
+	return keys[key] > 1
+}
+
+// Kept returns the values stages kept for the records output by the
+// pipeline, in the order the records were output
+func (b *Batch) Kept(stage string) []interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.kept[stage]
+}
+
+// keep adds the values kept for an output record
+func (b *Batch) keep(values []keptValue) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, v := range values {
+		b.kept[v.stage] = append(b.kept[v.stage], v.value)
+	}
+}
+
+// keptValue is a value a stage keeps for a record
+type keptValue struct {
+	stage string
+	value interface{}
+}
+
+// Record is a post moving through the transform pipeline
+type Record struct {
+	Raw      models.Post
+	Enriched models.EnrichedPost
+
+	dropped  bool
+	rejected *models.RejectedRecord
+	emitted  []*Record
+	counters map[string]int
+	batch    *Batch
+	kept     []keptValue
+}
+
+// Drop removes the record from the batch. Later stages are skipped.
+func (r *Record) Drop() {
+	r.dropped = true
+}
+
+// Dropped reports whether a stage dropped the record
+func (r *Record) Dropped() bool {
+	return r.dropped
+}
+
+// Reject drops the record and sends it to the rejected store with the
+// reason and errors
+func (r *Record) Reject(reason string, errs ...string) {
+	r.dropped = true
+	r.rejected = &models.RejectedRecord{
+		Source:  r.Enriched.Source,
+		Reason:  reason,
+		Payload: models.Document(r.Raw.Fields),
+		Errors:  errs,
+	}
+}
+
+// Emit adds a record to the batch, e.g. when a stage splits a record in
+// several. Emitted records go through the remaining stages.
+func (r *Record) Emit(record *Record) {
+	r.emitted = append(r.emitted, record)
+}
+
+// Keep holds a value for the stage to commit with the batch, e.g. the
+// template counts of the record. It is only added to the batch if the
+// record is output, so the values of records that later stages drop or
+// reject are not committed.
+func (r *Record) Keep(stage string, value interface{}) {
+	r.kept = append(r.kept, keptValue{stage: stage, value: value})
+}
+
+// Count increments a named counter, summed over the batch into Result.Counters
+func (r *Record) Count(name string) {
+	if r.counters == nil {
+		r.counters = make(map[string]int)
+	}
+	r.counters[name]++
+}
+
+// Field returns the value of a record field, looking at the enriched fields
+// first and the raw payload second
+func (r *Record) Field(name string) (interface{}, bool) {
+	switch name {
+	case "userId":
+		return r.Enriched.UserID, true
+	case "id", "postId":
+		return r.Enriched.PostID, true
+	case "title":
+		return r.Enriched.Title, true
+	case "body":
+		return r.Enriched.Body, true
+	case "source":
+		return r.Enriched.Source, true
+	case "severity":
+		if r.Enriched.Severity != "" {
+			return r.Enriched.Severity, true
+		}
+	case "severity_number":
+		return r.Enriched.SeverityNumber, true
+	case "fingerprint":
+		return r.Enriched.Fingerprint, true
+	case "tags":
+		return r.Enriched.Tags, true
+	case "template_id":
+		return r.Enriched.TemplateID, true
+	}
+
+	if attribute, ok := strings.CutPrefix(name, "attributes."); ok {
+		value, ok := r.Enriched.Attributes[attribute]
+		return value, ok
+	}
+	if field, ok := strings.CutPrefix(name, "geo."); ok {
+		return r.geoField(field)
+	}
+	return lookupField(r.Raw.Fields, name)
+}
+
+// geoField returns a field of the resolved location
+func (r *Record) geoField(name string) (interface{}, bool) {
+	geo := r.Enriched.Geo
+	if geo == nil {
+		return nil, false
+	}
+
+	switch name {
+	case "country_code":
+		return geo.CountryCode, true
+	case "country":
+		return geo.Country, true
+	case "city":
+		return geo.City, true
+	case "asn":
+		return geo.ASN, true
+	case "as_organization":
+		return geo.ASOrganization, true
+	}
+	return nil, false
+}
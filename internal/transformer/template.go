@@ -0,0 +1,168 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/drain"
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// TemplateStore persists mined templates
+type TemplateStore interface {
+	LoadTemplates(ctx context.Context, source string) ([]models.LogTemplate, error)
+	// SaveTemplates adds the counts of the given templates to the stored
+	// ones, updating their template text and first and last seen times
+	SaveTemplates(ctx context.Context, templates []models.LogTemplate) error
+}
+
+// TemplateStage mines message templates with Drain, tagging each record
+// with the ID of its template and the values of the variable parts. Counts
+// are kept on the batch, so only the records that are stored are counted,
+// and written to the store once the batch is committed.
+type TemplateStage struct {
+	field  string
+	source string
+	config drain.Config
+	store  TemplateStore
+
+	mu      sync.Mutex
+	miner   *drain.Miner
+	pending map[string]*models.LogTemplate
+}
+
+// NewTemplateStage creates a new TemplateStage instance mining the given
+// field, restoring the templates previously mined for the source
+func NewTemplateStage(ctx context.Context, source, field string, config drain.Config, store TemplateStore) (*TemplateStage, error) {
+	s := &TemplateStage{
+		field:   field,
+		source:  source,
+		config:  config,
+		store:   store,
+		pending: make(map[string]*models.LogTemplate),
+	}
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload rebuilds the miner from the stored templates, which include the
+// ones mined by the replica that ran the source before. Templates not saved
+// yet are kept.
+func (s *TemplateStage) Reload(ctx context.Context) error {
+	templates, err := s.store.LoadTemplates(ctx, s.source)
+	if err != nil {
+		return fmt.Errorf("failed to load templates: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A pending template holds the latest text of its cluster
+	miner := drain.New(s.config, s.source)
+	for _, template := range templates {
+		if _, ok := s.pending[template.ID]; !ok {
+			miner.Restore(template.ID, template.Template)
+		}
+	}
+	for _, template := range s.pending {
+		miner.Restore(template.ID, template.Template)
+	}
+	s.miner = miner
+	return nil
+}
+
+// Name returns the name of the stage
+func (s *TemplateStage) Name() string {
+	return "template"
+}
+
+// Process assigns the record to a template
+func (s *TemplateStage) Process(ctx context.Context, record *Record) error {
+	value, ok := record.Field(s.field)
+	if !ok || value == nil {
+		return nil
+	}
+	message, ok := value.(string)
+	if !ok {
+		message = fmt.Sprint(value)
+	}
+
+	s.mu.Lock()
+	miner := s.miner
+	s.mu.Unlock()
+
+	cluster, params, _ := miner.Match(message)
+	record.Enriched.TemplateID = cluster.ID
+	record.Enriched.TemplateParams = params
+
+	seen := record.Enriched.IngestedAt
+	record.Keep(s.Name(), models.LogTemplate{
+		ID:        cluster.ID,
+		Source:    s.source,
+		Template:  cluster.Template(),
+		Count:     1,
+		FirstSeen: seen,
+		LastSeen:  seen,
+	})
+	return nil
+}
+
+// Commit adds the counts of the stored records of a batch to the pending
+// templates, written on the next flush
+func (s *TemplateStage) Commit(ctx context.Context, batch *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, value := range batch.Kept(s.Name()) {
+		template := value.(models.LogTemplate)
+		s.merge(&template)
+		// The template may have been generalized by later records
+		s.pending[template.ID].Template = template.Template
+	}
+	return nil
+}
+
+// Flush writes the templates seen since the last flush
+func (s *TemplateStage) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	templates := make([]models.LogTemplate, 0, len(s.pending))
+	for _, template := range s.pending {
+		templates = append(templates, *template)
+	}
+	s.pending = make(map[string]*models.LogTemplate)
+	s.mu.Unlock()
+
+	if err := s.store.SaveTemplates(ctx, templates); err != nil {
+		// Keep the counts for the next flush
+		s.mu.Lock()
+		for i := range templates {
+			s.merge(&templates[i])
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// merge adds unsaved counts back to the pending templates
+func (s *TemplateStage) merge(template *models.LogTemplate) {
+	pending, ok := s.pending[template.ID]
+	if !ok {
+		s.pending[template.ID] = template
+		return
+	}
+	pending.Count += template.Count
+	if template.FirstSeen.Before(pending.FirstSeen) {
+		pending.FirstSeen = template.FirstSeen
+	}
+	if template.LastSeen.After(pending.LastSeen) {
+		pending.LastSeen = template.LastSeen
+	}
+}
@@ -0,0 +1,175 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/drain"
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// memoryTemplateStore keeps templates in memory
+type memoryTemplateStore struct {
+	templates map[string]models.LogTemplate
+	err       error
+}
+
+func (m *memoryTemplateStore) LoadTemplates(ctx context.Context, source string) ([]models.LogTemplate, error) {
+	var templates []models.LogTemplate
+	for _, template := range m.templates {
+		if template.Source == source {
+			templates = append(templates, template)
+		}
+	}
+	return templates, nil
+}
+
+func (m *memoryTemplateStore) SaveTemplates(ctx context.Context, templates []models.LogTemplate) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, template := range templates {
+		stored, ok := m.templates[template.ID]
+		if ok {
+			template.Count += stored.Count
+			template.FirstSeen = stored.FirstSeen
+		}
+		m.templates[template.ID] = template
+	}
+	return nil
+}
+
+func TestTemplateStage(t *testing.T) {
+	store := &memoryTemplateStore{templates: make(map[string]models.LogTemplate)}
+	stage, err := NewTemplateStage(context.Background(), "test", "body", drain.DefaultConfig(), store)
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	transform := New("test", WithStages(stage))
+
+	result, err := transform.Transform(context.Background(), []models.Post{
+		{ID: 1, Body: "user 1 logged in"},
+		{ID: 2, Body: "user 2 logged in"},
+		{ID: 3, Body: "disk /dev/sda is full"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to transform: %v", err)
+	}
+	if err := transform.Commit(context.Background(), result); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	login, disk := result.Posts[1], result.Posts[2]
+	if login.TemplateID == "" || login.TemplateID != result.Posts[0].TemplateID || login.TemplateID == disk.TemplateID {
+		t.Errorf("Expected logins to share a template distinct from disk, got %+v", result.Posts)
+	}
+	if !reflect.DeepEqual(login.TemplateParams, []string{"2"}) {
+		t.Errorf("Expected params [2], got %v", login.TemplateParams)
+	}
+
+	// Templates are saved with their counts on commit
+	saved := store.templates[login.TemplateID]
+	if saved.Count != 2 || saved.Template != "user <*> logged in" || saved.Source != "test" {
+		t.Errorf("Expected 2 logins under 'user <*> logged in', got %+v", saved)
+	}
+
+	// A new stage resumes from the stored templates
+	stage, err = NewTemplateStage(context.Background(), "test", "body", drain.DefaultConfig(), store)
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	transform = New("test", WithStages(stage))
+	result, _ = transform.Transform(context.Background(), []models.Post{{ID: 4, Body: "user 3 logged in"}})
+	transform.Commit(context.Background(), result)
+	if result.Posts[0].TemplateID != login.TemplateID {
+		t.Errorf("Expected the restored template %s, got %s", login.TemplateID, result.Posts[0].TemplateID)
+	}
+	if store.templates[login.TemplateID].Count != 3 {
+		t.Errorf("Expected count 3, got %d", store.templates[login.TemplateID].Count)
+	}
+}
+
+func TestTemplateStageFlushError(t *testing.T) {
+	store := &memoryTemplateStore{templates: make(map[string]models.LogTemplate), err: errors.New("unavailable")}
+	stage, err := NewTemplateStage(context.Background(), "test", "body", drain.DefaultConfig(), store)
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	transform := New("test", WithStages(stage))
+
+	result, _ := transform.Transform(context.Background(), []models.Post{{ID: 1, Body: "job 1 done"}})
+	if err := transform.Commit(context.Background(), result); err == nil {
+		t.Fatal("Expected flush error, got nil")
+	}
+
+	// Unsaved counts are kept for the next flush
+	store.err = nil
+	next, _ := transform.Transform(context.Background(), []models.Post{{ID: 2, Body: "job 2 done"}})
+	if err := transform.Commit(context.Background(), next); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if count := store.templates[result.Posts[0].TemplateID].Count; count != 2 {
+		t.Errorf("Expected count 2, got %d", count)
+	}
+}
+
+func TestTemplateStageCommit(t *testing.T) {
+	store := &memoryTemplateStore{templates: make(map[string]models.LogTemplate)}
+	stage, err := NewTemplateStage(context.Background(), "test", "body", drain.DefaultConfig(), store)
+	if err != nil {
+		t.Fatalf("Failed to create stage: %v", err)
+	}
+	// A later stage drops the second login
+	dropTwo := stageFunc(func(record *Record) {
+		if record.Raw.ID == 2 {
+			record.Drop()
+		}
+	})
+	transform := New("test", WithStages(stage, dropTwo))
+	posts := []models.Post{{ID: 1, Body: "user 1 logged in"}, {ID: 2, Body: "user 2 logged in"}}
+
+	// A batch that failed to be stored is not committed, and counts nothing
+	if _, err := transform.Transform(context.Background(), posts); err != nil {
+		t.Fatalf("Failed to transform: %v", err)
+	}
+	if len(store.templates) != 0 {
+		t.Fatalf("Expected no template before commit, got %+v", store.templates)
+	}
+
+	// Retried and stored, the batch counts the records that were output
+	result, _ := transform.Transform(context.Background(), posts)
+	if err := transform.Commit(context.Background(), result); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if count := store.templates[result.Posts[0].TemplateID].Count; count != 1 {
+		t.Errorf("Expected the stored login alone to be counted, got %d", count)
+	}
+}
+
+func TestTemplateStageReload(t *testing.T) {
+	store := &memoryTemplateStore{templates: make(map[string]models.LogTemplate)}
+	first, _ := NewTemplateStage(context.Background(), "test", "body", drain.DefaultConfig(), store)
+	second, _ := NewTemplateStage(context.Background(), "test", "body", drain.DefaultConfig(), store)
+
+	// The first replica mines and saves a template
+	transform := New("test", WithStages(first))
+	result, _ := transform.Transform(context.Background(), []models.Post{
+		{ID: 1, Body: "user 1 logged in"},
+		{ID: 2, Body: "user 2 logged in"},
+	})
+	if err := transform.Commit(context.Background(), result); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	// The replica taking over the source matches it once reloaded
+	if err := second.Reload(context.Background()); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	record := &Record{Enriched: models.EnrichedPost{Body: "user 3 logged in"}}
+	second.Process(context.Background(), record)
+	if !reflect.DeepEqual(record.Enriched.TemplateParams, []string{"3"}) {
+		t.Errorf("Expected the reloaded template to match with params [3], got %v", record.Enriched.TemplateParams)
+	}
+}
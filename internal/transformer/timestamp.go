@@ -0,0 +1,159 @@
+package transformer
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimestampFields are the payload fields checked for an event time
+// when no fields are configured
+var DefaultTimestampFields = []string{"timestamp", "@timestamp", "time", "ts", "created_at", "date"}
+
+// DefaultTimestampLayouts are the layouts tried before any custom layouts
+var DefaultTimestampLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// TimestampExtractor extracts the event time from a raw payload
+type TimestampExtractor struct {
+	fields   []string
+	layouts  []string
+	location *time.Location
+}
+
+// NewTimestampExtractor creates a new TimestampExtractor instance. Custom
+// layouts are tried after the default ones and timestamps without a zone
+// are interpreted in location.
+func NewTimestampExtractor(fields, layouts []string, location *time.Location) *TimestampExtractor {
+	if len(fields) == 0 {
+		fields = DefaultTimestampFields
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &TimestampExtractor{
+		fields:   fields,
+		layouts:  append(append([]string{}, DefaultTimestampLayouts...), layouts...),
+		location: location,
+	}
+}
+
+// Extract returns the first candidate field that parses as a timestamp
+func (e *TimestampExtractor) Extract(fields map[string]interface{}) (time.Time, bool) {
+	for _, name := range e.fields {
+		value, ok := lookupField(fields, name)
+		if !ok {
+			continue
+		}
+		if ts, ok := e.parse(value); ok {
+			return ts.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parse converts a single field value into a timestamp
+func (e *TimestampExtractor) parse(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return parseEpoch(string(v))
+	case float64:
+		return epochToTime(v)
+	case int:
+		return epochToTime(float64(v))
+	case int64:
+		return epochToTime(float64(v))
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return time.Time{}, false
+		}
+		for _, layout := range e.layouts {
+			if ts, err := time.ParseInLocation(layout, s, e.location); err == nil {
+				return ts, true
+			}
+		}
+		return parseEpoch(s)
+	}
+	return time.Time{}, false
+}
+
+// parseEpoch parses a numeric string as an epoch timestamp. Integers are
+// parsed without going through float64 so nanosecond values keep their
+// precision.
+func parseEpoch(s string) (time.Time, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return intEpochToTime(n), true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return epochToTime(f)
+	}
+	return time.Time{}, false
+}
+
+// intEpochToTime guesses the unit of an integer epoch from its magnitude
+func intEpochToTime(n int64) time.Time {
+	abs := n
+	if abs < 0 {
+		abs = -abs
+	}
+
+	switch {
+	case abs < 1e11:
+		return time.Unix(n, 0)
+	case abs < 1e14:
+		return time.UnixMilli(n)
+	case abs < 1e17:
+		return time.UnixMicro(n)
+	default:
+		return time.Unix(0, n)
+	}
+}
+
+// epochToTime converts a possibly fractional epoch into a timestamp
+func epochToTime(f float64) (time.Time, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, false
+	}
+	if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+		return intEpochToTime(int64(f)), true
+	}
+
+	sec, frac := math.Modf(f)
+	if math.Abs(f) >= 1e11 {
+		// Fractional milliseconds or finer are not worth guessing
+		return intEpochToTime(int64(f)), true
+	}
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+// lookupField resolves a dotted path such as "meta.created_at" in a payload
+func lookupField(fields map[string]interface{}, path string) (interface{}, bool) {
+	if fields == nil {
+		return nil, false
+	}
+	if value, ok := fields[path]; ok {
+		return value, true
+	}
+
+	parts := strings.Split(path, ".")
+	var current interface{} = fields
+	for _, part := range parts {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
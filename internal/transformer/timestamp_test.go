@@ -0,0 +1,118 @@
+package transformer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampExtractorExtract(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("Timezone database not available: %v", err)
+	}
+
+	expected := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		extractor *TimestampExtractor
+		fields    map[string]interface{}
+		want      time.Time
+	}{
+		{
+			name:      "RFC3339",
+			extractor: NewTimestampExtractor(nil, nil, nil),
+			fields:    map[string]interface{}{"timestamp": "2024-03-15T10:30:00Z"},
+			want:      expected,
+		},
+		{
+			name:      "RFC3339 with offset",
+			extractor: NewTimestampExtractor(nil, nil, nil),
+			fields:    map[string]interface{}{"time": "2024-03-15T16:00:00+05:30"},
+			want:      expected,
+		},
+		{
+			name:      "epoch seconds",
+			extractor: NewTimestampExtractor(nil, nil, nil),
+			fields:    map[string]interface{}{"ts": json.Number("1710498600")},
+			want:      expected,
+		},
+		{
+			name:      "epoch milliseconds",
+			extractor: NewTimestampExtractor(nil, nil, nil),
+			fields:    map[string]interface{}{"ts": json.Number("1710498600000")},
+			want:      expected,
+		},
+		{
+			name:      "epoch nanoseconds",
+			extractor: NewTimestampExtractor(nil, nil, nil),
+			fields:    map[string]interface{}{"ts": json.Number("1710498600000000123")},
+			want:      expected.Add(123 * time.Nanosecond),
+		},
+		{
+			name:      "fractional epoch seconds",
+			extractor: NewTimestampExtractor(nil, nil, nil),
+			fields:    map[string]interface{}{"ts": json.Number("1710498600.5")},
+			want:      expected.Add(500 * time.Millisecond),
+		},
+		{
+			name:      "epoch as string",
+			extractor: NewTimestampExtractor(nil, nil, nil),
+			fields:    map[string]interface{}{"ts": "1710498600"},
+			want:      expected,
+		},
+		{
+			name:      "custom layout in source timezone",
+			extractor: NewTimestampExtractor([]string{"logged"}, []string{"02/01/2006 15:04"}, kolkata),
+			fields:    map[string]interface{}{"logged": "15/03/2024 16:00"},
+			want:      expected,
+		},
+		{
+			name:      "nested field",
+			extractor: NewTimestampExtractor([]string{"meta.created_at"}, nil, nil),
+			fields: map[string]interface{}{
+				"meta": map[string]interface{}{"created_at": "2024-03-15T10:30:00Z"},
+			},
+			want: expected,
+		},
+		{
+			name:      "first parsable candidate wins",
+			extractor: NewTimestampExtractor([]string{"bad", "good"}, nil, nil),
+			fields:    map[string]interface{}{"bad": "not a time", "good": "2024-03-15T10:30:00Z"},
+			want:      expected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.extractor.Extract(tt.fields)
+			if !ok {
+				t.Fatal("Expected a timestamp to be extracted")
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+
+			if got.Location() != time.UTC {
+				t.Errorf("Expected UTC timestamp, got %v", got.Location())
+			}
+		})
+	}
+}
+
+func TestTimestampExtractorNoMatch(t *testing.T) {
+	extractor := NewTimestampExtractor(nil, nil, nil)
+
+	for _, fields := range []map[string]interface{}{
+		nil,
+		{"title": "no timestamp here"},
+		{"timestamp": "garbage"},
+		{"timestamp": true},
+	} {
+		if ts, ok := extractor.Extract(fields); ok {
+			t.Errorf("Expected no timestamp for %v, got %v", fields, ts)
+		}
+	}
+}
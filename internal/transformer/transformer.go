@@ -1,6 +1,10 @@
 package transformer
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/tiwariayush700/log-ingestion-service/internal/models"
@@ -9,30 +13,457 @@ import (
 // Transformer is responsible for transforming data
 type Transformer struct {
 	sourceName string
+	timestamps *TimestampExtractor
+	stages     []Stage
+
+	// workers run the stages concurrently, fed through channels holding up
+	// to queueSize records; ordered keeps the output in input order
+	workers   int
+	queueSize int
+	ordered   bool
+
+	// version tags the records with the pipeline that transformed them;
+	// keepRaw stores the compressed upstream record alongside
+	version string
+	keepRaw bool
+}
+
+// Option configures a Transformer
+type Option func(*Transformer)
+
+// WithTimestampExtractor sets the extractor used to find the event time
+func WithTimestampExtractor(extractor *TimestampExtractor) Option {
+	return func(t *Transformer) {
+		t.timestamps = extractor
+	}
+}
+
+// WithStages appends stages to the pipeline, run in the given order
+func WithStages(stages ...Stage) Option {
+	return func(t *Transformer) {
+		t.stages = append(t.stages, stages...)
+	}
+}
+
+// WithWorkers runs the stages over a pool of workers fed through bounded
+// channels of queueSize records, twice the number of workers when zero.
+// Stages must be safe for concurrent use.
+func WithWorkers(workers, queueSize int) Option {
+	return func(t *Transformer) {
+		t.workers = workers
+		t.queueSize = queueSize
+	}
+}
+
+// WithPreserveOrder sets whether the output keeps the order of the input
+// when running over several workers. Records are otherwise output as soon
+// as they are processed.
+func WithPreserveOrder(ordered bool) Option {
+	return func(t *Transformer) {
+		t.ordered = ordered
+	}
+}
+
+// WithVersion tags the transformed records with the version of the pipeline
+func WithVersion(version string) Option {
+	return func(t *Transformer) {
+		t.version = version
+	}
+}
+
+// WithRawPayload sets whether the upstream record is kept, compressed, on
+// each transformed record so it can be reprocessed later
+func WithRawPayload(keep bool) Option {
+	return func(t *Transformer) {
+		t.keepRaw = keep
+	}
 }
 
 // New creates a new Transformer instance
-func New(sourceName string) *Transformer {
-	return &Transformer{
+func New(sourceName string, opts ...Option) *Transformer {
+	t := &Transformer{
 		sourceName: sourceName,
+		timestamps: NewTimestampExtractor(nil, nil, time.UTC),
+		workers:    1,
+		ordered:    true,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.workers < 1 {
+		t.workers = 1
+	}
+	if t.queueSize < 1 {
+		t.queueSize = 2 * t.workers
+	}
+
+	return t
+}
+
+// Version returns the version records are tagged with
+func (t *Transformer) Version() string {
+	return t.version
+}
+
+// Result holds the outcome of transforming a batch of posts
+type Result struct {
+	Posts []models.EnrichedPost
+	// Rejected holds the records stages sent to the rejected store; they are
+	// not counted as dropped
+	Rejected   []models.RejectedRecord
+	Dropped    int
+	Duplicates int
+	Counters   map[string]int
+	// Stages holds the time spent in each stage
+	Stages map[string]StageTiming
+
+	batch *Batch
+}
+
+// StageTiming is the time spent in a stage over a batch
+type StageTiming struct {
+	Records int
+	Total   time.Duration
+	Max     time.Duration
+}
+
+// add records the time spent on one record
+func (s *StageTiming) add(d time.Duration) {
+	s.Records++
+	s.Total += d
+	if d > s.Max {
+		s.Max = d
+	}
+}
+
+// Latency converts the timing for the run status
+func (s StageTiming) Latency() models.StageLatency {
+	latency := models.StageLatency{
+		Records: s.Records,
+		TotalMs: milliseconds(s.Total),
+		MaxMs:   milliseconds(s.Max),
+	}
+	if s.Records > 0 {
+		latency.MeanMs = milliseconds(s.Total / time.Duration(s.Records))
+	}
+	return latency
+}
+
+// StageLatency returns the latency of each stage for the run status
+func (r Result) StageLatency() map[string]models.StageLatency {
+	latency := make(map[string]models.StageLatency, len(r.Stages))
+	for name, timing := range r.Stages {
+		latency[name] = timing.Latency()
+	}
+	return latency
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func newResult(size int) Result {
+	return Result{
+		Posts:    make([]models.EnrichedPost, 0, size),
+		Counters: make(map[string]int),
+		Stages:   make(map[string]StageTiming),
+	}
+}
+
+// merge adds the outcome of part of the batch
+func (r *Result) merge(other Result) {
+	r.Posts = append(r.Posts, other.Posts...)
+	r.Rejected = append(r.Rejected, other.Rejected...)
+	r.Dropped += other.Dropped
+	r.Duplicates += other.Duplicates
+	for name, n := range other.Counters {
+		r.Counters[name] += n
+	}
+	for name, timing := range other.Stages {
+		total := r.Stages[name]
+		total.Records += timing.Records
+		total.Total += timing.Total
+		if timing.Max > total.Max {
+			total.Max = timing.Max
+		}
+		r.Stages[name] = total
+	}
+}
+
+// process runs a record through the stages starting at the given index
+// and adds it to the result, followed by the records it emitted
+func (t *Transformer) process(ctx context.Context, record *Record, first int, result *Result, now time.Time) error {
+	type emission struct {
+		record *Record
+		next   int
+	}
+	var emitted []emission
+
+	for i := first; i < len(t.stages); i++ {
+		stage := t.stages[i]
+		start := time.Now()
+		err := stage.Process(ctx, record)
+		timing := result.Stages[stage.Name()]
+		timing.add(time.Since(start))
+		result.Stages[stage.Name()] = timing
+		if err != nil {
+			return fmt.Errorf("stage %s failed for post %d: %w", stage.Name(), record.Raw.ID, err)
+		}
+
+		// Records emitted by this stage continue from the next one
+		for _, child := range record.emitted {
+			child.batch = record.batch
+			emitted = append(emitted, emission{record: child, next: i + 1})
+		}
+		record.emitted = nil
+
+		if record.Dropped() {
+			break
+		}
+	}
+
+	for name, n := range record.counters {
+		result.Counters[name] += n
+	}
+	if record.Enriched.Duplicate {
+		result.Duplicates++
+	}
+	switch {
+	case record.rejected != nil:
+		record.rejected.RejectedAt = now
+		result.Rejected = append(result.Rejected, *record.rejected)
+	case record.Dropped():
+		result.Dropped++
+	default:
+		result.Posts = append(result.Posts, record.Enriched)
+		if record.batch != nil {
+			record.batch.keep(record.kept)
+		}
 	}
+
+	for _, e := range emitted {
+		if err := t.process(ctx, e.record, e.next, result, now); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// TransformPosts transforms posts by adding metadata
+// TransformPosts transforms posts by adding metadata and commits them right
+// away. Stage errors are logged; use Transform to handle them.
 func (t *Transformer) TransformPosts(posts []models.Post) []models.EnrichedPost {
-	enrichedPosts := make([]models.EnrichedPost, len(posts))
+	result, err := t.Transform(context.Background(), posts)
+	if err == nil {
+		err = t.Commit(context.Background(), result)
+	}
+	if err != nil {
+		log.Printf("Error transforming posts: %v", err)
+	}
+	return result.Posts
+}
+
+// Transform runs posts through the pipeline and reports how many were
+// dropped or flagged as duplicates. It stops at the first stage error or
+// when ctx is cancelled. The result is committed once its posts are stored.
+func (t *Transformer) Transform(ctx context.Context, posts []models.Post) (Result, error) {
+	result := newResult(len(posts))
+	result.batch = newBatch()
 	now := time.Now().UTC()
 
-	for i, post := range posts {
-		enrichedPosts[i] = models.EnrichedPost{
-			UserID:     post.UserID,
-			PostID:     post.ID,
-			Title:      post.Title,
-			Body:       post.Body,
-			IngestedAt: now,
-			Source:     t.sourceName,
+	if t.workers == 1 {
+		for _, post := range posts {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			record, err := t.newRecord(post, now, result.batch)
+			if err != nil {
+				return result, err
+			}
+			if err := t.process(ctx, record, 0, &result, now); err != nil {
+				return result, err
+			}
 		}
+	} else if err := t.transformParallel(ctx, posts, now, &result); err != nil {
+		return result, err
 	}
 
-	return enrichedPosts
+	return result, nil
+}
+
+// Commit persists the state the stages keep for a transformed batch, such
+// as the fingerprints seen by dedup. It is called once the posts of the
+// result are stored, so the posts of a batch that failed to be stored are
+// not taken for duplicates when retried.
+func (t *Transformer) Commit(ctx context.Context, result Result) error {
+	for _, stage := range t.stages {
+		if committer, ok := stage.(Committer); ok && result.batch != nil {
+			if err := committer.Commit(ctx, result.batch); err != nil {
+				return fmt.Errorf("failed to commit stage %s: %w", stage.Name(), err)
+			}
+		}
+		if flusher, ok := stage.(Flusher); ok {
+			if err := flusher.Flush(ctx); err != nil {
+				return fmt.Errorf("failed to flush stage %s: %w", stage.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// transformParallel processes the posts over the worker pool and merges
+// the outcome of each post into result
+func (t *Transformer) transformParallel(parent context.Context, posts []models.Post, now time.Time, result *Result) error {
+	type job struct {
+		index int
+		post  models.Post
+	}
+	type output struct {
+		index  int
+		result Result
+		err    error
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	jobs := make(chan job, t.queueSize)
+	outputs := make(chan output, t.queueSize)
+
+	go func() {
+		defer close(jobs)
+		for i, post := range posts {
+			select {
+			case jobs <- job{index: i, post: post}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < t.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				partial := newResult(1)
+				err := ctx.Err()
+				if err == nil {
+					var record *Record
+					record, err = t.newRecord(j.post, now, result.batch)
+					if err == nil {
+						err = t.process(ctx, record, 0, &partial, now)
+					}
+				}
+				select {
+				case outputs <- output{index: j.index, result: partial, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outputs)
+	}()
+
+	// Outputs that arrive ahead of their turn wait here when order matters
+	pending := make(map[int]Result)
+	next := 0
+	var firstErr error
+	for out := range outputs {
+		switch {
+		case firstErr != nil:
+			continue
+		case out.err != nil:
+			firstErr = out.err
+			cancel()
+		case !t.ordered:
+			result.merge(out.result)
+		default:
+			pending[out.index] = out.result
+			for {
+				partial, ok := pending[next]
+				if !ok {
+					break
+				}
+				result.merge(partial)
+				delete(pending, next)
+				next++
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	// Workers stop without an error when the parent is cancelled
+	return parent.Err()
+}
+
+// Reload refreshes the state stages keep a copy of, such as the dedup
+// window and the mined templates, from the last persisted state. It is
+// called when this replica starts running the source, which another
+// replica may have run meanwhile.
+func (t *Transformer) Reload(ctx context.Context) error {
+	for _, stage := range t.stages {
+		if reloader, ok := stage.(Reloader); ok {
+			if err := reloader.Reload(ctx); err != nil {
+				return fmt.Errorf("failed to reload stage %s: %w", stage.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// newRecord maps a post onto a record of the batch entering the pipeline
+func (t *Transformer) newRecord(post models.Post, now time.Time, batch *Batch) (*Record, error) {
+	ingestedAt := now
+	if post.Stored != nil {
+		ingestedAt = post.Stored.IngestedAt
+	}
+
+	// Fall back to the ingestion time when the payload carries no usable timestamp
+	eventTime, ok := t.timestamps.Extract(post.Fields)
+	if !ok {
+		eventTime = ingestedAt
+	}
+
+	record := &Record{
+		Raw: post,
+		Enriched: models.EnrichedPost{
+			UserID:             post.UserID,
+			PostID:             post.ID,
+			Title:              post.Title,
+			Body:               post.Body,
+			EventTime:          eventTime,
+			IngestedAt:         ingestedAt,
+			Source:             t.sourceName,
+			TransformerVersion: t.version,
+		},
+		batch: batch,
+	}
+
+	switch {
+	case post.Stored != nil:
+		// Reprocessed posts keep their ID, their payload, their tombstone,
+		// the rate they were sampled at and their template, sampling and
+		// template mining being skipped when reprocessing
+		record.Enriched.ID = post.Stored.ID
+		record.Enriched.RawPayload = post.Stored.RawPayload
+		record.Enriched.SampleRate = post.Stored.SampleRate
+		record.Enriched.DeletedAt = post.Stored.DeletedAt
+		record.Enriched.TemplateID = post.Stored.TemplateID
+		record.Enriched.TemplateParams = post.Stored.TemplateParams
+	case t.keepRaw && len(post.Payload) > 0:
+		payload, err := models.CompressPayload(post.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to keep payload of post %d: %w", post.ID, err)
+		}
+		record.Enriched.RawPayload = payload
+	}
+	return record, nil
 }
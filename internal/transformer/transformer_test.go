@@ -1,6 +1,10 @@
 package transformer
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -65,6 +69,30 @@ func TestTransformPosts(t *testing.T) {
 		if enriched.IngestedAt.Before(before) || enriched.IngestedAt.After(after) {
 			t.Errorf("IngestedAt timestamp %v is not between %v and %v", enriched.IngestedAt, before, after)
 		}
+
+		// Without a timestamp in the payload the event time falls back to the ingestion time
+		if !enriched.EventTime.Equal(enriched.IngestedAt) {
+			t.Errorf("Expected EventTime %v to equal IngestedAt %v", enriched.EventTime, enriched.IngestedAt)
+		}
+	}
+}
+
+func TestTransformPostsEventTime(t *testing.T) {
+	// Create a transformer that reads the event time from a custom field
+	transformer := New("test_source", WithTimestampExtractor(NewTimestampExtractor([]string{"logged_at"}, nil, time.UTC)))
+
+	posts := []models.Post{
+		{
+			ID:     1,
+			Fields: map[string]interface{}{"logged_at": "2024-03-15T10:30:00Z"},
+		},
+	}
+
+	enrichedPosts := transformer.TransformPosts(posts)
+
+	expected := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	if !enrichedPosts[0].EventTime.Equal(expected) {
+		t.Errorf("Expected EventTime %v, got %v", expected, enrichedPosts[0].EventTime)
 	}
 }
 
@@ -80,3 +108,143 @@ func TestTransformEmptyPosts(t *testing.T) {
 		t.Fatalf("Expected 0 enriched posts, got %d", len(enrichedPosts))
 	}
 }
+
+func TestTransformWorkers(t *testing.T) {
+	var posts []models.Post
+	for i := 0; i < 200; i++ {
+		posts = append(posts, models.Post{ID: i, Title: "post"})
+	}
+
+	// Later records finish first so the output order must be restored
+	slow := stageFunc(func(record *Record) {
+		time.Sleep(time.Duration(200-record.Enriched.PostID) * 10 * time.Microsecond)
+		record.Count("seen")
+	})
+
+	transform := New("test", WithStages(slow), WithWorkers(8, 4))
+	result, err := transform.Transform(context.Background(), posts)
+	if err != nil {
+		t.Fatalf("Failed to transform: %v", err)
+	}
+
+	if len(result.Posts) != 200 || result.Counters["seen"] != 200 {
+		t.Fatalf("Expected 200 posts, got %d", len(result.Posts))
+	}
+	for i, post := range result.Posts {
+		if post.PostID != i {
+			t.Fatalf("Expected post %d at position %d, got %d", i, i, post.PostID)
+		}
+	}
+
+	timing := result.Stages["func"]
+	if timing.Records != 200 || timing.Total <= 0 || timing.Max <= 0 {
+		t.Errorf("Expected timings for 200 records, got %+v", timing)
+	}
+	if latency := result.StageLatency()["func"]; latency.Records != 200 || latency.MeanMs <= 0 {
+		t.Errorf("Expected mean latency for 200 records, got %+v", latency)
+	}
+
+	// Without ordering every record is still output once
+	transform = New("test", WithStages(slow), WithWorkers(8, 4), WithPreserveOrder(false))
+	result, err = transform.Transform(context.Background(), posts)
+	if err != nil {
+		t.Fatalf("Failed to transform: %v", err)
+	}
+	seen := make(map[int]bool)
+	for _, post := range result.Posts {
+		seen[post.PostID] = true
+	}
+	if len(seen) != 200 {
+		t.Errorf("Expected 200 distinct posts, got %d", len(seen))
+	}
+}
+
+// errorStage fails on one post
+type errorStage struct {
+	id int
+}
+
+func (s errorStage) Name() string {
+	return "error"
+}
+
+func (s errorStage) Process(ctx context.Context, record *Record) error {
+	if record.Enriched.PostID == s.id {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func TestTransformWorkersError(t *testing.T) {
+	var posts []models.Post
+	for i := 0; i < 100; i++ {
+		posts = append(posts, models.Post{ID: i})
+	}
+
+	for _, workers := range []int{1, 4} {
+		transform := New("test", WithStages(errorStage{id: 50}), WithWorkers(workers, 0))
+		if _, err := transform.Transform(context.Background(), posts); err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("Expected stage error with %d workers, got %v", workers, err)
+		}
+	}
+}
+
+func TestTransformCancelled(t *testing.T) {
+	var posts []models.Post
+	for i := 0; i < 100; i++ {
+		posts = append(posts, models.Post{ID: i})
+	}
+
+	var cancel context.CancelFunc
+	stage := stageFunc(func(record *Record) {
+		if record.Enriched.PostID == 10 {
+			cancel()
+		}
+	})
+
+	for _, workers := range []int{1, 4} {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		transform := New("test", WithStages(stage), WithWorkers(workers, 0))
+		result, err := transform.Transform(ctx, posts)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled with %d workers, got %v", workers, err)
+		}
+		if len(result.Posts) == 100 {
+			t.Errorf("Expected the batch to stop early with %d workers", workers)
+		}
+		cancel()
+	}
+}
+
+func TestTransformRawPayload(t *testing.T) {
+	var post models.Post
+	payload := `{"userId":1,"id":3,"title":"Raw","body":"kept as received"}`
+	if err := json.Unmarshal([]byte(payload), &post); err != nil {
+		t.Fatalf("Failed to decode post: %v", err)
+	}
+
+	transformer := New("test_source", WithVersion("v3"), WithRawPayload(true))
+	result, err := transformer.Transform(context.Background(), []models.Post{post})
+	if err != nil {
+		t.Fatalf("Transform returned an error: %v", err)
+	}
+
+	enriched := result.Posts[0]
+	if enriched.TransformerVersion != "v3" {
+		t.Errorf("Expected transformer version v3, got %q", enriched.TransformerVersion)
+	}
+	raw, err := models.DecompressPayload(enriched.RawPayload)
+	if err != nil {
+		t.Fatalf("Failed to decompress payload: %v", err)
+	}
+	if string(raw) != payload {
+		t.Errorf("Expected payload %s, got %s", payload, raw)
+	}
+
+	// Without WithRawPayload the payload is not kept
+	result, _ = New("test_source").Transform(context.Background(), []models.Post{post})
+	if len(result.Posts[0].RawPayload) != 0 {
+		t.Errorf("Expected no raw payload, got %d bytes", len(result.Posts[0].RawPayload))
+	}
+}
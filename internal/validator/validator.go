@@ -0,0 +1,113 @@
+// Package validator checks raw upstream records against per-source JSON
+// Schemas before they are transformed
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+// Validator holds the compiled schema of each source
+type Validator struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+// New compiles the JSON Schema file of each source, keyed by source name
+func New(paths map[string]string) (*Validator, error) {
+	v := &Validator{schemas: make(map[string]*jsonschema.Schema)}
+	for source, path := range paths {
+		schema, err := jsonschema.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile schema for source %s: %w", source, err)
+		}
+		v.schemas[source] = schema
+	}
+	return v, nil
+}
+
+// Validate splits posts into the ones matching the schema of the source and
+// rejected records carrying the validation errors. Sources without a schema
+// accept every post whose fields could be decoded.
+func (v *Validator) Validate(source string, posts []models.Post) ([]models.Post, []models.RejectedRecord) {
+	schema := v.schemas[source]
+
+	valid := make([]models.Post, 0, len(posts))
+	var rejected []models.RejectedRecord
+	now := time.Now().UTC()
+
+	for _, post := range posts {
+		payload := payloadOf(post)
+
+		var errs []string
+		if schema != nil {
+			if err := schema.Validate(payload); err != nil {
+				errs = validationErrors(err)
+			}
+		}
+		if len(errs) == 0 && post.DecodeError != "" {
+			errs = []string{post.DecodeError}
+		}
+		if len(errs) == 0 {
+			valid = append(valid, post)
+			continue
+		}
+
+		rejected = append(rejected, models.RejectedRecord{
+			Source:     source,
+			Reason:     models.RejectedSchema,
+			Payload:    models.Document(payload),
+			Errors:     errs,
+			RejectedAt: now,
+		})
+	}
+
+	return valid, rejected
+}
+
+// payloadOf returns the decoded upstream payload of a post, falling back to
+// its typed fields for posts that were not decoded from JSON
+func payloadOf(post models.Post) map[string]interface{} {
+	if post.Fields != nil {
+		return post.Fields
+	}
+	return map[string]interface{}{
+		"userId": json.Number(fmt.Sprint(post.UserID)),
+		"id":     json.Number(fmt.Sprint(post.ID)),
+		"title":  post.Title,
+		"body":   post.Body,
+	}
+}
+
+// validationErrors flattens a validation error into one message per
+// failing location, e.g. "/userId: expected integer, but got string"
+func validationErrors(err error) []string {
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []string{err.Error()}
+	}
+
+	var messages []string
+	for _, unit := range validationErr.BasicOutput().Errors {
+		// Skip the wrappers that only say a subschema failed
+		if unit.Error == "" || unit.KeywordLocation == "" {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		messages = append(messages, location+": "+unit.Error)
+	}
+	if len(messages) == 0 {
+		messages = append(messages, validationErr.Error())
+	}
+
+	sort.Strings(messages)
+	return messages
+}
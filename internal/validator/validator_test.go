@@ -0,0 +1,124 @@
+package validator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiwariayush700/log-ingestion-service/internal/models"
+)
+
+const postSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["userId", "id", "title"],
+	"properties": {
+		"userId": {"type": "integer", "minimum": 1},
+		"id": {"type": "integer"},
+		"title": {"type": "string", "minLength": 1}
+	}
+}`
+
+func newTestValidator(t *testing.T) *Validator {
+	path := filepath.Join(t.TempDir(), "posts.json")
+	if err := os.WriteFile(path, []byte(postSchema), 0o644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+
+	v, err := New(map[string]string{"posts": path})
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+	return v
+}
+
+func decodePosts(t *testing.T, data string) []models.Post {
+	var posts []models.Post
+	if err := json.Unmarshal([]byte(data), &posts); err != nil {
+		t.Fatalf("Failed to decode posts: %v", err)
+	}
+	return posts
+}
+
+func TestValidate(t *testing.T) {
+	v := newTestValidator(t)
+	posts := decodePosts(t, `[
+		{"userId": 1, "id": 1, "title": "valid", "extra": {"n": 1.5}},
+		{"id": 2, "title": "missing user"},
+		{"userId": "1", "id": 3, "title": ""}
+	]`)
+
+	valid, rejected := v.Validate("posts", posts)
+
+	if len(valid) != 1 || valid[0].ID != 1 {
+		t.Fatalf("Expected only post 1 to be valid, got %+v", valid)
+	}
+	if len(rejected) != 2 {
+		t.Fatalf("Expected 2 rejected records, got %d", len(rejected))
+	}
+
+	missing := rejected[0]
+	if missing.Source != "posts" || missing.Reason != models.RejectedSchema {
+		t.Errorf("Expected source posts and reason schema, got %s and %s", missing.Source, missing.Reason)
+	}
+	if len(missing.Errors) != 1 || !strings.Contains(missing.Errors[0], "userId") {
+		t.Errorf("Expected an error about the missing userId, got %v", missing.Errors)
+	}
+	if id, ok := missing.Payload["id"].(int64); !ok || id != 2 {
+		t.Errorf("Expected payload id to be stored as int64 2, got %#v", missing.Payload["id"])
+	}
+
+	// Both failures of the third post are reported
+	if errs := rejected[1].Errors; len(errs) != 2 || !strings.HasPrefix(errs[0], "/title") || !strings.HasPrefix(errs[1], "/userId") {
+		t.Errorf("Expected errors for /title and /userId, got %v", errs)
+	}
+}
+
+func TestValidateWithoutSchema(t *testing.T) {
+	v := newTestValidator(t)
+	posts := decodePosts(t, `[{"id": 1}]`)
+
+	valid, rejected := v.Validate("other", posts)
+	if len(valid) != 1 || len(rejected) != 0 {
+		t.Errorf("Expected every post to be accepted, got %d valid and %d rejected", len(valid), len(rejected))
+	}
+}
+
+func TestValidateDecodeError(t *testing.T) {
+	v := newTestValidator(t)
+	posts := decodePosts(t, `[{"userId": "1", "id": 1, "title": "string user"}]`)
+
+	// Posts with mistyped fields are rejected even without a schema
+	valid, rejected := v.Validate("other", posts)
+	if len(valid) != 0 || len(rejected) != 1 {
+		t.Fatalf("Expected the post to be rejected, got %d valid and %d rejected", len(valid), len(rejected))
+	}
+	if !strings.Contains(rejected[0].Errors[0], "userId") {
+		t.Errorf("Expected an error about userId, got %v", rejected[0].Errors)
+	}
+}
+
+func TestValidateTypedPost(t *testing.T) {
+	v := newTestValidator(t)
+
+	valid, _ := v.Validate("posts", []models.Post{{UserID: 1, ID: 1, Title: "typed"}})
+	if len(valid) != 1 {
+		t.Errorf("Expected a typed post to be validated from its fields")
+	}
+}
+
+func TestNewInvalidSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte(`{"type": 5}`), 0o644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+
+	if _, err := New(map[string]string{"posts": path}); err == nil {
+		t.Error("Expected error for an invalid schema, got nil")
+	}
+	if _, err := New(map[string]string{"posts": filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Error("Expected error for a missing schema, got nil")
+	}
+}